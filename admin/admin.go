@@ -0,0 +1,448 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"projectt/models"
+	"projectt/socket"
+	"projectt/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API serves the admin HTTP/JSON endpoints for a game server
+type API struct {
+	server *socket.GameServer
+	token  string
+	mux    *http.ServeMux
+}
+
+// NewAPI returns the admin API handler. If token is not empty every request
+// must carry it as a bearer token.
+func NewAPI(server *socket.GameServer, token string) *API {
+	a := &API{server: server, token: token, mux: http.NewServeMux()}
+
+	a.mux.HandleFunc("GET /stats", a.handleStats)
+	a.mux.HandleFunc("GET /connections", a.handleConnections)
+	a.mux.HandleFunc("GET /players", a.handlePlayers)
+	a.mux.HandleFunc("POST /players/{nickname}/kick", a.handleKick)
+	a.mux.HandleFunc("POST /players/{nickname}/ban", a.handleBan)
+	a.mux.HandleFunc("DELETE /players/{nickname}/ban", a.handleUnbanPlayer)
+	a.mux.HandleFunc("GET /bans", a.handleBans)
+	a.mux.HandleFunc("POST /bans", a.handleBanIP)
+	a.mux.HandleFunc("DELETE /bans/{id}", a.handleUnban)
+	a.mux.HandleFunc("POST /players/{nickname}/mute", a.handleMute)
+	a.mux.HandleFunc("DELETE /players/{nickname}/mute", a.handleUnmute)
+	a.mux.HandleFunc("POST /players/{nickname}/teleport", a.handleTeleport)
+	a.mux.HandleFunc("PUT /players/{nickname}/role", a.handleSetRole)
+	a.mux.HandleFunc("POST /notice", a.handleNotice)
+	a.mux.HandleFunc("GET /tiles/{x}/{y}", a.handleGetTile)
+	a.mux.HandleFunc("PATCH /tiles/{x}/{y}", a.handleEditTile)
+	a.mux.HandleFunc("GET /countries", a.handleCountries)
+	a.mux.HandleFunc("PATCH /countries/{id}", a.handleEditCountry)
+	a.mux.HandleFunc("POST /save", a.handleSave)
+
+	return a
+}
+
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+	}
+
+	log.Printf("Admin API: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+	a.mux.ServeHTTP(w, r)
+}
+
+func (a *API) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.server.Stats())
+}
+
+func (a *API) handleConnections(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.server.Connections())
+}
+
+func (a *API) handlePlayers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.server.OnlinePlayers())
+}
+
+type reasonRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (a *API) handleKick(w http.ResponseWriter, r *http.Request) {
+	var req reasonRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := a.server.Kick(r.PathValue("nickname"), req.Reason); err != nil {
+		writeServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bannedBy is recorded as the author of bans made through the API
+const bannedBy = "admin api"
+
+type banRequest struct {
+	// IP address or CIDR range, only for POST /bans
+	IP string `json:"ip"`
+	// Go duration such as "24h", empty for a permanent ban
+	Duration string `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+func (a *API) handleBan(w http.ResponseWriter, r *http.Request) {
+	var req banRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	duration, ok := readDuration(w, req.Duration)
+	if !ok {
+		return
+	}
+	ban, err := a.server.BanPlayer(r.PathValue("nickname"), duration, req.Reason, bannedBy)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ban)
+}
+
+func (a *API) handleUnbanPlayer(w http.ResponseWriter, r *http.Request) {
+	if err := a.server.UnbanPlayer(r.PathValue("nickname")); err != nil {
+		writeServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) handleBans(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.server.Bans())
+}
+
+func (a *API) handleBanIP(w http.ResponseWriter, r *http.Request) {
+	var req banRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	duration, ok := readDuration(w, req.Duration)
+	if !ok {
+		return
+	}
+	ban, err := a.server.BanIP(req.IP, duration, req.Reason, bannedBy)
+	if errors.Is(err, socket.ErrInvalidAddress) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ban)
+}
+
+func (a *API) handleUnban(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid ban id")
+		return
+	}
+	if err := a.server.Unban(uint(id)); err != nil {
+		writeServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type muteRequest struct {
+	// Go duration such as "10m", empty for a permanent mute
+	Duration string `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+func (a *API) handleMute(w http.ResponseWriter, r *http.Request) {
+	var req muteRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	duration, ok := readDuration(w, req.Duration)
+	if !ok {
+		return
+	}
+	player, err := a.server.Mute(r.PathValue("nickname"), duration, req.Reason)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, player)
+}
+
+func (a *API) handleUnmute(w http.ResponseWriter, r *http.Request) {
+	if err := a.server.Unmute(r.PathValue("nickname")); err != nil {
+		writeServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type teleportRequest struct {
+	X *float32 `json:"x"`
+	Y *float32 `json:"y"`
+}
+
+func (a *API) handleTeleport(w http.ResponseWriter, r *http.Request) {
+	var req teleportRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.X == nil || req.Y == nil {
+		writeError(w, http.StatusBadRequest, "x and y are required")
+		return
+	}
+	player, err := a.server.Teleport(r.PathValue("nickname"), *req.X, *req.Y)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, player)
+}
+
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+func (a *API) handleSetRole(w http.ResponseWriter, r *http.Request) {
+	var req roleRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	role, err := types.ParseStaffRole(req.Role)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := a.server.SetStaffRole(r.PathValue("nickname"), role); err != nil {
+		writeServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type noticeRequest struct {
+	Message string `json:"message"`
+}
+
+func (a *API) handleNotice(w http.ResponseWriter, r *http.Request) {
+	var req noticeRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		writeError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+	if err := a.server.BroadcastNotice(req.Message); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) handleGetTile(w http.ResponseWriter, r *http.Request) {
+	x, y, ok := tileCoords(w, r)
+	if !ok {
+		return
+	}
+	tile, exists := a.server.Tile(x, y)
+	if !exists {
+		writeServerError(w, socket.ErrTileNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, tile)
+}
+
+// tileEdit holds the editable tile fields; nil fields are left unchanged
+type tileEdit struct {
+	TileType            *types.TileType `json:"tile_type"`
+	OwnerCountryID      *uint8          `json:"owner_country_id"`
+	IsBorder            *bool           `json:"is_border"`
+	OccupiedByCountryID *uint8          `json:"occupied_by_country_id"`
+	ClearOccupation     bool            `json:"clear_occupation"`
+}
+
+func (a *API) handleEditTile(w http.ResponseWriter, r *http.Request) {
+	x, y, ok := tileCoords(w, r)
+	if !ok {
+		return
+	}
+	var req tileEdit
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.TileType == nil && req.OwnerCountryID == nil && req.IsBorder == nil &&
+		req.OccupiedByCountryID == nil && !req.ClearOccupation {
+		writeError(w, http.StatusBadRequest, "no tile fields to edit")
+		return
+	}
+	if req.TileType != nil && !req.TileType.Valid() {
+		writeError(w, http.StatusBadRequest, "unknown tile_type")
+		return
+	}
+	if req.OwnerCountryID != nil && !a.countryExists(*req.OwnerCountryID) {
+		writeError(w, http.StatusBadRequest, "unknown owner_country_id")
+		return
+	}
+	if req.OccupiedByCountryID != nil && !a.countryExists(*req.OccupiedByCountryID) {
+		writeError(w, http.StatusBadRequest, "unknown occupied_by_country_id")
+		return
+	}
+
+	tile, err := a.server.EditTile(x, y, func(tile *models.MapTile) {
+		if req.TileType != nil {
+			tile.TileType = *req.TileType
+		}
+		if req.OwnerCountryID != nil {
+			tile.OwnerCountryID = *req.OwnerCountryID
+		}
+		if req.IsBorder != nil {
+			tile.IsBorder = *req.IsBorder
+		}
+		if req.OccupiedByCountryID != nil {
+			tile.OccupiedByCountryID = req.OccupiedByCountryID
+		}
+		if req.ClearOccupation {
+			tile.OccupiedByCountryID = nil
+			tile.OccupiedAt = nil
+		}
+	})
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tile)
+}
+
+func (a *API) handleCountries(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.server.Countries())
+}
+
+type countryEdit struct {
+	IsAIControlled *bool `json:"is_ai_controlled"`
+}
+
+func (a *API) handleEditCountry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 8)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid country id")
+		return
+	}
+	var req countryEdit
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.IsAIControlled == nil {
+		writeError(w, http.StatusBadRequest, "is_ai_controlled is required")
+		return
+	}
+
+	country, err := a.server.EditCountry(uint8(id), func(country *models.Country) {
+		if req.IsAIControlled != nil {
+			country.IsAIControlled = *req.IsAIControlled
+		}
+	})
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, country)
+}
+
+// countryExists reports whether a country with the given id is loaded
+func (a *API) countryExists(id uint8) bool {
+	for _, country := range a.server.Countries() {
+		if country.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *API) handleSave(w http.ResponseWriter, r *http.Request) {
+	a.server.Save()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func tileCoords(w http.ResponseWriter, r *http.Request) (uint16, uint16, bool) {
+	x, errX := strconv.ParseUint(r.PathValue("x"), 10, 16)
+	y, errY := strconv.ParseUint(r.PathValue("y"), 10, 16)
+	if errX != nil || errY != nil {
+		writeError(w, http.StatusBadRequest, "invalid tile coordinates")
+		return 0, 0, false
+	}
+	return uint16(x), uint16(y), true
+}
+
+// readDuration parses an optional positive duration; empty means permanent
+func readDuration(w http.ResponseWriter, value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, true
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		writeError(w, http.StatusBadRequest, "duration must be a positive duration such as 10m")
+		return 0, false
+	}
+	return duration, true
+}
+
+// readJSON decodes the request body into v. The body is required, send {}
+// when all fields are optional.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "request body is required")
+		return false
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func writeServerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, socket.ErrPlayerNotFound),
+		errors.Is(err, socket.ErrBanNotFound),
+		errors.Is(err, socket.ErrTileNotFound),
+		errors.Is(err, socket.ErrCountryNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, socket.ErrInvalidPosition):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
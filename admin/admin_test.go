@@ -0,0 +1,202 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"projectt/config"
+	"projectt/models"
+	"projectt/socket"
+	"projectt/storage"
+	"projectt/types"
+	"strings"
+	"testing"
+)
+
+func newTestAPI(t *testing.T, token string) *API {
+	t.Helper()
+
+	store := storage.NewMemory()
+	store.SaveCountry(&models.Country{ID: 1, Code: "TR"})
+	store.SaveCountry(&models.Country{ID: 2, Code: "DE"})
+	store.SaveTiles([]models.MapTile{{CoordX: 5, CoordY: 6, OwnerCountryID: 1, TileType: types.TileTypeGround}})
+	store.SavePlayer(&models.Player{Nickname: "Carl", CountryID: 1, Rank: types.PlayerRankCitizen})
+
+	server := socket.NewGameServer(config.Default(), store)
+	if err := server.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return NewAPI(server, token)
+}
+
+func do(api *API, method, path, body, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthorization(t *testing.T) {
+	api := newTestAPI(t, "secret")
+
+	if rec := do(api, "GET", "/stats", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rec.Code)
+	}
+	if rec := do(api, "GET", "/stats", "", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with wrong token, got %d", rec.Code)
+	}
+	if rec := do(api, "GET", "/stats", "", "secret"); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with token, got %d", rec.Code)
+	}
+}
+
+func TestStats(t *testing.T) {
+	api := newTestAPI(t, "")
+
+	rec := do(api, "GET", "/stats", "", "")
+	var stats socket.ServerStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Countries != 2 || stats.Tiles != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestEditTile(t *testing.T) {
+	api := newTestAPI(t, "")
+
+	rec := do(api, "PATCH", "/tiles/5/6", `{"owner_country_id": 2, "occupied_by_country_id": 1}`, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body)
+	}
+	tile, _ := api.server.Tile(5, 6)
+	if tile.OwnerCountryID != 2 || tile.OccupiedByCountryID == nil || *tile.OccupiedByCountryID != 1 {
+		t.Fatalf("tile was not updated: %+v", tile)
+	}
+	if stats := api.server.Stats(); stats.DirtyTiles != 1 {
+		t.Fatalf("expected the tile to be marked for saving, got %d dirty tiles", stats.DirtyTiles)
+	}
+
+	if rec := do(api, "PATCH", "/tiles/50/60", `{"owner_country_id": 2}`, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for missing tile, got %d", rec.Code)
+	}
+	for _, body := range []string{`{"owner": 2}`, ``, `{}`, `{"tile_type": 7}`, `{"owner_country_id": 9}`, `{"occupied_by_country_id": 9}`} {
+		if rec := do(api, "PATCH", "/tiles/5/6", body, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", body, rec.Code)
+		}
+	}
+	if tile, _ := api.server.Tile(5, 6); tile.OwnerCountryID != 2 || tile.TileType != types.TileTypeGround {
+		t.Fatalf("rejected edits changed the tile: %+v", tile)
+	}
+}
+
+func TestEditCountry(t *testing.T) {
+	api := newTestAPI(t, "")
+
+	rec := do(api, "PATCH", "/countries/2", `{"is_ai_controlled": true}`, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body)
+	}
+	for _, country := range api.server.Countries() {
+		if country.ID == 2 && !country.IsAIControlled {
+			t.Fatal("country was not updated")
+		}
+	}
+	if rec := do(api, "PATCH", "/countries/2", `{}`, ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an empty edit, got %d", rec.Code)
+	}
+}
+
+func TestPlayerNotFound(t *testing.T) {
+	api := newTestAPI(t, "")
+
+	for path, body := range map[string]string{"/players/nobody/kick": `{}`, "/players/nobody/teleport": `{"x": 1, "y": 1}`} {
+		if rec := do(api, "POST", path, body, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestTeleportOutsideWorld(t *testing.T) {
+	api := newTestAPI(t, "")
+
+	for _, body := range []string{``, `{}`, `{"x": 5}`, `{"x": -1, "y": 5}`, `{"x": 5, "y": 1e9}`} {
+		if rec := do(api, "POST", "/players/nobody/teleport", body, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rec.Code)
+		}
+	}
+}
+
+func TestNoticeRequiresMessage(t *testing.T) {
+	api := newTestAPI(t, "")
+
+	if rec := do(api, "POST", "/notice", `{"message": " "}`, ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if rec := do(api, "POST", "/notice", `{"message": "Restart in 5 minutes"}`, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+}
+
+func TestMuteOfflinePlayer(t *testing.T) {
+	api := newTestAPI(t, "")
+
+	if rec := do(api, "POST", "/players/carl/mute", `{"duration":"soon"}`, ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid duration, got %d", rec.Code)
+	}
+
+	rec := do(api, "POST", "/players/carl/mute", `{"duration":"10m","reason":"spam"}`, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var player models.Player
+	if err := json.NewDecoder(rec.Body).Decode(&player); err != nil {
+		t.Fatal(err)
+	}
+	if !player.Muted || player.MuteExpires == nil || player.MuteReason != "spam" {
+		t.Fatalf("unexpected mute state %+v", player)
+	}
+
+	if rec := do(api, "DELETE", "/players/carl/mute", "", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if rec := do(api, "DELETE", "/players/nobody/mute", "", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestBans(t *testing.T) {
+	api := newTestAPI(t, "")
+
+	if rec := do(api, "POST", "/bans", `{"ip":"localhost"}`, ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid address, got %d", rec.Code)
+	}
+	if rec := do(api, "POST", "/bans", `{"ip":"203.0.113.0/24","duration":"24h"}`, ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(api, "POST", "/players/carl/ban", `{"reason":"griefing"}`, ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var bans []models.Ban
+	if err := json.NewDecoder(do(api, "GET", "/bans", "", "").Body).Decode(&bans); err != nil {
+		t.Fatal(err)
+	}
+	if len(bans) != 2 || bans[0].CIDR != "203.0.113.0/24" || bans[1].Nickname != "Carl" || bans[1].ExpiresAt != nil {
+		t.Fatalf("unexpected bans %+v", bans)
+	}
+
+	if rec := do(api, "DELETE", "/players/carl/ban", "", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if rec := do(api, "DELETE", "/players/carl/ban", "", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without bans, got %d", rec.Code)
+	}
+	if rec := do(api, "DELETE", "/bans/99", "", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown ban, got %d", rec.Code)
+	}
+}
@@ -3,7 +3,9 @@ package binary
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"strings"
+	"unicode/utf8"
 )
 
 type ChatMessageType uint8
@@ -14,12 +16,23 @@ const (
 	ChatMessageTypeCountry
 	ChatMessageTypeSystem
 	ChatMessageTypeNotice
+	ChatMessageTypeLocal // Players within view distance
 )
 
 type ChatMessage struct {
 	Type    ChatMessageType // 1 byte
 	From    string          // 2 byte (Player name, Maximum 255 characters)
 	Message string          // 2 byte (Maximum 255 characters)
+	To      string          // 2 byte (Whisper recipient, Maximum 255 characters)
+}
+
+// IsClientChannel reports whether clients may send messages on this channel
+func (t ChatMessageType) IsClientChannel() bool {
+	switch t {
+	case ChatMessageTypeGeneral, ChatMessageTypeWhisper, ChatMessageTypeCountry, ChatMessageTypeLocal:
+		return true
+	}
+	return false
 }
 
 func EncodeChatMessage(m *ChatMessage) ([]byte, error) {
@@ -43,36 +56,75 @@ func EncodeChatMessage(m *ChatMessage) ([]byte, error) {
 	buf.WriteByte(uint8(messageLen))
 	buf.Write(messageBytes)
 
+	toBytes := []byte(m.To)
+	toLen := len(toBytes)
+	if toLen > 255 {
+		return nil, fmt.Errorf("recipient name too long")
+	}
+	buf.WriteByte(uint8(toLen))
+	buf.Write(toBytes)
+
 	return buf.Bytes(), nil
 }
 
+// DecodeChatMessage decodes a chat message sent by a client:
+// channel (1 byte), message (1 byte length + data) and, for whispers,
+// the recipient (1 byte length + data)
 func DecodeChatMessage(data []byte) (*ChatMessage, error) {
-	if len(data) < 1 { // minimum 1 byte for message length
+	if len(data) < 2 { // minimum 1 byte channel + 1 byte message length
 		return nil, fmt.Errorf("data too short")
 	}
 
 	buf := bytes.NewReader(data)
 	m := &ChatMessage{}
 
-	// Message length (1 byte)
-	msgLenByte, err := buf.ReadByte()
+	// Channel (1 byte)
+	channel, err := buf.ReadByte()
 	if err != nil {
 		return nil, err
 	}
-	msgLen := int(msgLenByte)
+	m.Type = ChatMessageType(channel)
 
-	// Check if remaining data is enough
-	if buf.Len() < msgLen {
-		return nil, fmt.Errorf("invalid message length")
+	// Message (1 byte length + data)
+	message, err := readShortString(buf)
+	if err != nil {
+		return nil, err
 	}
+	m.Message = strings.TrimSpace(message)
 
-	// Message (msgLen byte)
-	msgBytes := make([]byte, msgLen)
-	if _, err := buf.Read(msgBytes); err != nil {
-		return nil, err
+	// Recipient (optional, 1 byte length + data)
+	if buf.Len() > 0 {
+		to, err := readShortString(buf)
+		if err != nil {
+			return nil, err
+		}
+		m.To = strings.TrimSpace(to)
+	}
+	if buf.Len() > 0 {
+		return nil, fmt.Errorf("unexpected data after chat message")
+	}
+	if !utf8.ValidString(m.Message) || !utf8.ValidString(m.To) {
+		return nil, fmt.Errorf("chat message is not valid UTF-8")
 	}
-	m.Message = string(msgBytes)
-	m.Message = strings.TrimSpace(m.Message)
 
 	return m, nil
 }
+
+// readShortString reads a string prefixed with its 1 byte length
+func readShortString(buf *bytes.Reader) (string, error) {
+	strLen, err := buf.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	// Check if remaining data is enough
+	if buf.Len() < int(strLen) {
+		return "", fmt.Errorf("invalid string length")
+	}
+
+	strBytes := make([]byte, strLen)
+	if _, err := io.ReadFull(buf, strBytes); err != nil {
+		return "", err
+	}
+	return string(strBytes), nil
+}
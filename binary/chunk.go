@@ -67,8 +67,8 @@ func EncodeChunkPacket(packet ChunkPacket) ([]byte, error) {
 }
 
 func DecodeChunkRequest(data []byte) (*ChunkRequest, error) {
-	if len(data) < 4 { // minimum 2 + 2 byte
-		return nil, fmt.Errorf("data too short")
+	if len(data) != 4 { // 2 + 2 byte
+		return nil, fmt.Errorf("invalid chunk request length %d", len(data))
 	}
 
 	buf := bytes.NewReader(data)
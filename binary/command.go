@@ -0,0 +1,84 @@
+package binary
+
+import (
+	"bytes"
+	"fmt"
+)
+
+type CommandArgKind uint8
+
+const (
+	CommandArgWord CommandArgKind = iota
+	CommandArgPlayer
+	CommandArgNumber
+	CommandArgText // rest of the line
+)
+
+type CommandArg struct {
+	Name     string
+	Kind     CommandArgKind
+	Optional bool
+}
+
+// CommandInfo describes a chat command for client-side help and tab completion
+type CommandInfo struct {
+	Name        string
+	Aliases     []string
+	Usage       string
+	Description string
+	Args        []CommandArg
+}
+
+func EncodeCommandList(commands []CommandInfo) ([]byte, error) {
+	if len(commands) > 255 {
+		return nil, fmt.Errorf("too many commands")
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(uint8(len(commands)))
+
+	for _, c := range commands {
+		if err := writeShortString(buf, c.Name); err != nil {
+			return nil, err
+		}
+
+		buf.WriteByte(uint8(len(c.Aliases)))
+		for _, alias := range c.Aliases {
+			if err := writeShortString(buf, alias); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := writeShortString(buf, c.Usage); err != nil {
+			return nil, err
+		}
+		if err := writeShortString(buf, c.Description); err != nil {
+			return nil, err
+		}
+
+		buf.WriteByte(uint8(len(c.Args)))
+		for _, arg := range c.Args {
+			if err := writeShortString(buf, arg.Name); err != nil {
+				return nil, err
+			}
+			buf.WriteByte(uint8(arg.Kind))
+			optional := uint8(0)
+			if arg.Optional {
+				optional = 1
+			}
+			buf.WriteByte(optional)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeShortString writes a string prefixed with its 1 byte length
+func writeShortString(buf *bytes.Buffer, s string) error {
+	if len(s) > 255 {
+		return fmt.Errorf("string too long")
+	}
+	buf.WriteByte(uint8(len(s)))
+	buf.WriteString(s)
+	return nil
+}
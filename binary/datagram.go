@@ -0,0 +1,164 @@
+package binary
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// DatagramKeySize is the length of the per-connection UDP key sent in
+	// the welcome message
+	DatagramKeySize = 32
+	// DatagramTagSize is the length of the MAC or AEAD tag ending a datagram
+	DatagramTagSize = 16
+
+	datagramHeaderSize = 4 + 8
+)
+
+// Datagram is a UDP packet sent by a client:
+//
+//	[connID u32][sequence u64][payload][tag 16 byte]
+//
+// The header is always readable so the server can find the connection. How
+// payload and tag are formed depends on the connection's DatagramCodec.
+// Sequence numbers start at 1 and increase with every datagram so the
+// server can drop replays.
+type Datagram struct {
+	ConnectionID uint32
+	Sequence     uint64
+
+	header []byte
+	body   []byte // payload followed by the tag
+}
+
+// ParseDatagram splits a packet into header and body without checking it
+func ParseDatagram(data []byte) (*Datagram, error) {
+	if len(data) < datagramHeaderSize+DatagramTagSize {
+		return nil, fmt.Errorf("datagram too short")
+	}
+	return &Datagram{
+		ConnectionID: binary.LittleEndian.Uint32(data),
+		Sequence:     binary.LittleEndian.Uint64(data[4:]),
+		header:       data[:datagramHeaderSize],
+		body:         data[datagramHeaderSize:],
+	}, nil
+}
+
+// DatagramCodec protects the UDP traffic of one connection
+type DatagramCodec interface {
+	// SealClient frames a datagram sent by the client
+	SealClient(connID uint32, sequence uint64, payload []byte) []byte
+	// OpenClient checks a client datagram and returns its payload
+	OpenClient(d *Datagram) ([]byte, error)
+	// SealServer frames a datagram sent to the client. sequence must not
+	// repeat for the same key.
+	SealServer(sequence uint64, payload []byte) []byte
+	// OpenServer checks a datagram sent by the server and returns its payload
+	OpenServer(data []byte) ([]byte, error)
+}
+
+// NewSignedCodec authenticates client datagrams with HMAC-SHA256 truncated to
+// DatagramTagSize, computed over header and payload. Payloads stay readable
+// and server datagrams are sent as plain raw messages.
+func NewSignedCodec(key [DatagramKeySize]byte) DatagramCodec {
+	return signedCodec{key: key[:]}
+}
+
+type signedCodec struct {
+	key []byte
+}
+
+func (c signedCodec) SealClient(connID uint32, sequence uint64, payload []byte) []byte {
+	packet := make([]byte, datagramHeaderSize, datagramHeaderSize+len(payload)+DatagramTagSize)
+	binary.LittleEndian.PutUint32(packet, connID)
+	binary.LittleEndian.PutUint64(packet[4:], sequence)
+	packet = append(packet, payload...)
+	return append(packet, c.mac(packet)...)
+}
+
+func (c signedCodec) OpenClient(d *Datagram) ([]byte, error) {
+	end := len(d.body) - DatagramTagSize
+	payload := d.body[:end]
+	signed := make([]byte, 0, len(d.header)+len(payload))
+	signed = append(append(signed, d.header...), payload...)
+	if !hmac.Equal(d.body[end:], c.mac(signed)) {
+		return nil, fmt.Errorf("invalid datagram signature")
+	}
+	return payload, nil
+}
+
+func (c signedCodec) SealServer(sequence uint64, payload []byte) []byte {
+	return payload
+}
+
+func (c signedCodec) OpenServer(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (c signedCodec) mac(data []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(data)
+	return mac.Sum(nil)[:DatagramTagSize]
+}
+
+// NewEncryptedCodec encrypts datagrams in both directions with AES-256-GCM.
+// Client datagrams authenticate their header as additional data. Server
+// datagrams are framed as [sequence u64][ciphertext][tag 16 byte]. The nonce
+// is the direction (0 from the client, 1 from the server) as u32 followed by
+// the sequence, so the key must only be used for a single connection.
+func NewEncryptedCodec(key [DatagramKeySize]byte) DatagramCodec {
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		panic(err) // unreachable, a 32 byte key selects AES-256
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return encryptedCodec{aead: aead}
+}
+
+type encryptedCodec struct {
+	aead cipher.AEAD
+}
+
+const (
+	directionClient uint32 = iota
+	directionServer
+)
+
+func (c encryptedCodec) nonce(direction uint32, sequence uint64) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	binary.LittleEndian.PutUint32(nonce, direction)
+	binary.LittleEndian.PutUint64(nonce[4:], sequence)
+	return nonce
+}
+
+func (c encryptedCodec) SealClient(connID uint32, sequence uint64, payload []byte) []byte {
+	packet := make([]byte, datagramHeaderSize, datagramHeaderSize+len(payload)+DatagramTagSize)
+	binary.LittleEndian.PutUint32(packet, connID)
+	binary.LittleEndian.PutUint64(packet[4:], sequence)
+	return c.aead.Seal(packet, c.nonce(directionClient, sequence), payload, packet)
+}
+
+func (c encryptedCodec) OpenClient(d *Datagram) ([]byte, error) {
+	return c.aead.Open(nil, c.nonce(directionClient, d.Sequence), d.body, d.header)
+}
+
+func (c encryptedCodec) SealServer(sequence uint64, payload []byte) []byte {
+	packet := make([]byte, 8, 8+len(payload)+DatagramTagSize)
+	binary.LittleEndian.PutUint64(packet, sequence)
+	return c.aead.Seal(packet, c.nonce(directionServer, sequence), payload, packet)
+}
+
+func (c encryptedCodec) OpenServer(data []byte) ([]byte, error) {
+	if len(data) < 8+DatagramTagSize {
+		return nil, fmt.Errorf("datagram too short")
+	}
+	sequence := binary.LittleEndian.Uint64(data)
+	return c.aead.Open(nil, c.nonce(directionServer, sequence), data[8:], data[:8])
+}
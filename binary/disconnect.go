@@ -0,0 +1,36 @@
+package binary
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+type DisconnectReason uint8
+
+const (
+	DisconnectReasonKicked DisconnectReason = iota + 1
+	DisconnectReasonBanned
+	DisconnectReasonTimeout           // no message within the heartbeat timeout
+	DisconnectReasonServerShutdown    // the server is stopping
+	DisconnectReasonReplacedByLogin   // the player logged in from another connection
+	DisconnectReasonProtocolViolation // too many malformed or oversized messages
+)
+
+// DisconnectMessage is sent by the server right before it closes a connection
+type DisconnectMessage struct {
+	Reason  DisconnectReason // 1 byte
+	Message string           // 1 byte length + data (e.g. the ban reason)
+	Until   int64            // 8 byte (Unix milliseconds a ban ends, 0 if permanent or not banned)
+}
+
+func EncodeDisconnectMessage(m *DisconnectMessage) ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	buf.WriteByte(byte(m.Reason))
+	if err := writeShortString(buf, m.Message); err != nil {
+		return nil, err
+	}
+	binary.Write(buf, binary.LittleEndian, m.Until)
+
+	return buf.Bytes(), nil
+}
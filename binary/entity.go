@@ -0,0 +1,70 @@
+package binary
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Entity is a unit, soldier or projectile in the sync state. Players are
+// sent as Player instead.
+type Entity struct {
+	ID         uint32 // 4 byte
+	Type       uint8  // 1 byte (EntityType enum)
+	UnitType   uint8  // 1 byte (UnitType enum, 0 for projectiles)
+	CountryID  uint8  // 1 byte
+	PosX, PosY float32
+	VelX, VelY float32 // tiles per second
+	Health     uint32
+	MaxHealth  uint32
+}
+
+// EntityMovementData is the state of an entity not controlled by a player,
+// sent while it moves and now and then while it stands
+type EntityMovementData struct {
+	EntityID   uint32
+	EntityType uint8
+	UnitType   uint8
+	CountryID  uint8
+	PosX, PosY float32
+	VelX, VelY float32 // tiles per second, zero while standing
+	Tick       uint32  // Server tick this state belongs to
+}
+
+func EncodeEntity(e *Entity) []byte {
+	buf := new(bytes.Buffer)
+
+	binary.Write(buf, binary.LittleEndian, e.ID)
+	buf.WriteByte(e.Type)
+	buf.WriteByte(e.UnitType)
+	buf.WriteByte(e.CountryID)
+	binary.Write(buf, binary.LittleEndian, e.PosX)
+	binary.Write(buf, binary.LittleEndian, e.PosY)
+	binary.Write(buf, binary.LittleEndian, e.VelX)
+	binary.Write(buf, binary.LittleEndian, e.VelY)
+	binary.Write(buf, binary.LittleEndian, e.Health)
+	binary.Write(buf, binary.LittleEndian, e.MaxHealth)
+
+	return buf.Bytes()
+}
+
+func EncodeEntityMovementData(m *EntityMovementData) []byte {
+	buf := new(bytes.Buffer)
+
+	binary.Write(buf, binary.LittleEndian, m.EntityID)
+	buf.WriteByte(m.EntityType)
+	buf.WriteByte(m.UnitType)
+	buf.WriteByte(m.CountryID)
+	binary.Write(buf, binary.LittleEndian, m.PosX)
+	binary.Write(buf, binary.LittleEndian, m.PosY)
+	binary.Write(buf, binary.LittleEndian, m.VelX)
+	binary.Write(buf, binary.LittleEndian, m.VelY)
+	binary.Write(buf, binary.LittleEndian, m.Tick)
+
+	return buf.Bytes()
+}
+
+// EncodeEntityRemoved returns the ID of an entity that left the world, for
+// EntityRemovedMessage
+func EncodeEntityRemoved(id uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, id)
+}
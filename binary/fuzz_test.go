@@ -0,0 +1,141 @@
+package binary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"projectt/types"
+	"testing"
+	"unicode/utf8"
+)
+
+// Every decoder parses client input. The fuzz targets check that none of
+// them panic and that accepted input is within the documented bounds.
+
+func FuzzDecodeRawMessage(f *testing.F) {
+	seed, _ := EncodeRawMessage(Message{Type: types.ChatMessage, Data: []byte("hi"), Error: "error.x"})
+	f.Add(seed)
+	f.Add([]byte{0, 0xff, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := DecodeRawMessage(data)
+		if err != nil {
+			return
+		}
+		encoded, err := EncodeRawMessage(*msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(encoded, data) {
+			t.Fatalf("round trip changed %v to %v", data, encoded)
+		}
+	})
+}
+
+func FuzzDecodeLoginMessage(f *testing.F) {
+	f.Add([]byte("\x05Alice"))
+	f.Add([]byte{0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := DecodeLoginMessage(data)
+		if err != nil {
+			return
+		}
+		if len(m.Nickname) != len(data)-1 || !utf8.ValidString(m.Nickname) {
+			t.Fatalf("unexpected nickname %q", m.Nickname)
+		}
+	})
+}
+
+func FuzzDecodeChatMessage(f *testing.F) {
+	seed, _ := EncodeChatMessage(&ChatMessage{Type: ChatMessageTypeWhisper, Message: "hello", To: "Bob"})
+	f.Add(seed)
+	f.Add([]byte("\x00\x02hi"))
+	f.Add([]byte("\x04\x02hi\x03Bob"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := DecodeChatMessage(data)
+		if err != nil {
+			return
+		}
+		if len(m.Message) > 255 || len(m.To) > 255 {
+			t.Fatalf("message or recipient too long: %d, %d", len(m.Message), len(m.To))
+		}
+		if !utf8.ValidString(m.Message) || !utf8.ValidString(m.To) {
+			t.Fatal("accepted invalid UTF-8")
+		}
+	})
+}
+
+func FuzzDecodePlayerMovementRequest(f *testing.F) {
+	valid := make([]byte, 12)
+	binary.LittleEndian.PutUint32(valid, math.Float32bits(1))
+	binary.LittleEndian.PutUint32(valid[4:], math.Float32bits(-0.5))
+	binary.LittleEndian.PutUint32(valid[8:], math.Float32bits(42))
+	f.Add(valid)
+	nan := bytes.Clone(valid)
+	binary.LittleEndian.PutUint32(nan, math.Float32bits(float32(math.NaN())))
+	f.Add(nan)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := DecodePlayerMovementRequest(data)
+		if err != nil {
+			return
+		}
+		for _, v := range []float32{m.DirX, m.DirY, m.Timestamp} {
+			if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+				t.Fatalf("accepted %v", v)
+			}
+		}
+		if math.Abs(float64(m.DirX)) > 1 || math.Abs(float64(m.DirY)) > 1 {
+			t.Fatalf("accepted direction %v, %v", m.DirX, m.DirY)
+		}
+	})
+}
+
+func FuzzDecodePlayerDataRequest(f *testing.F) {
+	f.Add([]byte{1, 0, 0, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if _, err := DecodePlayerDataRequest(data); err == nil && len(data) != 4 {
+			t.Fatalf("accepted %d bytes", len(data))
+		}
+	})
+}
+
+func FuzzDecodeChunkRequest(f *testing.F) {
+	f.Add([]byte{1, 0, 2, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if _, err := DecodeChunkRequest(data); err == nil && len(data) != 4 {
+			t.Fatalf("accepted %d bytes", len(data))
+		}
+	})
+}
+
+func FuzzDecodeResumeRequest(f *testing.F) {
+	f.Add(make([]byte, SessionTokenSize))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		token, err := DecodeResumeRequest(data)
+		if err == nil && !bytes.Equal(token[:], data) {
+			t.Fatalf("token %v does not match %v", token, data)
+		}
+	})
+}
+
+func FuzzParseDatagram(f *testing.F) {
+	var key [DatagramKeySize]byte
+	signed, encrypted := NewSignedCodec(key), NewEncryptedCodec(key)
+	f.Add(signed.SealClient(7, 1, []byte("payload")))
+	f.Add(encrypted.SealClient(7, 1, []byte("payload")))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d, err := ParseDatagram(data)
+		if err != nil {
+			return
+		}
+		// A datagram only opens with the codec that sealed it
+		for _, codec := range []DatagramCodec{signed, encrypted} {
+			payload, err := codec.OpenClient(d)
+			if err != nil {
+				continue
+			}
+			if sealed := codec.SealClient(d.ConnectionID, d.Sequence, payload); !bytes.Equal(sealed, data) {
+				t.Fatalf("opened a datagram the codec would not have sealed: %v", data)
+			}
+		}
+	})
+}
@@ -0,0 +1,29 @@
+package binary
+
+import "projectt/types"
+
+// MaxFrameSize is the largest TCP frame a client may send. Longer frames
+// are refused before they are read.
+const MaxFrameSize = 1024
+
+// rawOverhead is the size of the type and length fields of a raw message
+const rawOverhead = 1 + 4 + 2
+
+// maxMessageSizes limits the raw size of each message type a client may
+// send. Clients never send an error text, so only the data is accounted.
+var maxMessageSizes = map[types.MessageType]int{
+	types.LoginMessage:          rawOverhead + 1 + 255,
+	types.ChatMessage:           rawOverhead + 1 + (1 + 255) + (1 + 255),
+	types.PlayerMovementMessage: rawOverhead + 12,
+	types.PlayerDataMessage:     rawOverhead + 4,
+	types.ChunkRequestMessage:   rawOverhead + 4,
+	types.DisconnectMessage:     rawOverhead,
+	types.PingPongMessage:       rawOverhead + 64,
+	types.SessionMessage:        rawOverhead + SessionTokenSize,
+}
+
+// MaxMessageSize returns the largest raw message of type t a client may
+// send, or 0 if clients must not send it at all
+func MaxMessageSize(t types.MessageType) int {
+	return maxMessageSizes[t]
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"unicode/utf8"
 )
 
 type LoginRequest struct {
@@ -36,6 +37,12 @@ func DecodeLoginMessage(data []byte) (*LoginRequest, error) {
 		return nil, err
 	}
 	m.Nickname = string(nickBytes)
+	if buf.Len() > 0 {
+		return nil, fmt.Errorf("unexpected data after nickname")
+	}
+	if !utf8.ValidString(m.Nickname) {
+		return nil, fmt.Errorf("nickname is not valid UTF-8")
+	}
 
 	return m, nil
 }
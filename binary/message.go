@@ -3,6 +3,7 @@ package binary
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"projectt/types"
 )
@@ -55,6 +56,10 @@ func DecodeRawMessage(data []byte) (*Message, error) {
 	if err := binary.Read(buf, binary.LittleEndian, &dataLen); err != nil {
 		return nil, err
 	}
+	// Lengths come from the client, check them before allocating
+	if int64(dataLen) > int64(buf.Len()) {
+		return nil, fmt.Errorf("data length %d exceeds message", dataLen)
+	}
 
 	dataBytes := make([]byte, dataLen)
 	if _, err := io.ReadFull(buf, dataBytes); err != nil {
@@ -65,6 +70,9 @@ func DecodeRawMessage(data []byte) (*Message, error) {
 	if err := binary.Read(buf, binary.LittleEndian, &errorLen); err != nil {
 		return nil, err
 	}
+	if int(errorLen) != buf.Len() {
+		return nil, fmt.Errorf("error length %d does not match message", errorLen)
+	}
 
 	errorBytes := make([]byte, errorLen)
 	if _, err := io.ReadFull(buf, errorBytes); err != nil {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"math"
 )
 
 type Player struct {
@@ -23,7 +24,9 @@ type Player struct {
 
 type PlayerMovementRequest struct {
 	DirX, DirY float32
-	Timestamp  float32
+	// Client clock in ticks, continued from the tick in the welcome message.
+	// It may not run ahead of the server, see anticheat.Validator.
+	Timestamp float32
 }
 
 type PlayerDataRequest struct {
@@ -37,6 +40,7 @@ type PlayerMovementData struct {
 	Speed            float32
 	IsMoving         bool
 	LastUpdatedTicks float32
+	Tick             uint32 // Server tick this state belongs to
 }
 
 func EncodePlayer(p *Player) ([]byte, error) {
@@ -83,13 +87,14 @@ func EncodePlayerMovementData(m *PlayerMovementData) []byte {
 	binary.Write(buf, binary.LittleEndian, m.Speed)
 	binary.Write(buf, binary.LittleEndian, m.IsMoving)
 	binary.Write(buf, binary.LittleEndian, m.LastUpdatedTicks)
+	binary.Write(buf, binary.LittleEndian, m.Tick)
 
 	return buf.Bytes()
 }
 
 func DecodePlayerMovementRequest(data []byte) (*PlayerMovementRequest, error) {
-	if len(data) < 4 { // minimum 2 + 2 byte
-		return nil, fmt.Errorf("data too short")
+	if len(data) != 12 { // 4 + 4 + 4 byte
+		return nil, fmt.Errorf("invalid movement request length %d", len(data))
 	}
 
 	buf := bytes.NewReader(data)
@@ -110,12 +115,24 @@ func DecodePlayerMovementRequest(data []byte) (*PlayerMovementRequest, error) {
 		return nil, err
 	}
 
+	if !isFinite(m.DirX) || !isFinite(m.DirY) || !isFinite(m.Timestamp) {
+		return nil, fmt.Errorf("movement request contains NaN or Inf")
+	}
+	// A direction is at most a unit vector per axis
+	if m.DirX < -1 || m.DirX > 1 || m.DirY < -1 || m.DirY > 1 {
+		return nil, fmt.Errorf("direction out of range")
+	}
+
 	return m, nil
 }
 
+func isFinite(f float32) bool {
+	return !math.IsNaN(float64(f)) && !math.IsInf(float64(f), 0)
+}
+
 func DecodePlayerDataRequest(data []byte) (*PlayerDataRequest, error) {
-	if len(data) < 4 { // minimum 2 + 2 byte
-		return nil, fmt.Errorf("data too short")
+	if len(data) != 4 {
+		return nil, fmt.Errorf("invalid player data request length %d", len(data))
 	}
 
 	buf := bytes.NewReader(data)
@@ -0,0 +1,37 @@
+package binary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// SessionTokenSize is the length of a resume token in bytes
+const SessionTokenSize = 16
+
+// SessionMessage is sent by the server after login and after every resume.
+// A client whose connection dropped sends the token alone as the first
+// message on a new connection to take its session back.
+type SessionMessage struct {
+	ConnectionID uint32                 // 4 byte, the connection ID to use for UDP
+	Token        [SessionTokenSize]byte // 16 byte, valid for a single resume
+}
+
+func EncodeSessionMessage(m *SessionMessage) []byte {
+	buf := new(bytes.Buffer)
+
+	binary.Write(buf, binary.LittleEndian, m.ConnectionID)
+	buf.Write(m.Token[:])
+
+	return buf.Bytes()
+}
+
+// DecodeResumeRequest reads the token a client sends to resume its session
+func DecodeResumeRequest(data []byte) ([SessionTokenSize]byte, error) {
+	var token [SessionTokenSize]byte
+	if len(data) != SessionTokenSize {
+		return token, fmt.Errorf("invalid token length %d", len(data))
+	}
+	copy(token[:], data)
+	return token, nil
+}
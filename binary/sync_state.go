@@ -3,12 +3,22 @@ package binary
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+	"math"
 )
 
 type SyncStateData struct {
 	Players     []*Player
 	Countries   []Country
 	OnlineCount int
+	ChatHistory []ChatHistoryEntry
+	Entities    []Entity // units, soldiers and projectiles in view
+}
+
+// ChatHistoryEntry is a chat message sent before the player joined
+type ChatHistoryEntry struct {
+	SentAt  int64 // 8 byte (Unix milliseconds)
+	Message ChatMessage
 }
 
 func EncodeSyncStateData(m *SyncStateData) ([]byte, error) {
@@ -39,5 +49,28 @@ func EncodeSyncStateData(m *SyncStateData) ([]byte, error) {
 
 	buf.WriteByte(uint8(m.OnlineCount))
 
+	// Chat history, oldest first
+	if len(m.ChatHistory) > 255 {
+		return nil, fmt.Errorf("chat history too long")
+	}
+	buf.WriteByte(uint8(len(m.ChatHistory)))
+	for _, entry := range m.ChatHistory {
+		chatBytes, err := EncodeChatMessage(&entry.Message)
+		if err != nil {
+			return nil, err
+		}
+		binary.Write(buf, binary.LittleEndian, entry.SentAt)
+		buf.Write(chatBytes)
+	}
+
+	// Entities
+	if len(m.Entities) > math.MaxUint16 {
+		return nil, fmt.Errorf("too many entities")
+	}
+	binary.Write(buf, binary.LittleEndian, uint16(len(m.Entities)))
+	for _, entity := range m.Entities {
+		buf.Write(EncodeEntity(&entity))
+	}
+
 	return buf.Bytes(), nil
 }
@@ -6,13 +6,24 @@ import (
 )
 
 type WelcomeMessage struct {
-	ConnectionID uint32
+	ConnectionID   uint32
+	TicksPerSecond uint16 // Server simulation rate
+	Tick           uint32 // Current server tick
+	// Key protecting the UDP datagrams, see DatagramCodec
+	SessionKey [DatagramKeySize]byte
+	// EncryptedUDP is set on TLS connections, whose datagrams use
+	// NewEncryptedCodec instead of NewSignedCodec
+	EncryptedUDP bool
 }
 
 func EncodeWelcomeMessage(m *WelcomeMessage) []byte {
 	buf := new(bytes.Buffer)
 
 	binary.Write(buf, binary.LittleEndian, m.ConnectionID)
+	binary.Write(buf, binary.LittleEndian, m.TicksPerSecond)
+	binary.Write(buf, binary.LittleEndian, m.Tick)
+	buf.Write(m.SessionKey[:])
+	binary.Write(buf, binary.LittleEndian, m.EncryptedUDP)
 
 	return buf.Bytes()
 }
@@ -1,96 +1,348 @@
 package config
 
 import (
-	"database/sql"
 	"fmt"
-	"log"
-	"os"
-	"strconv"
+	"net"
 	"time"
-
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 )
 
-var (
-	DB *gorm.DB
+// Config holds all server settings. It is built by Load from defaults, an
+// optional YAML file, environment variables and command line flags.
+type Config struct {
+	App         AppConfig         `yaml:"app"`
+	Database    DatabaseConfig    `yaml:"database"`
+	Game        GameConfig        `yaml:"game"`
+	Metrics     MetricsConfig     `yaml:"metrics"`
+	Admin       AdminConfig       `yaml:"admin"`
+	Chat        ChatConfig        `yaml:"chat"`
+	TLS         TLSConfig         `yaml:"tls"`
+	Limits      LimitsConfig      `yaml:"limits"`
+	AntiCheat   AntiCheatConfig   `yaml:"anticheat"`
+	Pathfinding PathfindingConfig `yaml:"pathfinding"`
+	AI          AIConfig          `yaml:"ai"`
+}
 
-	MaxPlayers           int
-	ChunkSize            int
-	MaxChunkViewDistance int
-	MaxViewDistance      int
-	TicksPerSecond       int
-	FixedDeltaTime       time.Duration
-)
+type AppConfig struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+	Env  string `yaml:"env"`
+	// Players are warned for ShutdownCountdown before the server stops, which
+	// then has ShutdownTimeout to disconnect clients and save
+	ShutdownCountdown time.Duration `yaml:"shutdown_countdown"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+}
 
-func Init() {
-	var err error
+type DatabaseConfig struct {
+	// Storage backend: postgres, sqlite or memory
+	Driver     string `yaml:"driver"`
+	Host       string `yaml:"host"`
+	User       string `yaml:"user"`
+	Password   string `yaml:"password"`
+	Name       string `yaml:"name"`
+	Port       int    `yaml:"port"`
+	SQLitePath string `yaml:"sqlite_path"`
+}
 
-	// Load game settings from environment variables
-	MaxPlayers, err = strconv.Atoi(os.Getenv("MAX_PLAYERS"))
-	if err != nil {
-		log.Fatalf("Invalid MAX_PLAYERS value: %v", err)
+type GameConfig struct {
+	MaxPlayers           int `yaml:"max_players"`
+	ChunkSize            int `yaml:"chunk_size"`
+	MaxChunkViewDistance int `yaml:"max_chunk_view_distance"`
+	// Number of simulation steps per second of real time
+	TicksPerSecond int `yaml:"ticks_per_second"`
+	WorldWidth     int `yaml:"world_width"`
+	WorldHeight    int `yaml:"world_height"`
+	// How long a player whose connection dropped stays in the world and may
+	// resume the session, 0 to remove players immediately
+	ResumeGracePeriod time.Duration `yaml:"resume_grace_period"`
+}
+
+type MetricsConfig struct {
+	// Address of the Prometheus /metrics endpoint, empty to disable
+	Addr string `yaml:"addr"`
+}
+
+type AdminConfig struct {
+	// Address of the admin HTTP API, empty to disable
+	Addr string `yaml:"addr"`
+	// Bearer token required by the admin API. May only be empty when the
+	// API is bound to a loopback address.
+	Token string `yaml:"token"`
+}
+
+type ChatConfig struct {
+	// Sustained messages per second a player may send, and the burst
+	// allowed on top of it
+	RateLimit float64 `yaml:"rate_limit"`
+	RateBurst int     `yaml:"rate_burst"`
+	// The same message may be sent at most MaxRepeats times in a row while
+	// less than RepeatWindow passes between them. 0 disables the check.
+	MaxRepeats   int           `yaml:"max_repeats"`
+	RepeatWindow time.Duration `yaml:"repeat_window"`
+	// Words masked with asterisks in player messages
+	FilteredWords []string `yaml:"filtered_words"`
+	// Number of general and of country messages sent to joining players,
+	// and how long messages are kept
+	HistorySize      int           `yaml:"history_size"`
+	HistoryRetention time.Duration `yaml:"history_retention"`
+	// Whispers queued for an offline player before further ones are refused
+	MaxOfflineMessages int `yaml:"max_offline_messages"`
+}
+
+type TLSConfig struct {
+	// Serve the game TCP channel over TLS. UDP datagrams are then encrypted
+	// with a key sent over it.
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// Generate a throwaway self-signed certificate at startup instead of
+	// loading one, for development
+	SelfSigned bool `yaml:"self_signed"`
+}
+
+// LimitsConfig protects the server from floods. Each rate is per second and
+// may be exceeded by the burst.
+type LimitsConfig struct {
+	// Datagrams accepted from all clients together
+	UDPPacketRate  float64 `yaml:"udp_packet_rate"`
+	UDPPacketBurst int     `yaml:"udp_packet_burst"`
+	// New TCP connections accepted from a single IP address
+	ConnectionRate  float64 `yaml:"connection_rate"`
+	ConnectionBurst int     `yaml:"connection_burst"`
+}
+
+// AntiCheatConfig controls movement validation. Suspicious movement adds to
+// a per-player score that decays by ScoreDecay points per second.
+type AntiCheatConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// How far a client's movement timestamps may run ahead of the server tick
+	MaxClockDrift time.Duration `yaml:"max_clock_drift"`
+	ScoreDecay    float64       `yaml:"score_decay"`
+	// Moderators are alerted when a score reaches FlagScore and the player
+	// is kicked at KickScore, 0 disables either
+	FlagScore float64 `yaml:"flag_score"`
+	KickScore float64 `yaml:"kick_score"`
+}
+
+// PathfindingConfig bounds the route searches of units moving on their own.
+// Routes are planned on the same chunks the map is sent in.
+type PathfindingConfig struct {
+	// Tiles or chunk regions a single search may expand before it gives up
+	MaxNodes int `yaml:"max_nodes"`
+	// Routes whose ends are at least this many chunks apart are planned on
+	// chunks first
+	HierarchyDistance int `yaml:"hierarchy_distance"`
+	// Found paths kept until the map changes, 0 to disable caching
+	CacheSize int `yaml:"cache_size"`
+}
+
+// AIConfig controls the countries played by the server, those marked as AI
+// controlled
+type AIConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// easy, normal or hard
+	Difficulty string `yaml:"difficulty"`
+	// Time between two decisions of an AI country
+	ThinkInterval time.Duration `yaml:"think_interval"`
+	// Time all AI countries together may spend deciding per tick
+	TickBudget time.Duration `yaml:"tick_budget"`
+	// Units occupy an enemy tile by holding it uncontested this long
+	CaptureTime time.Duration `yaml:"capture_time"`
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	return &Config{
+		App: AppConfig{
+			Host: "localhost",
+			Port: 8080,
+			Env:  "development",
+
+			ShutdownCountdown: 10 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Driver:     "postgres",
+			Host:       "localhost",
+			User:       "postgres",
+			Name:       "projecttdb",
+			Port:       5432,
+			SQLitePath: "projectt.db",
+		},
+		Game: GameConfig{
+			MaxPlayers:           100,
+			ChunkSize:            16,
+			MaxChunkViewDistance: 4,
+			TicksPerSecond:       60,
+			WorldWidth:           8192,
+			WorldHeight:          4096,
+			ResumeGracePeriod:    30 * time.Second,
+		},
+		Metrics: MetricsConfig{
+			Addr: ":9100",
+		},
+		Admin: AdminConfig{
+			Addr: "127.0.0.1:8081",
+		},
+		Chat: ChatConfig{
+			RateLimit:    1,
+			RateBurst:    5,
+			MaxRepeats:   2,
+			RepeatWindow: 30 * time.Second,
+
+			HistorySize:        20,
+			HistoryRetention:   7 * 24 * time.Hour,
+			MaxOfflineMessages: 50,
+		},
+		Limits: LimitsConfig{
+			UDPPacketRate:   20000,
+			UDPPacketBurst:  40000,
+			ConnectionRate:  0.5,
+			ConnectionBurst: 10,
+		},
+		AntiCheat: AntiCheatConfig{
+			Enabled:       true,
+			MaxClockDrift: 500 * time.Millisecond,
+			ScoreDecay:    1,
+			FlagScore:     50,
+			KickScore:     100,
+		},
+		Pathfinding: PathfindingConfig{
+			MaxNodes:          200000,
+			HierarchyDistance: 4,
+			CacheSize:         1024,
+		},
+		AI: AIConfig{
+			Enabled:       true,
+			Difficulty:    "normal",
+			ThinkInterval: 500 * time.Millisecond,
+			TickBudget:    2 * time.Millisecond,
+			CaptureTime:   10 * time.Second,
+		},
 	}
+}
 
-	ChunkSize, err = strconv.Atoi(os.Getenv("CHUNK_SIZE"))
-	if err != nil {
-		log.Fatalf("Invalid CHUNK_SIZE value: %v", err)
+// Validate checks that the settings are usable together
+func (c *Config) Validate() error {
+	if c.App.Port < 0 || c.App.Port > 65535 {
+		return fmt.Errorf("app.port must be between 0 and 65535, got %d", c.App.Port)
+	}
+	if c.App.ShutdownCountdown < 0 || c.App.ShutdownTimeout <= 0 {
+		return fmt.Errorf("app.shutdown_countdown must not be negative and app.shutdown_timeout must be positive")
 	}
 
-	MaxChunkViewDistance, err = strconv.Atoi(os.Getenv("MAX_CHUNK_VIEW_DISTANCE"))
-	if err != nil {
-		log.Fatalf("Invalid MAX_CHUNK_VIEW_DISTANCE value: %v", err)
+	switch c.Database.Driver {
+	case "postgres", "sqlite", "memory":
+	default:
+		return fmt.Errorf("database.driver must be postgres, sqlite or memory, got %q", c.Database.Driver)
+	}
+	if c.Database.Driver == "sqlite" && c.Database.SQLitePath == "" {
+		return fmt.Errorf("database.sqlite_path is required for the sqlite driver")
 	}
 
-	MaxViewDistance = ChunkSize * MaxChunkViewDistance
+	g := c.Game
+	if g.MaxPlayers < 1 {
+		return fmt.Errorf("game.max_players must be positive, got %d", g.MaxPlayers)
+	}
+	if g.WorldWidth < 1 || g.WorldHeight < 1 || g.WorldWidth > 65535 || g.WorldHeight > 65535 {
+		return fmt.Errorf("game.world_width and game.world_height must be between 1 and 65535")
+	}
+	// Chunk packets always carry 16x16 tiles
+	if g.ChunkSize != 16 {
+		return fmt.Errorf("game.chunk_size must be 16, got %d", g.ChunkSize)
+	}
+	if g.WorldWidth%g.ChunkSize != 0 || g.WorldHeight%g.ChunkSize != 0 {
+		return fmt.Errorf("game.chunk_size %d must divide the world size %dx%d", g.ChunkSize, g.WorldWidth, g.WorldHeight)
+	}
+	if g.MaxChunkViewDistance < 1 {
+		return fmt.Errorf("game.max_chunk_view_distance must be positive, got %d", g.MaxChunkViewDistance)
+	}
+	if g.TicksPerSecond < 1 || g.TicksPerSecond > 128 {
+		return fmt.Errorf("game.ticks_per_second must be between 1 and 128, got %d", g.TicksPerSecond)
+	}
+	if g.ResumeGracePeriod < 0 {
+		return fmt.Errorf("game.resume_grace_period must not be negative")
+	}
 
-	TicksPerSecond, err = strconv.Atoi(os.Getenv("TICKS_PER_SECOND"))
-	if err != nil {
-		log.Fatalf("Invalid TICKS_PER_SECOND value: %v", err)
+	if c.Admin.Addr != "" && c.Admin.Token == "" && !isLoopback(c.Admin.Addr) {
+		return fmt.Errorf("admin.token is required when the admin API is not bound to localhost")
 	}
 
-	FixedDeltaTime = time.Duration(TicksPerSecond) * time.Millisecond
-}
+	if c.Chat.RateLimit <= 0 || c.Chat.RateBurst < 1 {
+		return fmt.Errorf("chat.rate_limit and chat.rate_burst must be positive")
+	}
+	if c.Chat.MaxRepeats < 0 || c.Chat.RepeatWindow < 0 {
+		return fmt.Errorf("chat.max_repeats and chat.repeat_window must not be negative")
+	}
+	// Both histories share one count byte in the sync state
+	if c.Chat.HistorySize < 0 || c.Chat.HistorySize > 100 {
+		return fmt.Errorf("chat.history_size must be between 0 and 100, got %d", c.Chat.HistorySize)
+	}
+	if c.Chat.HistoryRetention <= 0 {
+		return fmt.Errorf("chat.history_retention must be positive")
+	}
+	if c.Chat.MaxOfflineMessages < 0 {
+		return fmt.Errorf("chat.max_offline_messages must not be negative")
+	}
 
-func ConnectDatabase() {
-	host := os.Getenv("DB_HOST")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
-	dbname := os.Getenv("DB_NAME")
-	port := os.Getenv("DB_PORT")
+	if c.Limits.UDPPacketRate <= 0 || c.Limits.UDPPacketBurst < 1 {
+		return fmt.Errorf("limits.udp_packet_rate and limits.udp_packet_burst must be positive")
+	}
+	if c.Limits.ConnectionRate <= 0 || c.Limits.ConnectionBurst < 1 {
+		return fmt.Errorf("limits.connection_rate and limits.connection_burst must be positive")
+	}
 
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
-		host,
-		user,
-		password,
-		dbname,
-		port,
-	)
+	if c.AntiCheat.MaxClockDrift <= 0 {
+		return fmt.Errorf("anticheat.max_clock_drift must be positive")
+	}
+	if c.AntiCheat.ScoreDecay < 0 || c.AntiCheat.FlagScore < 0 || c.AntiCheat.KickScore < 0 {
+		return fmt.Errorf("anticheat.score_decay, anticheat.flag_score and anticheat.kick_score must not be negative")
+	}
 
-	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+	if c.Pathfinding.MaxNodes < 1 || c.Pathfinding.HierarchyDistance < 1 {
+		return fmt.Errorf("pathfinding.max_nodes and pathfinding.hierarchy_distance must be positive")
+	}
+	if c.Pathfinding.CacheSize < 0 {
+		return fmt.Errorf("pathfinding.cache_size must not be negative")
 	}
 
-	// Connection pool configuration
-	sqlDB, err := database.DB()
-	if err != nil {
-		log.Fatal("Failed to get database object:", err)
+	switch c.AI.Difficulty {
+	case "easy", "normal", "hard":
+	default:
+		return fmt.Errorf("ai.difficulty must be easy, normal or hard, got %q", c.AI.Difficulty)
+	}
+	if c.AI.ThinkInterval <= 0 || c.AI.TickBudget <= 0 || c.AI.CaptureTime <= 0 {
+		return fmt.Errorf("ai.think_interval, ai.tick_budget and ai.capture_time must be positive")
 	}
 
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	if c.TLS.Enabled && !c.TLS.SelfSigned && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		return fmt.Errorf("tls.cert_file and tls.key_file are required unless tls.self_signed is set")
+	}
+	if c.TLS.Enabled && c.TLS.SelfSigned && c.App.Env == "production" {
+		return fmt.Errorf("tls.self_signed must not be used in production")
+	}
 
-	DB = database
+	return nil
 }
 
-func GetDBStats() sql.DBStats {
-	sqlDB, err := DB.DB()
+// isLoopback reports whether addr (host:port) only accepts local connections
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
 	if err != nil {
-		log.Printf("Error getting database instance: %v", err)
-		return sql.DBStats{}
+		return false
 	}
-	return sqlDB.Stats()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// MaxViewDistance returns the view distance in tiles
+func (g GameConfig) MaxViewDistance() int {
+	return g.ChunkSize * g.MaxChunkViewDistance
+}
+
+// FixedDeltaTime returns the duration of a single simulation tick
+func (g GameConfig) FixedDeltaTime() time.Duration {
+	return time.Second / time.Duration(g.TicksPerSecond)
 }
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	file := "app:\n  port: 9000\ngame:\n  max_players: 50\n  ticks_per_second: 30\n"
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MAX_PLAYERS", "75")
+	t.Setenv("TICKS_PER_SECOND", "40")
+
+	cfg, err := Load([]string{"-config", path, "-ticks-per-second", "20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.App.Port != 9000 {
+		t.Errorf("expected port from file, got %d", cfg.App.Port)
+	}
+	if cfg.Game.MaxPlayers != 75 {
+		t.Errorf("expected max players from env, got %d", cfg.Game.MaxPlayers)
+	}
+	if cfg.Game.TicksPerSecond != 20 {
+		t.Errorf("expected tick rate from flags, got %d", cfg.Game.TicksPerSecond)
+	}
+	if cfg.Game.ChunkSize != 16 {
+		t.Errorf("expected default chunk size, got %d", cfg.Game.ChunkSize)
+	}
+}
+
+func TestLoadChatSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	file := "chat:\n  repeat_window: 1m\n  filtered_words: [darn]\n"
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CHAT_FILTERED_WORDS", "heck, darn ,")
+
+	cfg, err := Load([]string{"-config", path, "-chat-rate-limit", "0.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Chat.RepeatWindow != time.Minute {
+		t.Errorf("expected repeat window from file, got %v", cfg.Chat.RepeatWindow)
+	}
+	if strings.Join(cfg.Chat.FilteredWords, ",") != "heck,darn" {
+		t.Errorf("expected filtered words from env, got %q", cfg.Chat.FilteredWords)
+	}
+	if cfg.Chat.RateLimit != 0.5 {
+		t.Errorf("expected rate limit from flags, got %v", cfg.Chat.RateLimit)
+	}
+}
+
+func TestLoadRejectsUnknownFileKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("game:\n  max_player: 5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load([]string{"-config", path}); err == nil {
+		t.Fatal("expected an error for an unknown key")
+	}
+}
+
+func TestLoadRejectsInvalidEnv(t *testing.T) {
+	t.Setenv("MAX_PLAYERS", "lots")
+
+	if _, err := Load(nil); err == nil || !strings.Contains(err.Error(), "MAX_PLAYERS") {
+		t.Fatalf("expected MAX_PLAYERS error, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]func(c *Config){
+		"chunk size":     func(c *Config) { c.Game.ChunkSize = 10 },
+		"world size":     func(c *Config) { c.Game.WorldWidth = 8200 },
+		"tick rate low":  func(c *Config) { c.Game.TicksPerSecond = 0 },
+		"tick rate high": func(c *Config) { c.Game.TicksPerSecond = 1000 },
+		"driver":         func(c *Config) { c.Database.Driver = "mysql" },
+		"max players":    func(c *Config) { c.Game.MaxPlayers = 0 },
+		"admin token":    func(c *Config) { c.Admin.Addr = ":8081" },
+		"chat rate":      func(c *Config) { c.Chat.RateLimit = 0 },
+		"shutdown":       func(c *Config) { c.App.ShutdownTimeout = 0 },
+		"resume grace":   func(c *Config) { c.Game.ResumeGracePeriod = -time.Second },
+		"udp budget":     func(c *Config) { c.Limits.UDPPacketRate = 0 },
+		"connection":     func(c *Config) { c.Limits.ConnectionBurst = 0 },
+		"tls cert":       func(c *Config) { c.TLS.Enabled = true },
+		"tls prod":       func(c *Config) { c.TLS.Enabled, c.TLS.SelfSigned, c.App.Env = true, true, "production" },
+		"clock drift":    func(c *Config) { c.AntiCheat.MaxClockDrift = 0 },
+		"kick score":     func(c *Config) { c.AntiCheat.KickScore = -1 },
+		"path nodes":     func(c *Config) { c.Pathfinding.MaxNodes = 0 },
+		"ai difficulty":  func(c *Config) { c.AI.Difficulty = "insane" },
+		"ai budget":      func(c *Config) { c.AI.TickBudget = 0 },
+	}
+
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("default config is invalid: %v", err)
+	}
+	for name, mutate := range tests {
+		cfg := Default()
+		mutate(cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestDumpRedactsPassword(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Password = "hunter2"
+
+	var out strings.Builder
+	if err := cfg.Dump(&out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "hunter2") {
+		t.Fatal("password was not redacted")
+	}
+	if !strings.Contains(out.String(), "max_players: 100") {
+		t.Fatalf("unexpected dump:\n%s", out.String())
+	}
+}
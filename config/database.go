@@ -0,0 +1,70 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// ConnectDatabase opens the Postgres database described by the config
+func ConnectDatabase(c DatabaseConfig) (*gorm.DB, error) {
+	dsn := fmt.Sprintf(
+		"host=%s user=%s password=%s dbname=%s port=%d sslmode=disable TimeZone=UTC",
+		c.Host,
+		c.User,
+		c.Password,
+		c.Name,
+		c.Port,
+	)
+
+	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+
+	// Connection pool configuration
+	sqlDB, err := database.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database object: %v", err)
+	}
+
+	sqlDB.SetMaxIdleConns(10)
+	sqlDB.SetMaxOpenConns(100)
+	sqlDB.SetConnMaxLifetime(time.Hour)
+
+	return database, nil
+}
+
+// ConnectSQLite opens the SQLite database file described by the config
+func ConnectSQLite(c DatabaseConfig) (*gorm.DB, error) {
+	database, err := gorm.Open(sqlite.Open(c.SQLitePath), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %v", err)
+	}
+
+	// SQLite allows a single writer at a time
+	sqlDB, err := database.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database object: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	return database, nil
+}
+
+func GetDBStats(db *gorm.DB) sql.DBStats {
+	if db == nil {
+		return sql.DBStats{}
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Printf("Error getting database instance: %v", err)
+		return sql.DBStats{}
+	}
+	return sqlDB.Stats()
+}
@@ -0,0 +1,287 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration from, in increasing precedence: built-in
+// defaults, the YAML file given with -config (or CONFIG_FILE), environment
+// variables and command line flags. The result is validated.
+func Load(args []string) (*Config, error) {
+	// First pass only discovers the config file; flag values are applied last
+	scratch := Default()
+	fs := newFlagSet(scratch)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	path := fs.Lookup("config").Value.String()
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	cfg := Default()
+	if path != "" {
+		if err := loadFile(cfg, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := loadEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	// Second pass writes explicitly set flags over file and env values
+	if err := newFlagSet(cfg).Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// DumpRequested reports whether -dump-config was passed
+func DumpRequested(args []string) bool {
+	fs := newFlagSet(Default())
+	if err := fs.Parse(args); err != nil {
+		return false
+	}
+	return fs.Lookup("dump-config").Value.String() == "true"
+}
+
+// Dump writes the effective configuration as YAML, with secrets redacted
+func (c *Config) Dump(w io.Writer) error {
+	redacted := *c
+	if redacted.Database.Password != "" {
+		redacted.Database.Password = "********"
+	}
+	if redacted.Admin.Token != "" {
+		redacted.Admin.Token = "********"
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&redacted); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func newFlagSet(cfg *Config) *flag.FlagSet {
+	fs := flag.NewFlagSet("projectt", flag.ContinueOnError)
+	fs.String("config", "", "path to a YAML config file")
+	fs.Bool("dump-config", false, "print the effective configuration and exit")
+
+	fs.StringVar(&cfg.App.Host, "host", cfg.App.Host, "public host name")
+	fs.IntVar(&cfg.App.Port, "port", cfg.App.Port, "TCP and UDP port")
+	fs.StringVar(&cfg.App.Env, "env", cfg.App.Env, "environment name")
+	fs.DurationVar(&cfg.App.ShutdownCountdown, "shutdown-countdown", cfg.App.ShutdownCountdown, "warning time given to players before shutdown")
+	fs.DurationVar(&cfg.App.ShutdownTimeout, "shutdown-timeout", cfg.App.ShutdownTimeout, "time allowed to disconnect clients and save on shutdown")
+
+	fs.StringVar(&cfg.Database.Driver, "db-driver", cfg.Database.Driver, "storage backend: postgres, sqlite or memory")
+	fs.StringVar(&cfg.Database.Host, "db-host", cfg.Database.Host, "database host")
+	fs.StringVar(&cfg.Database.User, "db-user", cfg.Database.User, "database user")
+	fs.StringVar(&cfg.Database.Password, "db-password", cfg.Database.Password, "database password")
+	fs.StringVar(&cfg.Database.Name, "db-name", cfg.Database.Name, "database name")
+	fs.IntVar(&cfg.Database.Port, "db-port", cfg.Database.Port, "database port")
+	fs.StringVar(&cfg.Database.SQLitePath, "sqlite-path", cfg.Database.SQLitePath, "SQLite database file")
+
+	fs.IntVar(&cfg.Game.MaxPlayers, "max-players", cfg.Game.MaxPlayers, "maximum concurrent connections")
+	fs.IntVar(&cfg.Game.ChunkSize, "chunk-size", cfg.Game.ChunkSize, "chunk size in tiles")
+	fs.IntVar(&cfg.Game.MaxChunkViewDistance, "max-chunk-view-distance", cfg.Game.MaxChunkViewDistance, "view distance in chunks")
+	fs.IntVar(&cfg.Game.TicksPerSecond, "ticks-per-second", cfg.Game.TicksPerSecond, "simulation tick rate")
+	fs.DurationVar(&cfg.Game.ResumeGracePeriod, "resume-grace-period", cfg.Game.ResumeGracePeriod, "time a disconnected player may resume the session, 0 to disable")
+
+	fs.StringVar(&cfg.Metrics.Addr, "metrics-addr", cfg.Metrics.Addr, "address of the /metrics endpoint, empty to disable")
+
+	fs.StringVar(&cfg.Admin.Addr, "admin-addr", cfg.Admin.Addr, "address of the admin API, empty to disable")
+	fs.StringVar(&cfg.Admin.Token, "admin-token", cfg.Admin.Token, "bearer token for the admin API")
+
+	fs.Float64Var(&cfg.Chat.RateLimit, "chat-rate-limit", cfg.Chat.RateLimit, "chat messages per second per player")
+	fs.IntVar(&cfg.Chat.RateBurst, "chat-rate-burst", cfg.Chat.RateBurst, "chat messages a player may send in a burst")
+	fs.IntVar(&cfg.Chat.MaxRepeats, "chat-max-repeats", cfg.Chat.MaxRepeats, "times the same chat message may be repeated, 0 to disable")
+	fs.IntVar(&cfg.Chat.HistorySize, "chat-history-size", cfg.Chat.HistorySize, "general and country messages sent to joining players")
+	fs.DurationVar(&cfg.Chat.HistoryRetention, "chat-history-retention", cfg.Chat.HistoryRetention, "how long chat messages are kept")
+	fs.IntVar(&cfg.Chat.MaxOfflineMessages, "chat-max-offline-messages", cfg.Chat.MaxOfflineMessages, "whispers queued per offline player")
+	fs.DurationVar(&cfg.Chat.RepeatWindow, "chat-repeat-window", cfg.Chat.RepeatWindow, "time after which a chat message no longer counts as a repeat")
+
+	fs.BoolVar(&cfg.TLS.Enabled, "tls", cfg.TLS.Enabled, "serve the game channel over TLS and encrypt UDP")
+	fs.StringVar(&cfg.TLS.CertFile, "tls-cert", cfg.TLS.CertFile, "TLS certificate file (PEM)")
+	fs.StringVar(&cfg.TLS.KeyFile, "tls-key", cfg.TLS.KeyFile, "TLS private key file (PEM)")
+	fs.BoolVar(&cfg.TLS.SelfSigned, "tls-self-signed", cfg.TLS.SelfSigned, "generate a self-signed certificate, for development")
+
+	fs.Float64Var(&cfg.Limits.UDPPacketRate, "udp-packet-rate", cfg.Limits.UDPPacketRate, "datagrams per second accepted from all clients")
+	fs.IntVar(&cfg.Limits.UDPPacketBurst, "udp-packet-burst", cfg.Limits.UDPPacketBurst, "datagrams accepted in a burst from all clients")
+	fs.Float64Var(&cfg.Limits.ConnectionRate, "connection-rate", cfg.Limits.ConnectionRate, "new connections per second accepted from one IP")
+	fs.IntVar(&cfg.Limits.ConnectionBurst, "connection-burst", cfg.Limits.ConnectionBurst, "new connections accepted in a burst from one IP")
+
+	fs.BoolVar(&cfg.AntiCheat.Enabled, "anticheat", cfg.AntiCheat.Enabled, "validate player movement")
+	fs.DurationVar(&cfg.AntiCheat.MaxClockDrift, "anticheat-max-clock-drift", cfg.AntiCheat.MaxClockDrift, "how far movement timestamps may run ahead of the server")
+	fs.Float64Var(&cfg.AntiCheat.ScoreDecay, "anticheat-score-decay", cfg.AntiCheat.ScoreDecay, "suspicion points forgiven per second")
+	fs.Float64Var(&cfg.AntiCheat.FlagScore, "anticheat-flag-score", cfg.AntiCheat.FlagScore, "suspicion score at which moderators are alerted, 0 to disable")
+	fs.Float64Var(&cfg.AntiCheat.KickScore, "anticheat-kick-score", cfg.AntiCheat.KickScore, "suspicion score at which players are kicked, 0 to disable")
+
+	fs.IntVar(&cfg.Pathfinding.MaxNodes, "pathfinding-max-nodes", cfg.Pathfinding.MaxNodes, "nodes a route search may expand")
+	fs.IntVar(&cfg.Pathfinding.HierarchyDistance, "pathfinding-hierarchy-distance", cfg.Pathfinding.HierarchyDistance, "distance in chunks from which routes are planned on chunks")
+	fs.IntVar(&cfg.Pathfinding.CacheSize, "pathfinding-cache-size", cfg.Pathfinding.CacheSize, "found paths kept in the cache, 0 to disable")
+
+	fs.BoolVar(&cfg.AI.Enabled, "ai", cfg.AI.Enabled, "play AI controlled countries")
+	fs.StringVar(&cfg.AI.Difficulty, "ai-difficulty", cfg.AI.Difficulty, "AI difficulty: easy, normal or hard")
+	fs.DurationVar(&cfg.AI.ThinkInterval, "ai-think-interval", cfg.AI.ThinkInterval, "time between two decisions of an AI country")
+	fs.DurationVar(&cfg.AI.TickBudget, "ai-tick-budget", cfg.AI.TickBudget, "time the AI may spend deciding per tick")
+	fs.DurationVar(&cfg.AI.CaptureTime, "ai-capture-time", cfg.AI.CaptureTime, "time a unit holds an enemy tile to occupy it")
+
+	return fs
+}
+
+func loadFile(cfg *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %v", err)
+	}
+	defer file.Close()
+
+	dec := yaml.NewDecoder(file)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return nil
+}
+
+func loadEnv(cfg *Config) error {
+	stringVars := map[string]*string{
+		"APP_HOST":       &cfg.App.Host,
+		"APP_ENV":        &cfg.App.Env,
+		"STORAGE_DRIVER": &cfg.Database.Driver,
+		"DB_HOST":        &cfg.Database.Host,
+		"DB_USER":        &cfg.Database.User,
+		"DB_PASSWORD":    &cfg.Database.Password,
+		"DB_NAME":        &cfg.Database.Name,
+		"SQLITE_PATH":    &cfg.Database.SQLitePath,
+		"METRICS_ADDR":   &cfg.Metrics.Addr,
+		"ADMIN_ADDR":     &cfg.Admin.Addr,
+		"ADMIN_TOKEN":    &cfg.Admin.Token,
+		"TLS_CERT_FILE":  &cfg.TLS.CertFile,
+		"TLS_KEY_FILE":   &cfg.TLS.KeyFile,
+		"AI_DIFFICULTY":  &cfg.AI.Difficulty,
+	}
+	for key, field := range stringVars {
+		if value, ok := os.LookupEnv(key); ok {
+			*field = value
+		}
+	}
+
+	intVars := map[string]*int{
+		"APP_PORT":                &cfg.App.Port,
+		"DB_PORT":                 &cfg.Database.Port,
+		"MAX_PLAYERS":             &cfg.Game.MaxPlayers,
+		"CHUNK_SIZE":              &cfg.Game.ChunkSize,
+		"MAX_CHUNK_VIEW_DISTANCE": &cfg.Game.MaxChunkViewDistance,
+		"TICKS_PER_SECOND":        &cfg.Game.TicksPerSecond,
+		"CHAT_RATE_BURST":         &cfg.Chat.RateBurst,
+		"CHAT_MAX_REPEATS":        &cfg.Chat.MaxRepeats,
+		"CHAT_HISTORY_SIZE":       &cfg.Chat.HistorySize,
+		"CHAT_MAX_OFFLINE":        &cfg.Chat.MaxOfflineMessages,
+		"UDP_PACKET_BURST":        &cfg.Limits.UDPPacketBurst,
+		"CONNECTION_BURST":        &cfg.Limits.ConnectionBurst,
+
+		"PATHFINDING_MAX_NODES":          &cfg.Pathfinding.MaxNodes,
+		"PATHFINDING_HIERARCHY_DISTANCE": &cfg.Pathfinding.HierarchyDistance,
+		"PATHFINDING_CACHE_SIZE":         &cfg.Pathfinding.CacheSize,
+	}
+	for key, field := range intVars {
+		value, ok := os.LookupEnv(key)
+		if !ok || value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value: %v", key, err)
+		}
+		*field = parsed
+	}
+
+	floatVars := map[string]*float64{
+		"CHAT_RATE_LIMIT": &cfg.Chat.RateLimit,
+		"UDP_PACKET_RATE": &cfg.Limits.UDPPacketRate,
+		"CONNECTION_RATE": &cfg.Limits.ConnectionRate,
+
+		"ANTICHEAT_SCORE_DECAY": &cfg.AntiCheat.ScoreDecay,
+		"ANTICHEAT_FLAG_SCORE":  &cfg.AntiCheat.FlagScore,
+		"ANTICHEAT_KICK_SCORE":  &cfg.AntiCheat.KickScore,
+	}
+	for key, field := range floatVars {
+		value, ok := os.LookupEnv(key)
+		if !ok || value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s value: %v", key, err)
+		}
+		*field = parsed
+	}
+	durationVars := map[string]*time.Duration{
+		"SHUTDOWN_COUNTDOWN":     &cfg.App.ShutdownCountdown,
+		"SHUTDOWN_TIMEOUT":       &cfg.App.ShutdownTimeout,
+		"RESUME_GRACE_PERIOD":    &cfg.Game.ResumeGracePeriod,
+		"CHAT_REPEAT_WINDOW":     &cfg.Chat.RepeatWindow,
+		"CHAT_HISTORY_RETENTION": &cfg.Chat.HistoryRetention,
+
+		"ANTICHEAT_MAX_CLOCK_DRIFT": &cfg.AntiCheat.MaxClockDrift,
+
+		"AI_THINK_INTERVAL": &cfg.AI.ThinkInterval,
+		"AI_TICK_BUDGET":    &cfg.AI.TickBudget,
+		"AI_CAPTURE_TIME":   &cfg.AI.CaptureTime,
+	}
+	for key, field := range durationVars {
+		value, ok := os.LookupEnv(key)
+		if !ok || value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value: %v", key, err)
+		}
+		*field = parsed
+	}
+	boolVars := map[string]*bool{
+		"TLS_ENABLED":     &cfg.TLS.Enabled,
+		"TLS_SELF_SIGNED": &cfg.TLS.SelfSigned,
+
+		"ANTICHEAT_ENABLED": &cfg.AntiCheat.Enabled,
+		"AI_ENABLED":        &cfg.AI.Enabled,
+	}
+	for key, field := range boolVars {
+		value, ok := os.LookupEnv(key)
+		if !ok || value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value: %v", key, err)
+		}
+		*field = parsed
+	}
+	// Comma separated list
+	if value, ok := os.LookupEnv("CHAT_FILTERED_WORDS"); ok {
+		cfg.Chat.FilteredWords = nil
+		for _, word := range strings.Split(value, ",") {
+			if word = strings.TrimSpace(word); word != "" {
+				cfg.Chat.FilteredWords = append(cfg.Chat.FilteredWords, word)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,397 @@
+// Package ai plays the countries marked as AI controlled.
+//
+// Every AI country has a commander that decides what its units do: it
+// spawns units up to a limit, stations them on the border where enemies
+// gather, sends squads to occupy enemy tiles that are weakly defended and
+// sends units back to tiles the enemy occupied. Commanders see the game
+// only through the World interface and act on it by spawning and moving
+// units. Together they stay within a time budget per tick so the simulation
+// is not held up; commanders that did not get their turn think in the next
+// tick.
+package ai
+
+import (
+	"cmp"
+	"math"
+	"math/rand/v2"
+	"projectt/game/pathfinding"
+	"projectt/types"
+	"slices"
+	"time"
+)
+
+// Point is a tile coordinate
+type Point = pathfinding.Point
+
+// Unit is a unit of an AI country as the commander sees it
+type Unit struct {
+	ID     uint
+	Type   types.UnitType
+	X, Y   float32
+	Moving bool // still on the way to where it was sent
+}
+
+// World is the game as seen by the commanders. It is implemented by the
+// game server.
+type World interface {
+	// AIControlled returns the countries played by the AI
+	AIControlled() []uint8
+	// Units returns the units of a country
+	Units(country uint8) []Unit
+	// Tile returns the owner of a tile and the country occupying it, 0 if
+	// it is not occupied
+	Tile(p Point) (owner, occupier uint8, ok bool)
+	// Strength returns the number of units and players of every country
+	// within radius tiles of p
+	Strength(p Point, radius float32) map[uint8]int
+	// Spawn places a new unit of a country on a tile
+	Spawn(country uint8, unitType types.UnitType, at Point) error
+	// Move sends a unit along a route to a tile. Searching the route may not
+	// take past the deadline.
+	Move(unit uint, to Point, deadline time.Time) error
+}
+
+const (
+	// Units and players within DefenseRadius tiles of a tile defend it
+	DefenseRadius = 4
+	// Occupied tiles a commander sends units back to at once
+	maxResponses = 4
+	// A unit that stopped further than this from where it was sent did not
+	// get there
+	arrivalDistance = 1.5
+)
+
+// Config sets the difficulty of all AI countries and the time they may
+// take
+type Config struct {
+	Difficulty Difficulty
+	// Time between two decisions of a commander
+	ThinkInterval time.Duration
+	// Time all commanders together may spend per tick
+	Budget time.Duration
+}
+
+// Brain runs the commanders of all AI countries. Think must not be called
+// concurrently, the territory may be updated at any time.
+type Brain struct {
+	cfg        Config
+	territory  *Territory
+	commanders map[uint8]*commander
+	next       int // country whose commander gets the next turn
+	rng        *rand.Rand
+}
+
+func NewBrain(cfg Config) *Brain {
+	return &Brain{
+		cfg:        cfg,
+		territory:  NewTerritory(),
+		commanders: make(map[uint8]*commander),
+		rng:        rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+	}
+}
+
+// Territory returns the tile index the commanders plan on
+func (b *Brain) Territory() *Territory {
+	return b.territory
+}
+
+// Think lets the commanders that are due decide, in turn, until the budget
+// is used up. The first one due always gets to think, but the routes it
+// searches are cut off at the end of the budget too.
+func (b *Brain) Think(world World, now time.Time) {
+	countries := world.AIControlled()
+	slices.Sort(countries)
+	for id := range b.commanders {
+		if !slices.Contains(countries, id) {
+			delete(b.commanders, id)
+		}
+	}
+	if len(countries) == 0 {
+		return
+	}
+
+	deadline := time.Now().Add(b.cfg.Budget)
+	thought := false
+	for i := range len(countries) {
+		index := (b.next + i) % len(countries)
+		c := b.commander(countries[index])
+		if !c.thought.IsZero() && now.Sub(c.thought) < b.cfg.ThinkInterval {
+			continue
+		}
+		if thought && !time.Now().Before(deadline) {
+			// the rest go first in the next tick
+			b.next = index
+			return
+		}
+		c.think(world, now, deadline)
+		c.thought = now
+		thought = true
+	}
+}
+
+func (b *Brain) commander(country uint8) *commander {
+	c, ok := b.commanders[country]
+	if !ok {
+		c = &commander{
+			country:   country,
+			settings:  b.cfg.Difficulty.Settings(),
+			territory: b.territory,
+			rng:       b.rng,
+			tasks:     make(map[uint]task),
+		}
+		b.commanders[country] = c
+	}
+	return c
+}
+
+type taskKind uint8
+
+const (
+	taskDefend taskKind = iota + 1 // guard a border tile
+	taskAttack                     // occupy an enemy tile
+	taskRetake                     // free an own tile from occupation
+)
+
+type task struct {
+	kind   taskKind
+	target Point
+}
+
+// commander decides for one country
+type commander struct {
+	country   uint8
+	settings  Settings
+	territory *Territory
+	rng       *rand.Rand
+
+	thought   time.Time // last decision
+	lastSpawn time.Time
+	spawned   int
+	tasks     map[uint]task // by unit ID, units without one are idle
+}
+
+func (c *commander) think(world World, now time.Time, deadline time.Time) {
+	units := world.Units(c.country)
+	alive := make(map[uint]bool, len(units))
+	for _, u := range units {
+		alive[u.ID] = true
+		if t, ok := c.tasks[u.ID]; ok && c.finished(world, u, t) {
+			delete(c.tasks, u.ID)
+		}
+	}
+	for id := range c.tasks {
+		if !alive[id] {
+			delete(c.tasks, id)
+		}
+	}
+
+	// reacting to attacks comes first and may call defenders away
+	c.respond(world, units, deadline)
+	c.spawn(world, len(units), now)
+
+	idle := make([]Unit, 0, len(units))
+	defenders := 0
+	for _, u := range units {
+		switch c.tasks[u.ID].kind {
+		case 0:
+			idle = append(idle, u)
+		case taskDefend:
+			defenders++
+		}
+	}
+	reserve := int(math.Ceil(float64(len(units))*c.settings.DefenseShare)) - defenders
+	idle = c.attack(world, idle, len(idle)-max(0, reserve), deadline)
+	c.defend(world, idle, deadline)
+}
+
+// finished reports whether a unit is done with its task
+func (c *commander) finished(world World, u Unit, t task) bool {
+	// a unit that stopped short was blocked on the way
+	if !u.Moving && distance(u, t.target) > arrivalDistance {
+		return true
+	}
+	switch t.kind {
+	case taskAttack:
+		owner, occupier, _ := world.Tile(t.target)
+		return owner == c.country || occupier == c.country
+	case taskRetake:
+		return !c.territory.IsLost(c.country, t.target)
+	}
+	return false
+}
+
+// respond sends units to the tiles of the country the enemy occupied most
+// recently, enough to outnumber the occupiers
+func (c *commander) respond(world World, units []Unit, deadline time.Time) {
+	for _, p := range c.territory.Lost(c.country, maxResponses) {
+		need := c.needed(world, p)
+		for _, t := range c.tasks {
+			if t.kind == taskRetake && t.target == p {
+				need--
+			}
+		}
+
+		// idle units and defenders answer, nearest first
+		candidates := make([]Unit, 0, len(units))
+		for _, u := range units {
+			if kind := c.tasks[u.ID].kind; kind == 0 || kind == taskDefend {
+				candidates = append(candidates, u)
+			}
+		}
+		c.send(world, nearest(candidates, p, need), task{kind: taskRetake, target: p}, deadline)
+	}
+}
+
+// spawn places a new unit on a random border tile when the country has
+// room for one and its spawn interval passed
+func (c *commander) spawn(world World, units int, now time.Time) {
+	if units >= c.settings.MaxUnits {
+		return
+	}
+	if !c.lastSpawn.IsZero() && now.Sub(c.lastSpawn) < c.settings.SpawnInterval {
+		return
+	}
+
+	unitType := types.UnitTypeInfantry
+	if c.settings.TankEvery > 0 && (c.spawned+1)%c.settings.TankEvery == 0 {
+		unitType = types.UnitTypeTank
+	}
+	for _, p := range c.territory.SampleBorders(c.country, c.settings.Awareness, c.rng) {
+		if world.Spawn(c.country, unitType, p) == nil {
+			c.lastSpawn = now
+			c.spawned++
+			return
+		}
+	}
+}
+
+// attack sends up to available idle units against the weakest enemy tile
+// next to the border, if they outnumber its defenders enough. It returns
+// the units left idle.
+func (c *commander) attack(world World, idle []Unit, available int, deadline time.Time) []Unit {
+	if available <= 0 {
+		return idle
+	}
+
+	var target Point
+	found, weakest := false, 0
+	seen := map[Point]bool{}
+	for _, border := range c.territory.SampleBorders(c.country, c.settings.Awareness, c.rng) {
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				p := Point{X: border.X + dx, Y: border.Y + dy}
+				if seen[p] {
+					continue
+				}
+				seen[p] = true
+				owner, occupier, ok := world.Tile(p)
+				if !ok || owner == 0 || owner == c.country || occupier == c.country {
+					continue
+				}
+				if need := c.needed(world, p); need <= available && (!found || need < weakest) {
+					target, found, weakest = p, true, need
+				}
+			}
+		}
+	}
+	if !found {
+		return idle
+	}
+
+	squad := nearest(idle, target, weakest)
+	sent := c.send(world, squad, task{kind: taskAttack, target: target}, deadline)
+	return slices.DeleteFunc(idle, func(u Unit) bool { return sent[u.ID] })
+}
+
+// defend stations idle units on the border tiles most threatened by enemy
+// forces, spreading them over the tiles that are equally threatened
+func (c *commander) defend(world World, idle []Unit, deadline time.Time) {
+	if len(idle) == 0 {
+		return
+	}
+	posts := c.territory.SampleBorders(c.country, c.settings.Awareness, c.rng)
+	if len(posts) == 0 {
+		return
+	}
+	threat := make([]int, len(posts))
+	for i, p := range posts {
+		threat[i] = enemies(world.Strength(p, DefenseRadius), c.country)
+		for _, t := range c.tasks {
+			if t.kind == taskDefend && distance2(t.target, p) <= DefenseRadius*DefenseRadius {
+				threat[i]--
+			}
+		}
+	}
+
+	for _, u := range idle {
+		if !time.Now().Before(deadline) {
+			return
+		}
+		best := 0
+		for i := range posts {
+			if threat[i] > threat[best] {
+				best = i
+			}
+		}
+		if world.Move(u.ID, posts[best], deadline) == nil {
+			c.tasks[u.ID] = task{kind: taskDefend, target: posts[best]}
+			threat[best]--
+		}
+	}
+}
+
+// needed returns the number of units that outnumber the enemies near p by
+// the attack ratio
+func (c *commander) needed(world World, p Point) int {
+	return int(float64(enemies(world.Strength(p, DefenseRadius), c.country))*c.settings.AttackRatio) + 1
+}
+
+// send moves units to the target of a task, as long as there is time left,
+// and returns the units that were sent
+func (c *commander) send(world World, units []Unit, t task, deadline time.Time) map[uint]bool {
+	sent := make(map[uint]bool, len(units))
+	for _, u := range units {
+		if !time.Now().Before(deadline) {
+			break
+		}
+		if world.Move(u.ID, t.target, deadline) == nil {
+			c.tasks[u.ID] = t
+			sent[u.ID] = true
+		}
+	}
+	return sent
+}
+
+// enemies counts the forces not belonging to country
+func enemies(strength map[uint8]int, country uint8) int {
+	total := 0
+	for id, n := range strength {
+		if id != country {
+			total += n
+		}
+	}
+	return total
+}
+
+// nearest returns up to n units closest to p
+func nearest(units []Unit, p Point, n int) []Unit {
+	if n <= 0 {
+		return nil
+	}
+	sorted := slices.Clone(units)
+	slices.SortStableFunc(sorted, func(a, b Unit) int {
+		return cmp.Compare(distance(a, p), distance(b, p))
+	})
+	return sorted[:min(n, len(sorted))]
+}
+
+// distance is measured from the unit to the centre of tile p
+func distance(u Unit, p Point) float64 {
+	dx, dy := float64(u.X)-float64(p.X)-0.5, float64(u.Y)-float64(p.Y)-0.5
+	return math.Sqrt(dx*dx + dy*dy)
+}
+
+func distance2(a, b Point) int {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx*dx + dy*dy
+}
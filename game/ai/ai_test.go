@@ -0,0 +1,290 @@
+package ai
+
+import (
+	"errors"
+	"math/rand/v2"
+	"projectt/models"
+	"projectt/types"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeWorld is a map where country 1 (the AI) owns x < 10 and country 2
+// owns the rest, with border tiles on both sides of x = 10. Units arrive
+// as soon as they are moved.
+type fakeWorld struct {
+	territory *Territory
+	tiles     map[Point]models.MapTile
+	units     map[uint]*Unit
+	owners    map[uint]uint8
+	// enemy players by tile
+	players map[Point]int
+	nextID  uint
+}
+
+func newFakeWorld(territory *Territory) *fakeWorld {
+	w := &fakeWorld{
+		territory: territory,
+		tiles:     map[Point]models.MapTile{},
+		units:     map[uint]*Unit{},
+		owners:    map[uint]uint8{},
+		players:   map[Point]int{},
+	}
+	for x := range 20 {
+		for y := range 10 {
+			tile := models.MapTile{CoordX: uint16(x), CoordY: uint16(y), OwnerCountryID: 1, IsBorder: x == 9}
+			if x >= 10 {
+				tile.OwnerCountryID, tile.IsBorder = 2, x == 10
+			}
+			w.setTile(tile)
+		}
+	}
+	return w
+}
+
+func (w *fakeWorld) setTile(tile models.MapTile) {
+	w.tiles[Point{X: int(tile.CoordX), Y: int(tile.CoordY)}] = tile
+	w.territory.Update(tile)
+}
+
+func (w *fakeWorld) AIControlled() []uint8 { return []uint8{1} }
+
+func (w *fakeWorld) Units(country uint8) []Unit {
+	units := []Unit{}
+	for id, u := range w.units {
+		if w.owners[id] == country {
+			units = append(units, *u)
+		}
+	}
+	return units
+}
+
+func (w *fakeWorld) Tile(p Point) (uint8, uint8, bool) {
+	tile, ok := w.tiles[p]
+	var occupier uint8
+	if tile.OccupiedByCountryID != nil {
+		occupier = *tile.OccupiedByCountryID
+	}
+	return tile.OwnerCountryID, occupier, ok
+}
+
+func (w *fakeWorld) Strength(p Point, radius float32) map[uint8]int {
+	strength := map[uint8]int{}
+	for at, n := range w.players {
+		if distance2(at, p) <= int(radius*radius) {
+			strength[2] += n
+		}
+	}
+	for id, u := range w.units {
+		if distance(*u, p) <= float64(radius) {
+			strength[w.owners[id]]++
+		}
+	}
+	return strength
+}
+
+func (w *fakeWorld) Spawn(country uint8, unitType types.UnitType, at Point) error {
+	if w.tiles[at].OwnerCountryID != country {
+		return errors.New("not our tile")
+	}
+	w.nextID++
+	w.units[w.nextID] = &Unit{ID: w.nextID, Type: unitType, X: float32(at.X) + 0.5, Y: float32(at.Y) + 0.5}
+	w.owners[w.nextID] = country
+	return nil
+}
+
+func (w *fakeWorld) Move(id uint, to Point, deadline time.Time) error {
+	u := w.units[id]
+	u.X, u.Y = float32(to.X)+0.5, float32(to.Y)+0.5
+	return nil
+}
+
+// occupy marks a tile as occupied by a country
+func (w *fakeWorld) occupy(p Point, country uint8) {
+	tile := w.tiles[p]
+	tile.OccupiedByCountryID = &country
+	w.setTile(tile)
+}
+
+func newTestBrain(d Difficulty) *Brain {
+	b := NewBrain(Config{Difficulty: d, ThinkInterval: time.Second, Budget: time.Second})
+	b.rng = rand.New(rand.NewPCG(1, 2))
+	return b
+}
+
+func TestParseDifficulty(t *testing.T) {
+	for _, d := range []Difficulty{Easy, Normal, Hard} {
+		if parsed, err := ParseDifficulty(d.String()); err != nil || parsed != d {
+			t.Fatalf("ParseDifficulty(%q) = %v, %v", d, parsed, err)
+		}
+	}
+	if _, err := ParseDifficulty("insane"); err == nil {
+		t.Fatal("expected an error for an unknown difficulty")
+	}
+	if Hard.Settings().MaxUnits <= Easy.Settings().MaxUnits || Hard.Settings().AttackRatio >= Easy.Settings().AttackRatio {
+		t.Fatal("hard AI should field more units and attack sooner than easy")
+	}
+}
+
+func TestTerritory(t *testing.T) {
+	territory := NewTerritory()
+	w := newFakeWorld(territory)
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	borders := territory.SampleBorders(1, 100, rng)
+	if len(borders) != 10 || slices.ContainsFunc(borders, func(p Point) bool { return p.X != 9 }) {
+		t.Fatalf("unexpected borders %v", borders)
+	}
+	if len(territory.SampleBorders(2, 3, rng)) != 3 {
+		t.Fatal("sample not limited")
+	}
+
+	// occupations are listed newest first until they end
+	first, second := Point{X: 9, Y: 1}, Point{X: 8, Y: 2}
+	w.occupy(first, 2)
+	tile := w.tiles[second]
+	occupiedAt := time.Now().Add(time.Minute)
+	tile.OccupiedByCountryID, tile.OccupiedAt = new(uint8), &occupiedAt
+	*tile.OccupiedByCountryID = 2
+	w.setTile(tile)
+	if lost := territory.Lost(1, 10); !slices.Equal(lost, []Point{second, first}) {
+		t.Fatalf("unexpected lost tiles %v", lost)
+	}
+	tile = w.tiles[first]
+	tile.OccupiedByCountryID = nil
+	w.setTile(tile)
+	if territory.IsLost(1, first) || !territory.IsLost(1, second) {
+		t.Fatal("freed tile is still lost")
+	}
+
+	// a border tile changing hands moves to the new owner
+	tile = w.tiles[Point{X: 9, Y: 0}]
+	tile.OwnerCountryID = 2
+	w.setTile(tile)
+	if len(territory.SampleBorders(1, 100, rng)) != 9 || len(territory.SampleBorders(2, 100, rng)) != 11 {
+		t.Fatal("border tile did not change owner")
+	}
+}
+
+func TestCommanderSpawnsAndAttacks(t *testing.T) {
+	brain := newTestBrain(Hard)
+	w := newFakeWorld(brain.Territory())
+	now := time.Now()
+
+	// the first unit spawns right away, the next after the spawn interval
+	brain.Think(w, now)
+	if len(w.units) != 1 {
+		t.Fatalf("expected one unit, got %d", len(w.units))
+	}
+	brain.Think(w, now.Add(time.Second))
+	if len(w.units) != 1 {
+		t.Fatal("spawned before the spawn interval")
+	}
+
+	// undefended enemy tiles next to the border are attacked
+	for i := 2; len(w.units) < 4; i++ {
+		brain.Think(w, now.Add(time.Duration(i)*Hard.Settings().SpawnInterval))
+	}
+	attackers := 0
+	for _, u := range w.units {
+		if owner, _, _ := w.Tile(Point{X: int(u.X), Y: int(u.Y)}); owner == 2 {
+			attackers++
+		}
+	}
+	if attackers == 0 {
+		t.Fatal("no unit was sent into enemy territory")
+	}
+}
+
+func TestCommanderHoldsBackAgainstStrongDefence(t *testing.T) {
+	brain := newTestBrain(Easy)
+	w := newFakeWorld(brain.Territory())
+	// enemies line up along their whole border
+	for y := range 10 {
+		w.players[Point{X: 11, Y: y}] = 5
+	}
+
+	now := time.Now()
+	for i := range 4 {
+		brain.Think(w, now.Add(time.Duration(i)*Easy.Settings().SpawnInterval))
+	}
+	if len(w.units) != 4 {
+		t.Fatalf("expected 4 units, got %d", len(w.units))
+	}
+	for _, u := range w.units {
+		if u.X >= 10 {
+			t.Fatalf("unit attacked a strong defence at %v,%v", u.X, u.Y)
+		}
+		if u.X < 9 || u.X >= 10 {
+			t.Fatalf("unit not stationed on the border: %v,%v", u.X, u.Y)
+		}
+	}
+}
+
+func TestCommanderRetakesTiles(t *testing.T) {
+	brain := newTestBrain(Normal)
+	w := newFakeWorld(brain.Territory())
+	now := time.Now()
+	for i := range 3 {
+		brain.Think(w, now.Add(time.Duration(i)*Normal.Settings().SpawnInterval))
+	}
+
+	lost := Point{X: 3, Y: 5}
+	w.occupy(lost, 2)
+	brain.Think(w, now.Add(time.Hour))
+	responders := 0
+	for _, u := range w.units {
+		if int(u.X) == lost.X && int(u.Y) == lost.Y {
+			responders++
+		}
+	}
+	if responders != 1 {
+		t.Fatalf("expected one unit to retake the undefended tile, got %d", responders)
+	}
+}
+
+func TestThinkBudget(t *testing.T) {
+	brain := NewBrain(Config{Difficulty: Normal, ThinkInterval: time.Second})
+	w := &multiWorld{fakeWorld: newFakeWorld(brain.Territory())}
+
+	// with no budget the countries take turns, one per call
+	now := time.Now()
+	for i := range 3 {
+		brain.Think(w, now)
+		if !w.thought(brain, i+1) {
+			t.Fatalf("expected %d commanders to have thought", i+1)
+		}
+	}
+
+	// countries no longer played by the AI are forgotten
+	w.countries = []uint8{1}
+	brain.Think(w, now)
+	if len(brain.commanders) != 1 {
+		t.Fatalf("expected one commander, got %d", len(brain.commanders))
+	}
+}
+
+// multiWorld adds AI countries without territory
+type multiWorld struct {
+	*fakeWorld
+	countries []uint8
+}
+
+func (w *multiWorld) AIControlled() []uint8 {
+	if w.countries == nil {
+		return []uint8{1, 3, 4}
+	}
+	return slices.Clone(w.countries)
+}
+
+// thought reports whether exactly n commanders have thought
+func (w *multiWorld) thought(brain *Brain, n int) bool {
+	count := 0
+	for _, c := range brain.commanders {
+		if !c.thought.IsZero() {
+			count++
+		}
+	}
+	return count == n
+}
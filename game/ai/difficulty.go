@@ -0,0 +1,88 @@
+package ai
+
+import (
+	"fmt"
+	"time"
+)
+
+// Difficulty selects how strong AI countries play
+type Difficulty uint8
+
+const (
+	Easy Difficulty = iota
+	Normal
+	Hard
+)
+
+var difficultyNames = map[Difficulty]string{
+	Easy:   "easy",
+	Normal: "normal",
+	Hard:   "hard",
+}
+
+func (d Difficulty) String() string {
+	if name, ok := difficultyNames[d]; ok {
+		return name
+	}
+	return fmt.Sprintf("Difficulty(%d)", int(d))
+}
+
+// ParseDifficulty parses a difficulty name as returned by String
+func ParseDifficulty(name string) (Difficulty, error) {
+	for d, difficultyName := range difficultyNames {
+		if difficultyName == name {
+			return d, nil
+		}
+	}
+	return Easy, fmt.Errorf("unknown difficulty %q", name)
+}
+
+// Settings tune the behaviour of a commander
+type Settings struct {
+	// Units a country keeps in the field, and the time between two spawns
+	MaxUnits      int
+	SpawnInterval time.Duration
+	// Every TankEvery-th unit spawned is a tank, 0 for infantry only
+	TankEvery int
+	// Units sent to capture a tile must outnumber its defenders by this
+	// factor
+	AttackRatio float64
+	// Share of the units held back to guard the border
+	DefenseShare float64
+	// Border tiles looked at per decision, for targets and threats
+	Awareness int
+}
+
+var settings = map[Difficulty]Settings{
+	Easy: {
+		MaxUnits:      4,
+		SpawnInterval: 30 * time.Second,
+		AttackRatio:   3,
+		DefenseShare:  0.75,
+		Awareness:     4,
+	},
+	Normal: {
+		MaxUnits:      8,
+		SpawnInterval: 15 * time.Second,
+		TankEvery:     4,
+		AttackRatio:   2,
+		DefenseShare:  0.5,
+		Awareness:     8,
+	},
+	Hard: {
+		MaxUnits:      16,
+		SpawnInterval: 5 * time.Second,
+		TankEvery:     2,
+		AttackRatio:   1.25,
+		DefenseShare:  0.3,
+		Awareness:     16,
+	},
+}
+
+// Settings returns the settings of a difficulty
+func (d Difficulty) Settings() Settings {
+	if s, ok := settings[d]; ok {
+		return s
+	}
+	return settings[Normal]
+}
@@ -0,0 +1,153 @@
+package ai
+
+import (
+	"math/rand/v2"
+	"projectt/game/pathfinding"
+	"projectt/models"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Territory indexes the tiles commanders care about: the border tiles of
+// every country and the tiles occupied by another country. It is safe for
+// concurrent use.
+type Territory struct {
+	mu      sync.Mutex
+	borders map[uint8]*pointSet
+	lost    map[uint8]map[pathfinding.Point]time.Time // by owner, when the tile was occupied
+	tiles   map[pathfinding.Point]tileState           // indexed tiles only
+}
+
+type tileState struct {
+	owner    uint8
+	border   bool
+	occupied bool
+}
+
+// pointSet keeps points in a slice for random sampling
+type pointSet struct {
+	points []pathfinding.Point
+	index  map[pathfinding.Point]int
+}
+
+func NewTerritory() *Territory {
+	return &Territory{
+		borders: make(map[uint8]*pointSet),
+		lost:    make(map[uint8]map[pathfinding.Point]time.Time),
+		tiles:   make(map[pathfinding.Point]tileState),
+	}
+}
+
+// Update indexes the current state of a tile. It must be called for every
+// tile when the map is loaded and whenever a tile changes.
+func (t *Territory) Update(tile models.MapTile) {
+	p := pathfinding.Point{X: int(tile.CoordX), Y: int(tile.CoordY)}
+	occupied := tile.OccupiedByCountryID != nil && *tile.OccupiedByCountryID != tile.OwnerCountryID
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	old := t.tiles[p]
+	if old.border {
+		t.borders[old.owner].remove(p)
+	}
+	if old.occupied && (!occupied || old.owner != tile.OwnerCountryID) {
+		delete(t.lost[old.owner], p)
+	}
+
+	state := tileState{owner: tile.OwnerCountryID, border: tile.IsBorder, occupied: occupied}
+	if state.border {
+		if t.borders[state.owner] == nil {
+			t.borders[state.owner] = &pointSet{index: make(map[pathfinding.Point]int)}
+		}
+		t.borders[state.owner].add(p)
+	}
+	if state.occupied && !(old.occupied && old.owner == state.owner) {
+		if t.lost[state.owner] == nil {
+			t.lost[state.owner] = make(map[pathfinding.Point]time.Time)
+		}
+		occupiedAt := time.Now()
+		if tile.OccupiedAt != nil {
+			occupiedAt = *tile.OccupiedAt
+		}
+		t.lost[state.owner][p] = occupiedAt
+	}
+
+	if state.border || state.occupied {
+		t.tiles[p] = state
+	} else {
+		delete(t.tiles, p)
+	}
+}
+
+// SampleBorders returns up to n random border tiles of a country
+func (t *Territory) SampleBorders(country uint8, n int, rng *rand.Rand) []pathfinding.Point {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	set := t.borders[country]
+	if set == nil || len(set.points) == 0 {
+		return nil
+	}
+	if len(set.points) <= n {
+		return slices.Clone(set.points)
+	}
+	sample := make([]pathfinding.Point, 0, n)
+	for _, i := range rng.Perm(len(set.points))[:n] {
+		sample = append(sample, set.points[i])
+	}
+	return sample
+}
+
+// Lost returns up to n tiles of a country occupied by others, most recently
+// occupied first
+func (t *Territory) Lost(country uint8, n int) []pathfinding.Point {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lost := make([]pathfinding.Point, 0, len(t.lost[country]))
+	for p := range t.lost[country] {
+		lost = append(lost, p)
+	}
+	times := t.lost[country]
+	slices.SortFunc(lost, func(a, b pathfinding.Point) int {
+		if c := times[b].Compare(times[a]); c != 0 {
+			return c
+		}
+		// stable order for tiles occupied at the same time
+		if a.X != b.X {
+			return a.X - b.X
+		}
+		return a.Y - b.Y
+	})
+	return lost[:min(n, len(lost))]
+}
+
+// IsLost reports whether a tile of a country is occupied by another one
+func (t *Territory) IsLost(country uint8, p pathfinding.Point) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.lost[country][p]
+	return ok
+}
+
+func (s *pointSet) add(p pathfinding.Point) {
+	if _, ok := s.index[p]; ok {
+		return
+	}
+	s.index[p] = len(s.points)
+	s.points = append(s.points, p)
+}
+
+func (s *pointSet) remove(p pathfinding.Point) {
+	i, ok := s.index[p]
+	if !ok {
+		return
+	}
+	last := s.points[len(s.points)-1]
+	s.points[i] = last
+	s.index[last] = i
+	s.points = s.points[:len(s.points)-1]
+	delete(s.index, p)
+}
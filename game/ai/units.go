@@ -0,0 +1,50 @@
+package ai
+
+import (
+	"projectt/game/entity"
+	"projectt/types"
+	"time"
+)
+
+// template holds the stats of a unit type commanders spawn
+type template struct {
+	health int
+	speed  float32
+	weapon entity.Weapon
+}
+
+var templates = map[types.UnitType]template{
+	types.UnitTypeInfantry: {
+		health: 100,
+		speed:  6,
+		weapon: entity.Weapon{Damage: 10, Range: 5, Speed: 20, Cooldown: time.Second},
+	},
+	types.UnitTypeTank: {
+		health: 400,
+		speed:  4,
+		weapon: entity.Weapon{Damage: 50, Range: 8, Speed: 25, Cooldown: 3 * time.Second},
+	},
+}
+
+// NewEntity returns a fresh, armed unit of the given type for a country,
+// infantry as a soldier and everything else as a unit. Types without a
+// template get infantry stats.
+func NewEntity(unitType types.UnitType, country uint8) entity.Entity {
+	t, ok := templates[unitType]
+	if !ok {
+		t = templates[types.UnitTypeInfantry]
+	}
+	entityType := types.EntityTypeUnit
+	if unitType == types.UnitTypeInfantry {
+		entityType = types.EntityTypeSoldier
+	}
+	weapon := t.weapon
+	return entity.Entity{
+		Type:     entityType,
+		Country:  country,
+		Body:     &entity.Body{UnitType: unitType, Speed: t.speed},
+		Health:   &entity.Health{Current: t.health, Max: t.health},
+		Weapon:   &weapon,
+		Occupier: &entity.Occupier{},
+	}
+}
@@ -0,0 +1,296 @@
+// Package anticheat validates player movement and keeps a suspicion score
+// for every player.
+//
+// Positions are simulated by the server, so a cheating client can only
+// bend the inputs it sends: movement timestamps that run ahead of the
+// server clock, more inputs than the tick rate allows, or anything that
+// makes a player cover more ground than its speed allows. Each offense adds
+// to the player's score, which decays over time so honest players that
+// trip a check now and then never reach the thresholds.
+package anticheat
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Offense is a kind of suspicious movement
+type Offense string
+
+const (
+	ClockDrift Offense = "clock_drift" // timestamps run ahead of the server tick
+	InputRate  Offense = "input_rate"  // more movement inputs than the tick rate allows
+	Teleport   Offense = "teleport"    // position jumped further than the speed allows
+)
+
+// weights is the suspicion added by a single offense
+var weights = map[Offense]float64{
+	ClockDrift: 10,
+	InputRate:  1,
+	Teleport:   25,
+}
+
+const (
+	// A step may be this much longer than speed and elapsed time allow,
+	// plus jumpSlack tiles, to absorb timer jitter
+	stepTolerance = 1.5
+	jumpSlack     = 1
+)
+
+// Action is what the server should do about a player after an offense
+type Action int
+
+const (
+	None Action = iota
+	Flag        // alert moderators, once per time the score crosses FlagScore
+	Kick        // disconnect the player
+)
+
+// Report describes an offense. The zero Report means nothing was detected.
+type Report struct {
+	Offense Offense
+	Score   float64 // score after the offense
+	Action  Action
+}
+
+// Config sets the validator's tolerances and thresholds
+type Config struct {
+	TicksPerSecond int
+	// How far movement timestamps may run ahead of the server tick
+	MaxClockDrift time.Duration
+	// Points the score loses per second
+	ScoreDecay float64
+	// Scores at which players are flagged and kicked, 0 disables either
+	FlagScore float64
+	KickScore float64
+}
+
+// Validator checks the movement of all players. It is safe for concurrent
+// use. A nil Validator accepts everything.
+type Validator struct {
+	cfg      Config
+	maxDrift float64 // MaxClockDrift in ticks
+
+	mu      sync.Mutex
+	players map[uint]*record
+}
+
+type record struct {
+	online bool
+
+	score    float64
+	scoredAt time.Time
+	flagged  bool
+
+	// Client timestamp and server tick of the input the clock is measured from
+	synced        bool
+	baseTimestamp float32
+	baseTick      uint64
+
+	// Last observed position
+	positioned bool
+	x, y       float32
+	seenAt     time.Time
+}
+
+// New returns a validator with the given settings
+func New(cfg Config) *Validator {
+	return &Validator{
+		cfg:      cfg,
+		maxDrift: cfg.MaxClockDrift.Seconds() * float64(cfg.TicksPerSecond),
+		players:  make(map[uint]*record),
+	}
+}
+
+func (v *Validator) record(id uint) *record {
+	r, ok := v.players[id]
+	if !ok {
+		r = &record{}
+		v.players[id] = r
+	}
+	r.online = true
+	return r
+}
+
+// Input checks the timestamp of a movement input received at the given
+// server tick. Timestamps count ticks like the server does, so the client
+// clock may not advance faster than the server's. It returns false when the
+// input must be dropped.
+func (v *Validator) Input(id uint, timestamp float32, tick uint64, now time.Time) (Report, bool) {
+	if v == nil {
+		return Report{}, true
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	r := v.record(id)
+	if !r.synced {
+		r.synced = true
+		r.baseTimestamp, r.baseTick = timestamp, tick
+		return Report{}, true
+	}
+
+	// Timestamps are float32 and stop counting whole ticks after 2^24 ticks,
+	// so the difference is taken in float64 and the rounding of the client
+	// clock is tolerated on top of the drift
+	drift := float64(timestamp) - float64(r.baseTimestamp) - float64(tick-r.baseTick)
+	if drift > v.maxDrift+resolution(timestamp) {
+		return v.offend(r, ClockDrift, now), false
+	}
+	if drift < -v.maxDrift {
+		// The client fell behind, e.g. after a stall. Measure from here so
+		// it cannot bank the lost time and run fast later.
+		r.baseTimestamp, r.baseTick = timestamp, tick
+	}
+	return Report{}, true
+}
+
+// resolution returns the distance from f to the next larger float32
+func resolution(f float32) float64 {
+	f = float32(math.Abs(float64(f)))
+	return float64(math.Nextafter32(f, float32(math.Inf(1))) - f)
+}
+
+// InputDropped records a movement input rejected by the rate limit
+func (v *Validator) InputDropped(id uint, now time.Time) Report {
+	if v == nil {
+		return Report{}
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.offend(v.record(id), InputRate, now)
+}
+
+// Position checks that a player moving at most speed tiles per second
+// could have reached x,y since its last observed position
+func (v *Validator) Position(id uint, x, y, speed float32, now time.Time) Report {
+	if v == nil {
+		return Report{}
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	r := v.record(id)
+	last, lastX, lastY := r.seenAt, r.x, r.y
+	positioned := r.positioned
+	r.positioned, r.x, r.y, r.seenAt = true, x, y, now
+	if !positioned {
+		return Report{}
+	}
+
+	dx, dy := float64(x-lastX), float64(y-lastY)
+	allowed := float64(speed)*now.Sub(last).Seconds()*stepTolerance + jumpSlack
+	if math.Hypot(dx, dy) <= allowed {
+		return Report{}
+	}
+	return v.offend(r, Teleport, now)
+}
+
+// Teleported tells the validator that the player was moved on purpose. The
+// next position is taken as is.
+func (v *Validator) Teleported(id uint) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.record(id).positioned = false
+}
+
+// Reset forgets the clock and position of a player whose client started
+// over, e.g. after logging in again. The score is kept.
+func (v *Validator) Reset(id uint) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	r := v.record(id)
+	r.synced, r.positioned = false, false
+}
+
+// Forget marks a player as offline. Its score is kept until it decays, so
+// reconnecting does not clear it.
+func (v *Validator) Forget(id uint) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if r, ok := v.players[id]; ok {
+		r.online, r.synced, r.positioned = false, false, false
+	}
+}
+
+// Score returns the current suspicion score of a player
+func (v *Validator) Score(id uint, now time.Time) float64 {
+	if v == nil {
+		return 0
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	r, ok := v.players[id]
+	if !ok {
+		return 0
+	}
+	v.decay(r, now)
+	return r.score
+}
+
+// Prune forgets offline players whose score decayed to zero by now
+func (v *Validator) Prune(now time.Time) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for id, r := range v.players {
+		if v.decay(r, now); !r.online && r.score == 0 {
+			delete(v.players, id)
+		}
+	}
+}
+
+// Len returns the number of players with a record
+func (v *Validator) Len() int {
+	if v == nil {
+		return 0
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.players)
+}
+
+func (v *Validator) decay(r *record, now time.Time) {
+	if r.scoredAt.IsZero() || now.After(r.scoredAt) {
+		if !r.scoredAt.IsZero() {
+			r.score = math.Max(0, r.score-v.cfg.ScoreDecay*now.Sub(r.scoredAt).Seconds())
+		}
+		r.scoredAt = now
+	}
+	if r.score < v.cfg.FlagScore {
+		r.flagged = false
+	}
+}
+
+func (v *Validator) offend(r *record, offense Offense, now time.Time) Report {
+	v.decay(r, now)
+	r.score += weights[offense]
+
+	report := Report{Offense: offense, Score: r.score}
+	switch {
+	case v.cfg.KickScore > 0 && r.score >= v.cfg.KickScore:
+		report.Action = Kick
+	case v.cfg.FlagScore > 0 && r.score >= v.cfg.FlagScore && !r.flagged:
+		r.flagged = true
+		report.Action = Flag
+	}
+	return report
+}
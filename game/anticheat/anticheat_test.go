@@ -0,0 +1,156 @@
+package anticheat
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestValidator() *Validator {
+	return New(Config{
+		TicksPerSecond: 10,
+		MaxClockDrift:  time.Second, // 10 ticks
+		ScoreDecay:     1,
+		FlagScore:      20,
+		KickScore:      40,
+	})
+}
+
+func TestClockDrift(t *testing.T) {
+	start := time.Unix(0, 0)
+	v := newTestValidator()
+
+	// the first input only sets the baseline, whatever the client clock says
+	if _, ok := v.Input(1, 5000, 100, start); !ok {
+		t.Fatal("first input was dropped")
+	}
+	// client and server advanced by the same amount
+	if report, ok := v.Input(1, 5050, 150, start); !ok || report.Offense != "" {
+		t.Fatalf("input in step with the server was rejected: %+v", report)
+	}
+	// some jitter is tolerated
+	if _, ok := v.Input(1, 5058, 150, start); !ok {
+		t.Fatal("input within the drift tolerance was dropped")
+	}
+
+	// a client clock running ahead is caught
+	report, ok := v.Input(1, 5100, 151, start)
+	if ok || report.Offense != ClockDrift || report.Score != 10 {
+		t.Fatalf("unexpected result for a fast clock: %+v %v", report, ok)
+	}
+
+	// falling behind is not an offense, but the lost time cannot be used up later
+	if _, ok := v.Input(1, 5060, 200, start); !ok {
+		t.Fatal("late input was dropped")
+	}
+	if _, ok := v.Input(1, 5080, 200, start); ok {
+		t.Fatal("client caught up on time it lost earlier")
+	}
+}
+
+func TestClockDriftAfterLongUptime(t *testing.T) {
+	start := time.Unix(0, 0)
+	v := newTestValidator()
+
+	// float32 counts in steps of 128 ticks here, an honest client rounds its
+	// clock by up to 64 ticks
+	const uptime = 1 << 30
+	v.Input(1, float32(uptime), uptime, start)
+	for tick := uint64(uptime + 1); tick < uptime+300; tick++ {
+		if report, ok := v.Input(1, float32(tick), tick, start); !ok || report.Offense != "" {
+			t.Fatalf("honest input at tick %d was rejected: %+v", tick, report)
+		}
+	}
+
+	if _, ok := v.Input(1, float32(uptime+300+1000), uptime+300, start); ok {
+		t.Fatal("a clock 1000 ticks ahead was not caught")
+	}
+}
+
+func TestScoreActions(t *testing.T) {
+	start := time.Unix(0, 0)
+	v := newTestValidator()
+	v.Input(1, 0, 0, start)
+
+	actions := make([]Action, 0)
+	for i := range 4 {
+		report, _ := v.Input(1, 1000, uint64(i), start)
+		actions = append(actions, report.Action)
+	}
+	// flagged once when reaching 20, kicked at 40
+	want := []Action{None, Flag, None, Kick}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Fatalf("actions = %v, want %v", actions, want)
+		}
+	}
+
+	// the score decays by one point per second
+	if score := v.Score(1, start.Add(15*time.Second)); score != 25 {
+		t.Fatalf("score after 15s = %v, want 25", score)
+	}
+	// and the player is flagged again when crossing the threshold anew
+	v.Score(1, start.Add(30*time.Second))
+	if report, _ := v.Input(1, 2000, 10, start.Add(30*time.Second)); report.Action != Flag {
+		t.Fatalf("expected a new flag, got %+v", report)
+	}
+}
+
+func TestTeleportDetection(t *testing.T) {
+	start := time.Unix(0, 0)
+	v := newTestValidator()
+
+	v.Position(1, 10, 10, 15, start)
+	// 15 tiles per second for 100ms
+	if report := v.Position(1, 11.5, 10, 15, start.Add(100*time.Millisecond)); report.Offense != "" {
+		t.Fatalf("regular step flagged: %+v", report)
+	}
+	if report := v.Position(1, 100, 10, 15, start.Add(200*time.Millisecond)); report.Offense != Teleport {
+		t.Fatalf("jump not detected: %+v", report)
+	}
+
+	// sanctioned teleports are not offenses
+	v.Teleported(1)
+	if report := v.Position(1, 500, 500, 15, start.Add(1100*time.Millisecond)); report.Offense != "" {
+		t.Fatalf("teleport flagged: %+v", report)
+	}
+	if report := v.Position(1, 500, 501, 15, start.Add(1200*time.Millisecond)); report.Offense != "" {
+		t.Fatalf("step after teleport flagged: %+v", report)
+	}
+
+	// after a reset the next position is the new baseline
+	v.Reset(1)
+	if report := v.Position(1, 20, 20, 15, start.Add(1300*time.Millisecond)); report.Offense != "" {
+		t.Fatalf("position after reset flagged: %+v", report)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	start := time.Unix(0, 0)
+	v := newTestValidator()
+
+	v.InputDropped(1, start)
+	v.Position(2, 0, 0, 15, start)
+	v.Forget(1)
+	v.Forget(2)
+	v.Position(3, 0, 0, 15, start)
+
+	// player 1 keeps its score while it decays, online player 3 is kept
+	v.Prune(start)
+	if v.Len() != 2 {
+		t.Fatalf("expected 2 records, got %d", v.Len())
+	}
+	v.Prune(start.Add(time.Second))
+	if v.Len() != 1 {
+		t.Fatalf("expected 1 record, got %d", v.Len())
+	}
+}
+
+func TestNilValidator(t *testing.T) {
+	var v *Validator
+	if _, ok := v.Input(1, 1000, 0, time.Now()); !ok {
+		t.Fatal("nil validator dropped an input")
+	}
+	if report := v.InputDropped(1, time.Now()); report.Offense != "" {
+		t.Fatalf("nil validator reported %+v", report)
+	}
+}
@@ -0,0 +1,280 @@
+// Package entity holds everything that exists on the map besides the tiles:
+// players, units and soldiers of AI countries and the projectiles they fire.
+//
+// An entity has a position, a velocity and an owner country. What else it
+// is and does comes from its components: a Body moves it along routes over
+// the map, Health lets it be hit, a Weapon fires projectiles at enemies in
+// range and a Projectile flies until it hits something or runs out of range.
+// The World steps all entities once per tick.
+//
+// Entities of players mirror the player and carry no Body; the server moves
+// players itself and copies their position over.
+package entity
+
+import (
+	"math"
+	"projectt/game/pathfinding"
+	"projectt/types"
+	"slices"
+	"time"
+)
+
+// ID identifies an entity for as long as the server runs. IDs are not
+// player IDs.
+type ID uint32
+
+type Entity struct {
+	ID         ID
+	Type       types.EntityType
+	X, Y       float32
+	VelX, VelY float32 // tiles per second
+	Country    uint8   // owner, 0 for none
+
+	// Components, nil when the entity does not have them
+	Player     *Player
+	Body       *Body
+	Health     *Health
+	Weapon     *Weapon
+	Projectile *Projectile
+	Occupier   *Occupier
+}
+
+// Player links an entity to a logged in player
+type Player struct {
+	PlayerID uint
+	ConnID   uint32 // connection of the player, kept when a session resumes
+}
+
+// Body moves an entity over the map the way units of its type move
+type Body struct {
+	UnitType types.UnitType
+	Speed    float32             // tiles per second
+	Route    []pathfinding.Point // waypoints left, nil while standing
+}
+
+type Health struct {
+	Current, Max int
+}
+
+// Weapon fires a projectile at the nearest enemy with health in range
+// whenever it is loaded
+type Weapon struct {
+	Damage   int
+	Range    float32       // tiles
+	Speed    float32       // of the projectiles, tiles per second
+	Cooldown time.Duration // between two shots
+	reload   time.Duration // until the next shot
+}
+
+type Projectile struct {
+	Damage int
+	Source ID      // entity that fired it
+	Left   float32 // tiles it flies before it drops
+}
+
+// Occupier lets an entity occupy the enemy tile it holds, see the server's
+// capture rules
+type Occupier struct {
+	Holding time.Duration // time the current tile was held uncontested
+}
+
+// Moving reports whether the entity has a velocity
+func (e *Entity) Moving() bool {
+	return e.VelX != 0 || e.VelY != 0
+}
+
+// Copy returns a copy of the entity that shares no components with it
+func (e *Entity) Copy() Entity {
+	c := *e
+	if e.Player != nil {
+		player := *e.Player
+		c.Player = &player
+	}
+	if e.Body != nil {
+		body := *e.Body
+		body.Route = slices.Clone(e.Body.Route)
+		c.Body = &body
+	}
+	if e.Health != nil {
+		health := *e.Health
+		c.Health = &health
+	}
+	if e.Weapon != nil {
+		weapon := *e.Weapon
+		c.Weapon = &weapon
+	}
+	if e.Projectile != nil {
+		projectile := *e.Projectile
+		c.Projectile = &projectile
+	}
+	if e.Occupier != nil {
+		occupier := *e.Occupier
+		c.Occupier = &occupier
+	}
+	return c
+}
+
+// World holds the entities of the game. It is not safe for concurrent use,
+// the server guards it with its lock.
+//
+// Entities are indexed by the square cell of the map they are in so Near
+// only looks at the cells around a position. Entities in the world must
+// therefore be moved with Move, not by setting their position.
+type World struct {
+	entities map[ID]*Entity
+	nextID   ID
+
+	cellSize int
+	cells    map[cell]map[ID]*Entity
+	cellOf   map[ID]cell
+
+	// changes since the last call to Changes
+	added   []ID
+	removed []Entity
+}
+
+// cell is the position of a cell of the index in cells, not in tiles
+type cell struct {
+	x, y int
+}
+
+// NewWorld returns an empty world whose index uses cells of cellSize
+// tiles, the server uses its chunk size
+func NewWorld(cellSize int) *World {
+	return &World{
+		entities: make(map[ID]*Entity),
+		cellSize: cellSize,
+		cells:    make(map[cell]map[ID]*Entity),
+		cellOf:   make(map[ID]cell),
+	}
+}
+
+// cellAt returns the cell holding the position x,y
+func (w *World) cellAt(x, y float32) cell {
+	size := float64(w.cellSize)
+	return cell{int(math.Floor(float64(x) / size)), int(math.Floor(float64(y) / size))}
+}
+
+// index puts an entity into the cell of its position
+func (w *World) index(e *Entity) {
+	c := w.cellAt(e.X, e.Y)
+	if old, ok := w.cellOf[e.ID]; ok {
+		if old == c {
+			return
+		}
+		w.unindex(e.ID)
+	}
+	entities, ok := w.cells[c]
+	if !ok {
+		entities = make(map[ID]*Entity)
+		w.cells[c] = entities
+	}
+	entities[e.ID] = e
+	w.cellOf[e.ID] = c
+}
+
+// unindex takes an entity out of its cell
+func (w *World) unindex(id ID) {
+	c, ok := w.cellOf[id]
+	if !ok {
+		return
+	}
+	delete(w.cells[c], id)
+	if len(w.cells[c]) == 0 {
+		delete(w.cells, c)
+	}
+	delete(w.cellOf, id)
+}
+
+// Add places an entity in the world under a new ID and returns it
+func (w *World) Add(e Entity) *Entity {
+	w.nextID++
+	e.ID = w.nextID
+	entity := &e
+	w.entities[e.ID] = entity
+	w.index(entity)
+	w.added = append(w.added, e.ID)
+	return entity
+}
+
+// Move sets the position of an entity in the world
+func (w *World) Move(e *Entity, x, y float32) {
+	e.X, e.Y = x, y
+	if _, ok := w.entities[e.ID]; ok {
+		w.index(e)
+	}
+}
+
+// Get returns the entity with the given ID, nil if there is none
+func (w *World) Get(id ID) *Entity {
+	return w.entities[id]
+}
+
+// Remove takes an entity out of the world
+func (w *World) Remove(id ID) {
+	e, ok := w.entities[id]
+	if !ok {
+		return
+	}
+	delete(w.entities, id)
+	w.unindex(id)
+	w.removed = append(w.removed, *e)
+}
+
+// Len returns the number of entities
+func (w *World) Len() int {
+	return len(w.entities)
+}
+
+// All returns every entity in no particular order
+func (w *World) All() []*Entity {
+	all := make([]*Entity, 0, len(w.entities))
+	for _, e := range w.entities {
+		all = append(all, e)
+	}
+	return all
+}
+
+// Near returns the entities within radius tiles of x,y
+func (w *World) Near(x, y, radius float32) []*Entity {
+	near := make([]*Entity, 0)
+	add := func(entities map[ID]*Entity) {
+		for _, e := range entities {
+			dx, dy := e.X-x, e.Y-y
+			if dx*dx+dy*dy <= radius*radius {
+				near = append(near, e)
+			}
+		}
+	}
+
+	from, to := w.cellAt(x-radius, y-radius), w.cellAt(x+radius, y+radius)
+	// a radius over most of the map is cheaper to check cell by cell
+	if (to.x-from.x+1)*(to.y-from.y+1) > len(w.cells) {
+		for c, entities := range w.cells {
+			if c.x >= from.x && c.x <= to.x && c.y >= from.y && c.y <= to.y {
+				add(entities)
+			}
+		}
+		return near
+	}
+	for cx := from.x; cx <= to.x; cx++ {
+		for cy := from.y; cy <= to.y; cy++ {
+			add(w.cells[cell{cx, cy}])
+		}
+	}
+	return near
+}
+
+// Changes returns the entities added since the last call that are still in
+// the world and the last state of those removed since
+func (w *World) Changes() ([]ID, []Entity) {
+	added := make([]ID, 0, len(w.added))
+	for _, id := range w.added {
+		if _, ok := w.entities[id]; ok {
+			added = append(added, id)
+		}
+	}
+	removed := w.removed
+	w.added, w.removed = nil, nil
+	return added, removed
+}
@@ -0,0 +1,156 @@
+package entity
+
+import (
+	"projectt/game/pathfinding"
+	"projectt/types"
+	"slices"
+	"testing"
+	"time"
+)
+
+const tick = 10 * time.Millisecond
+
+// open is a 20x20 map of ground with a wall at x = 10 for y < 10
+func open(x, y int) (types.TileType, bool) {
+	if x < 0 || y < 0 || x >= 20 || y >= 20 {
+		return 0, false
+	}
+	if x == 10 && y < 10 {
+		return types.TileTypeWater, true
+	}
+	return types.TileTypeGround, true
+}
+
+func soldier(country uint8, x, y float32) Entity {
+	return Entity{
+		Type:    types.EntityTypeSoldier,
+		X:       x,
+		Y:       y,
+		Country: country,
+		Body:    &Body{UnitType: types.UnitTypeInfantry, Speed: 5},
+		Health:  &Health{Current: 100, Max: 100},
+	}
+}
+
+func TestWorldChanges(t *testing.T) {
+	w := NewWorld(16)
+	a := w.Add(soldier(1, 1, 1))
+	b := w.Add(soldier(1, 2, 2))
+	w.Remove(b.ID)
+
+	added, removed := w.Changes()
+	if !slices.Equal(added, []ID{a.ID}) || len(removed) != 1 || removed[0].ID != b.ID {
+		t.Fatalf("unexpected changes %v, %v", added, removed)
+	}
+	if added, removed := w.Changes(); len(added) != 0 || len(removed) != 0 {
+		t.Fatal("changes were not reset")
+	}
+	if near := w.Near(0, 0, 2); len(near) != 1 || near[0] != a {
+		t.Fatalf("unexpected entities near the corner %v", near)
+	}
+
+	c := a.Copy()
+	c.Health.Current = 0
+	if a.Health.Current != 100 {
+		t.Fatal("copy shares its health with the entity")
+	}
+}
+
+func TestWorldNear(t *testing.T) {
+	w := NewWorld(16)
+	a := w.Add(soldier(1, 15.5, 15.5))
+	b := w.Add(soldier(1, 16.5, 16.5)) // in the next cell
+	c := w.Add(soldier(1, 100, 100))
+	d := w.Add(soldier(1, -0.5, 15.5)) // off the map, left of the first cell
+
+	ids := func(entities []*Entity) []ID {
+		found := make([]ID, 0, len(entities))
+		for _, e := range entities {
+			found = append(found, e.ID)
+		}
+		slices.Sort(found)
+		return found
+	}
+	if near := ids(w.Near(16, 16, 1)); !slices.Equal(near, []ID{a.ID, b.ID}) {
+		t.Fatalf("unexpected entities across the cell border %v", near)
+	}
+	if near := ids(w.Near(0, 15.5, 1)); !slices.Equal(near, []ID{d.ID}) {
+		t.Fatalf("unexpected entities left of the map %v", near)
+	}
+
+	// moved entities are found at their new position only
+	w.Move(c, 17, 15)
+	w.Remove(b.ID)
+	if near := ids(w.Near(16, 16, 2)); !slices.Equal(near, []ID{a.ID, c.ID}) {
+		t.Fatalf("unexpected entities after moving %v", near)
+	}
+	if near := w.Near(100, 100, 5); len(near) != 0 {
+		t.Fatalf("entity still found where it was %v", near)
+	}
+	if near := ids(w.Near(0, 0, 1000)); !slices.Equal(near, []ID{a.ID, c.ID, d.ID}) {
+		t.Fatalf("unexpected entities in a radius over the map %v", near)
+	}
+}
+
+func TestBodyFollowsRoute(t *testing.T) {
+	w := NewWorld(16)
+	e := w.Add(soldier(1, 5.5, 12.5))
+	e.Body.Route = []pathfinding.Point{{X: 15, Y: 12}}
+
+	for range 300 {
+		w.Step(open, tick)
+	}
+	if int(e.X) != 15 || int(e.Y) != 12 || e.Body.Route != nil || e.Moving() {
+		t.Fatalf("entity stopped at %v,%v with route %v", e.X, e.Y, e.Body.Route)
+	}
+
+	// a route into the wall is given up where the wall stops the entity
+	w.Move(e, 5.5, 5.5)
+	e.Body.Route = []pathfinding.Point{{X: 15, Y: 5}}
+	for range 300 {
+		w.Step(open, tick)
+	}
+	if e.X >= 10 || e.Body.Route != nil {
+		t.Fatalf("entity went through the wall to %v,%v", e.X, e.Y)
+	}
+}
+
+func TestWeaponsFireUntilKilled(t *testing.T) {
+	w := NewWorld(16)
+	shooter := soldier(1, 2.5, 15.5)
+	shooter.Weapon = &Weapon{Damage: 40, Range: 6, Speed: 20, Cooldown: 100 * time.Millisecond}
+	w.Add(shooter)
+	target := w.Add(soldier(2, 6.5, 15.5))
+	w.Add(soldier(1, 4.5, 15.5)) // friendly, shot through
+	w.Changes()
+
+	w.Step(open, tick)
+	added, _ := w.Changes()
+	if len(added) != 1 || w.Get(added[0]).Type != types.EntityTypeProjectile {
+		t.Fatalf("expected a shot, got %v", added)
+	}
+
+	for range 100 {
+		w.Step(open, tick)
+	}
+	if w.Get(target.ID) != nil {
+		t.Fatalf("target survived with %d health", target.Health.Current)
+	}
+	_, removed := w.Changes()
+	if !slices.ContainsFunc(removed, func(e Entity) bool { return e.ID == target.ID }) {
+		t.Fatal("killed target was not reported")
+	}
+
+	// with no one left to shoot the shots drop out of range
+	for range 100 {
+		w.Step(open, tick)
+	}
+	for _, e := range w.All() {
+		if e.Projectile != nil {
+			t.Fatalf("projectile still flying at %v,%v", e.X, e.Y)
+		}
+	}
+	if w.Len() != 2 {
+		t.Fatalf("expected the two soldiers of country 1, got %d entities", w.Len())
+	}
+}
@@ -0,0 +1,159 @@
+package entity
+
+import (
+	"math"
+	"projectt/game/movement"
+	"projectt/game/pathfinding"
+	"projectt/types"
+	"time"
+)
+
+// HitRadius is how close a projectile has to come to an entity to hit it
+const HitRadius = 0.5
+
+// Step advances the world by dt: bodies follow their routes, loaded weapons
+// fire and projectiles fly and hit. Entities whose health runs out are
+// removed. Players are left alone, the server moves them.
+func (w *World) Step(grid movement.Grid, dt time.Duration) {
+	for _, e := range w.entities {
+		if e.Body != nil && e.Player == nil {
+			w.move(e, grid, dt)
+		}
+	}
+
+	// shots enter the world after the others had their turn so they fly
+	// from the next tick on
+	shots := make([]Entity, 0)
+	for _, e := range w.entities {
+		if e.Weapon != nil {
+			if shot, ok := w.fire(e, dt); ok {
+				shots = append(shots, shot)
+			}
+		}
+	}
+
+	for _, e := range w.entities {
+		if e.Projectile != nil {
+			w.fly(e, grid, dt)
+		}
+	}
+	for _, shot := range shots {
+		w.Add(shot)
+	}
+}
+
+// move takes an entity along its route. A route blocked by a changed tile
+// is given up.
+func (w *World) move(e *Entity, grid movement.Grid, dt time.Duration) {
+	distance := e.Body.Speed * float32(dt.Seconds())
+	route, dx, dy := pathfinding.Steer(e.Body.Route, e.X, e.Y, distance)
+	e.Body.Route = route
+	if route == nil {
+		e.VelX, e.VelY = 0, 0
+		return
+	}
+
+	magnitude := float32(math.Sqrt(float64(dx*dx + dy*dy)))
+	e.VelX, e.VelY = dx/magnitude*e.Body.Speed, dy/magnitude*e.Body.Speed
+	fromX, fromY := e.X, e.Y
+	x, y := movement.Step(grid, e.Body.UnitType, e.X, e.Y, dx/magnitude*distance, dy/magnitude*distance)
+	w.Move(e, x, y)
+
+	movedX, movedY := e.X-fromX, e.Y-fromY
+	if movedX*movedX+movedY*movedY < distance*distance/16 {
+		e.Body.Route = nil
+		e.VelX, e.VelY = 0, 0
+	}
+}
+
+// fire returns a projectile aimed at the nearest enemy in range if the
+// weapon of e is loaded
+func (w *World) fire(e *Entity, dt time.Duration) (Entity, bool) {
+	weapon := e.Weapon
+	if weapon.reload > 0 {
+		weapon.reload -= dt
+		if weapon.reload > 0 {
+			return Entity{}, false
+		}
+	}
+
+	var target *Entity
+	nearest := weapon.Range * weapon.Range
+	for _, other := range w.Near(e.X, e.Y, weapon.Range) {
+		if other.Health == nil || other.Country == e.Country || other.Country == 0 {
+			continue
+		}
+		dx, dy := other.X-e.X, other.Y-e.Y
+		if d := dx*dx + dy*dy; d <= nearest {
+			target, nearest = other, d
+		}
+	}
+	if target == nil {
+		return Entity{}, false
+	}
+
+	weapon.reload = weapon.Cooldown
+	dx, dy := target.X-e.X, target.Y-e.Y
+	magnitude := float32(math.Sqrt(float64(dx*dx + dy*dy)))
+	if magnitude == 0 {
+		dx, magnitude = 1, 1
+	}
+	return Entity{
+		Type:    types.EntityTypeProjectile,
+		X:       e.X,
+		Y:       e.Y,
+		VelX:    dx / magnitude * weapon.Speed,
+		VelY:    dy / magnitude * weapon.Speed,
+		Country: e.Country,
+		Projectile: &Projectile{
+			Damage: weapon.Damage,
+			Source: e.ID,
+			// a little further than the range so targets running away
+			// can still be hit
+			Left: weapon.Range + HitRadius,
+		},
+	}, true
+}
+
+// fly moves a projectile in a straight line over everything on the map. It
+// hits the first enemy with health it passes and drops once out of range or
+// off the map.
+func (w *World) fly(e *Entity, grid movement.Grid, dt time.Duration) {
+	seconds := float32(dt.Seconds())
+	speed := float32(math.Sqrt(float64(e.VelX*e.VelX + e.VelY*e.VelY)))
+	distance := min(speed*seconds, e.Projectile.Left)
+	steps := max(1, int(math.Ceil(float64(distance/HitRadius))))
+
+	// sub-steps keep fast projectiles from passing through targets
+	for range steps {
+		w.Move(e, e.X+e.VelX/speed*distance/float32(steps), e.Y+e.VelY/speed*distance/float32(steps))
+		if target := w.hit(e); target != nil {
+			target.Health.Current -= e.Projectile.Damage
+			if target.Health.Current <= 0 {
+				w.Remove(target.ID)
+			}
+			w.Remove(e.ID)
+			return
+		}
+	}
+
+	e.Projectile.Left -= distance
+	_, onMap := grid(int(math.Floor(float64(e.X))), int(math.Floor(float64(e.Y))))
+	if e.Projectile.Left <= 0 || !onMap {
+		w.Remove(e.ID)
+	}
+}
+
+// hit returns an enemy with health within the hit radius of a projectile
+func (w *World) hit(p *Entity) *Entity {
+	for _, e := range w.Near(p.X, p.Y, HitRadius) {
+		if e.Health == nil || e.Country == p.Country || e.ID == p.Projectile.Source {
+			continue
+		}
+		dx, dy := e.X-p.X, e.Y-p.Y
+		if dx*dx+dy*dy <= HitRadius*HitRadius {
+			return e
+		}
+	}
+	return nil
+}
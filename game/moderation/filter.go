@@ -0,0 +1,54 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Filter masks blocked words in chat messages. Words are matched whole and
+// case-insensitively, so blocking "ass" leaves "class" alone.
+type Filter struct {
+	words map[string]struct{}
+}
+
+// NewFilter returns a filter for the given words. Blank entries are ignored.
+func NewFilter(words []string) *Filter {
+	f := &Filter{words: make(map[string]struct{}, len(words))}
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" {
+			f.words[word] = struct{}{}
+		}
+	}
+	return f
+}
+
+// Clean returns text with every blocked word replaced by asterisks
+func (f *Filter) Clean(text string) string {
+	if len(f.words) == 0 {
+		return text
+	}
+
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		if _, blocked := f.words[strings.ToLower(string(runes[start:end]))]; blocked {
+			for i := start; i < end; i++ {
+				runes[i] = '*'
+			}
+		}
+		start = end
+	}
+	return string(runes)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
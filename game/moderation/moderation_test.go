@@ -0,0 +1,47 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilterClean(t *testing.T) {
+	f := NewFilter([]string{"darn", " Heck ", "", "şey"})
+
+	tests := []struct {
+		in, want string
+	}{
+		{"hello world", "hello world"},
+		{"darn it", "**** it"},
+		{"HECK, what the heck!", "****, what the ****!"},
+		{"darnation is fine", "darnation is fine"},
+		{"bu şey olmaz", "bu *** olmaz"},
+	}
+	for _, tt := range tests {
+		if got := f.Clean(tt.in); got != tt.want {
+			t.Errorf("Clean(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRepeatGuard(t *testing.T) {
+	start := time.Unix(0, 0)
+	g := NewRepeatGuard(2, 10*time.Second)
+
+	if !g.Allow("buy gold", start) || !g.Allow("Buy  GOLD", start.Add(time.Second)) {
+		t.Fatal("first repeats were refused")
+	}
+	if g.Allow("buy gold", start.Add(2*time.Second)) {
+		t.Fatal("third repeat was allowed")
+	}
+	if !g.Allow("something else", start.Add(3*time.Second)) {
+		t.Fatal("different message was refused")
+	}
+	if !g.Allow("something else", start.Add(20*time.Second)) || !g.Allow("something else", start.Add(40*time.Second)) {
+		t.Fatal("repeats outside the window were refused")
+	}
+
+	if disabled := NewRepeatGuard(0, time.Minute); !disabled.Allow("x", start) || !disabled.Allow("x", start) {
+		t.Fatal("disabled guard refused a message")
+	}
+}
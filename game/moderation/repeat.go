@@ -0,0 +1,42 @@
+package moderation
+
+import (
+	"strings"
+	"time"
+)
+
+// RepeatGuard detects a player sending the same message over and over. It
+// is not safe for concurrent use.
+type RepeatGuard struct {
+	max    int
+	window time.Duration
+
+	last  string
+	count int
+	at    time.Time
+}
+
+// NewRepeatGuard allows the same message at most max times in a row while
+// less than window passes between them. A max of 0 disables the check.
+func NewRepeatGuard(max int, window time.Duration) *RepeatGuard {
+	return &RepeatGuard{max: max, window: window}
+}
+
+// Allow records text and reports whether it may be sent
+func (g *RepeatGuard) Allow(text string, now time.Time) bool {
+	if g.max <= 0 {
+		return true
+	}
+
+	// Ignore case and spacing so trivial variations count as repeats
+	normalized := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	if normalized == g.last && now.Sub(g.at) < g.window {
+		g.count++
+	} else {
+		g.last = normalized
+		g.count = 1
+	}
+	g.at = now
+
+	return g.count <= g.max
+}
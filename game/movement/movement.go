@@ -0,0 +1,82 @@
+// Package movement moves units across the tile grid with swept collision.
+//
+// A step is split into sub-steps shorter than a tile so fast units cannot
+// skip over thin obstacles. Each sub-step resolves X and Y separately, so a
+// unit running diagonally into a coastline keeps sliding along it instead of
+// stopping.
+package movement
+
+import (
+	"math"
+	"projectt/types"
+)
+
+// maxSubstep is the longest distance in tiles moved before collisions are
+// checked again. It must stay below one tile so every tile crossed is seen.
+const maxSubstep = 0.5
+
+// Grid looks up the type of the tile at x,y. It reports false for tiles
+// outside the map, which are never passable.
+type Grid func(x, y int) (types.TileType, bool)
+
+// Step moves a unit at x,y by dx,dy and returns where it ends up. Movement
+// along an axis stops at the edge of the first tile the unit may not enter,
+// while movement along the other axis goes on. A unit standing on a tile it
+// may not enter, e.g. after being teleported there, moves freely until it
+// is back on passable ground.
+func Step(grid Grid, unit types.UnitType, x, y, dx, dy float32) (float32, float32) {
+	distance := math.Hypot(float64(dx), float64(dy))
+	if distance == 0 {
+		return x, y
+	}
+	steps := int(math.Ceil(distance / maxSubstep))
+	sx, sy := dx/float32(steps), dy/float32(steps)
+
+	blockedX, blockedY := sx == 0, sy == 0
+	for i := 0; i < steps && !(blockedX && blockedY); i++ {
+		if !blockedX {
+			if canEnter(grid, unit, x, y, x+sx, y) {
+				x += sx
+			} else {
+				x, blockedX = edge(x, sx), true
+			}
+		}
+		if !blockedY {
+			if canEnter(grid, unit, x, y, x, y+sy) {
+				y += sy
+			} else {
+				y, blockedY = edge(y, sy), true
+			}
+		}
+	}
+	return x, y
+}
+
+// edge returns the coordinate closest to the tile boundary crossed by moving
+// from pos by delta while staying on the tile of pos
+func edge(pos, delta float32) float32 {
+	tile := float32(math.Floor(float64(pos)))
+	if delta < 0 {
+		return tile
+	}
+	return math.Nextafter32(tile+1, tile)
+}
+
+func canEnter(grid Grid, unit types.UnitType, fromX, fromY, toX, toY float32) bool {
+	to, ok := tileAt(grid, toX, toY)
+	if !ok {
+		return false
+	}
+	if Passable(unit, to) {
+		return true
+	}
+	from, ok := tileAt(grid, fromX, fromY)
+	return ok && !Passable(unit, from)
+}
+
+func tileAt(grid Grid, x, y float32) (types.TileType, bool) {
+	if x < 0 || y < 0 {
+		return 0, false
+	}
+	return grid(int(x), int(y))
+}
@@ -0,0 +1,125 @@
+package movement
+
+import (
+	"math"
+	"projectt/types"
+	"testing"
+)
+
+func near(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-4
+}
+
+// testGrid parses rows of '.' (ground), '~' (water) and '#' (buildings),
+// row 0 first
+func testGrid(rows ...string) Grid {
+	return func(x, y int) (types.TileType, bool) {
+		if y >= len(rows) || x >= len(rows[y]) {
+			return 0, false
+		}
+		switch rows[y][x] {
+		case '~':
+			return types.TileTypeWater, true
+		case '#':
+			return types.TileTypeBuilding, true
+		}
+		return types.TileTypeGround, true
+	}
+}
+
+func TestPassable(t *testing.T) {
+	tests := []struct {
+		unit types.UnitType
+		tile types.TileType
+		want bool
+	}{
+		{types.UnitTypeInfantry, types.TileTypeGround, true},
+		{types.UnitTypeInfantry, types.TileTypeWater, false},
+		{types.UnitTypeTank, types.TileTypeWater, false},
+		{types.UnitTypeShip, types.TileTypeWater, true},
+		{types.UnitTypeBattleShip, types.TileTypeGround, false},
+		// buildings block ground units but not aircraft
+		{types.UnitTypeInfantry, types.TileTypeBuilding, false},
+		{types.UnitTypeTank, types.TileTypeBuilding, false},
+		{types.UnitTypeHelicopter, types.TileTypeBuilding, true},
+		{types.UnitTypeHelicopter, types.TileTypeWater, true},
+		{types.UnitTypeFighterJet, types.TileTypeBuilding, true},
+	}
+	for _, tt := range tests {
+		if got := Passable(tt.unit, tt.tile); got != tt.want {
+			t.Errorf("Passable(%d, %d) = %v, want %v", tt.unit, tt.tile, got, tt.want)
+		}
+	}
+}
+
+func TestStepSlidesAlongWalls(t *testing.T) {
+	grid := testGrid(
+		"....~",
+		"....~",
+		"....~",
+		"~~~~~",
+	)
+
+	// running diagonally into the water on the right keeps moving down
+	x, y := Step(grid, types.UnitTypeInfantry, 3.5, 0.5, 1, 1)
+	if x >= 4 || x < 3.99 || !near(y, 1.5) {
+		t.Fatalf("expected to slide down along x=4, got %v,%v", x, y)
+	}
+
+	// and into the corner it stops on both axes
+	x, y = Step(grid, types.UnitTypeInfantry, 3.5, 2.5, 1, 1)
+	if x >= 4 || y >= 3 || x < 3.99 || y < 2.99 {
+		t.Fatalf("expected to stop in the corner, got %v,%v", x, y)
+	}
+
+	// moving away from a wall is not blocked
+	x, y = Step(grid, types.UnitTypeInfantry, 3.9, 1.5, -1, 0)
+	if !near(x, 2.9) || y != 1.5 {
+		t.Fatalf("expected to move left, got %v,%v", x, y)
+	}
+}
+
+func TestStepDoesNotTunnel(t *testing.T) {
+	grid := testGrid("..~..")
+
+	// a step of three tiles would land on ground beyond the water
+	x, _ := Step(grid, types.UnitTypeTank, 0.5, 0.5, 3, 0)
+	if x >= 2 {
+		t.Fatalf("tank crossed the water to %v", x)
+	}
+
+	// ships are confined to water
+	x, _ = Step(grid, types.UnitTypeShip, 2.5, 0.5, 2, 0)
+	if x >= 3 {
+		t.Fatalf("ship sailed onto land at %v", x)
+	}
+}
+
+func TestStepOffMapAndStuck(t *testing.T) {
+	grid := testGrid("~..")
+
+	// the map edge blocks like an obstacle
+	x, _ := Step(grid, types.UnitTypeInfantry, 2.5, 0.5, 5, 0)
+	if x >= 3 {
+		t.Fatalf("moved off the map to %v", x)
+	}
+
+	// a unit standing in the water may walk back to land
+	x, _ = Step(grid, types.UnitTypeInfantry, 0.5, 0.5, 1, 0)
+	if !near(x, 1.5) {
+		t.Fatalf("stuck unit did not leave the water, x = %v", x)
+	}
+}
+
+func TestStepAircraft(t *testing.T) {
+	grid := testGrid(".#~#.")
+
+	x, _ := Step(grid, types.UnitTypeHelicopter, 0.5, 0.5, 4, 0)
+	if !near(x, 4.5) {
+		t.Fatalf("helicopter did not fly over buildings and water, x = %v", x)
+	}
+	x, _ = Step(grid, types.UnitTypeInfantry, 0.5, 0.5, 4, 0)
+	if x >= 1 {
+		t.Fatalf("infantry walked into a building, x = %v", x)
+	}
+}
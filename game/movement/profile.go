@@ -0,0 +1,108 @@
+package movement
+
+import (
+	"projectt/models"
+	"projectt/types"
+	"slices"
+	"time"
+)
+
+// Layer is the altitude a unit moves at. Tiles only block units on the
+// surface.
+type Layer uint8
+
+const (
+	LayerSurface Layer = iota // infantry, vehicles and ships
+	LayerLow                  // helicopters
+	LayerHigh                 // jets
+)
+
+// Profile describes how a unit type moves
+type Profile struct {
+	Layer Layer
+	// Tile types a surface unit may enter. Aircraft fly over every tile.
+	Passable []types.TileType
+	// How long an aircraft may fly before it runs on reserve, 0 for units
+	// that do not need fuel
+	MaxFlightTime time.Duration
+	// Tile types an aircraft refuels on while it rests there
+	Landing []types.TileType
+}
+
+// IsAircraft reports whether units of the profile fly
+func (p Profile) IsAircraft() bool {
+	return p.Layer != LayerSurface
+}
+
+const (
+	// Resting on a landing tile for refuelTime refills an empty tank
+	refuelTime = 20 * time.Second
+	minRest    = time.Second
+	// Fraction of its speed an aircraft keeps once out of fuel, enough to
+	// reach a landing tile
+	reserveSpeed = 0.25
+)
+
+// infantry is also the profile of players without a unit
+var infantry = Profile{Layer: LayerSurface, Passable: []types.TileType{types.TileTypeGround}}
+
+// profiles holds the movement profile of every unit type. Buildings block
+// all surface units, helicopters may land on them.
+var profiles = map[types.UnitType]Profile{
+	types.UnitTypeInfantry:   infantry,
+	types.UnitTypeTank:       {Layer: LayerSurface, Passable: []types.TileType{types.TileTypeGround}},
+	types.UnitTypeShip:       {Layer: LayerSurface, Passable: []types.TileType{types.TileTypeWater}},
+	types.UnitTypeBattleShip: {Layer: LayerSurface, Passable: []types.TileType{types.TileTypeWater}},
+	types.UnitTypeHelicopter: {
+		Layer:         LayerLow,
+		MaxFlightTime: 3 * time.Minute,
+		Landing:       []types.TileType{types.TileTypeGround, types.TileTypeBuilding},
+	},
+	types.UnitTypeFighterJet: {
+		Layer:         LayerHigh,
+		MaxFlightTime: 90 * time.Second,
+		Landing:       []types.TileType{types.TileTypeGround},
+	},
+}
+
+// ProfileOf returns the movement profile of a unit type. Unknown types
+// move like infantry.
+func ProfileOf(unit types.UnitType) Profile {
+	if profile, ok := profiles[unit]; ok {
+		return profile
+	}
+	return infantry
+}
+
+// Passable reports whether a unit of the given type may enter a tile
+func Passable(unit types.UnitType, tile types.TileType) bool {
+	profile := ProfileOf(unit)
+	return profile.IsAircraft() || slices.Contains(profile.Passable, tile)
+}
+
+// Fuel burns the fuel of an aircraft about to move for dt while over tile.
+// Time the aircraft rested on a landing tile since it last moved refuels it
+// first. It returns the fraction of its speed the unit may use, which drops
+// to a reserve once the flight time is used up. Units that do not need
+// fuel always get their full speed.
+func Fuel(unit *models.Unit, tile types.TileType, dt time.Duration, now time.Time) float32 {
+	profile := ProfileOf(unit.UnitType)
+	if profile.MaxFlightTime <= 0 {
+		return 1
+	}
+	maxFlightTime := float32(profile.MaxFlightTime.Seconds())
+
+	// Pauses between ticks are not rests, the aircraft has to stop
+	rested := now.Sub(unit.LastFlown)
+	if !unit.LastFlown.IsZero() && rested > minRest && slices.Contains(profile.Landing, tile) {
+		refueled := float32(rested.Seconds()) * maxFlightTime / float32(refuelTime.Seconds())
+		unit.FlightTime = max(0, unit.FlightTime-refueled)
+	}
+	unit.LastFlown = now.Add(dt)
+
+	if unit.FlightTime >= maxFlightTime {
+		return reserveSpeed
+	}
+	unit.FlightTime = min(maxFlightTime, unit.FlightTime+float32(dt.Seconds()))
+	return 1
+}
@@ -0,0 +1,62 @@
+package movement
+
+import (
+	"projectt/models"
+	"projectt/types"
+	"testing"
+	"time"
+)
+
+func TestProfileOf(t *testing.T) {
+	if ProfileOf(types.UnitTypeHelicopter).Layer != LayerLow || ProfileOf(types.UnitTypeFighterJet).Layer != LayerHigh {
+		t.Fatal("aircraft do not fly")
+	}
+	if ProfileOf(types.UnitTypeTank).IsAircraft() {
+		t.Fatal("tanks fly")
+	}
+	// unknown unit types move like infantry
+	if profile := ProfileOf(0); profile.IsAircraft() || !Passable(0, types.TileTypeGround) || Passable(0, types.TileTypeWater) {
+		t.Fatalf("unexpected profile for an unknown unit type: %+v", profile)
+	}
+}
+
+func TestFuel(t *testing.T) {
+	start := time.Unix(0, 0)
+	tick := 100 * time.Millisecond
+	jet := &models.Unit{UnitType: types.UnitTypeFighterJet}
+
+	// a full tank lasts 90 seconds of flight
+	now := start
+	for Fuel(jet, types.TileTypeWater, tick, now) == 1 && now.Sub(start) < time.Hour {
+		now = now.Add(tick)
+	}
+	if flown := now.Sub(start); flown < 89*time.Second || flown > 91*time.Second {
+		t.Fatalf("jet ran out of fuel after %v", flown)
+	}
+
+	// hovering over water does not refuel
+	now = now.Add(time.Minute)
+	if factor := Fuel(jet, types.TileTypeWater, tick, now); factor != reserveSpeed {
+		t.Fatal("jet refueled over water")
+	}
+
+	// resting on an airfield for half the refuel time refills half the tank
+	now = now.Add(tick + refuelTime/2)
+	Fuel(jet, types.TileTypeGround, tick, now)
+	if jet.FlightTime < 44 || jet.FlightTime > 46 {
+		t.Fatalf("expected about 45s of flight used after refueling, got %v", jet.FlightTime)
+	}
+
+	// jets cannot land on buildings
+	jet.FlightTime = 90
+	now = now.Add(time.Minute)
+	if factor := Fuel(jet, types.TileTypeBuilding, tick, now); factor != reserveSpeed {
+		t.Fatal("jet refueled on a building")
+	}
+
+	// ground units have no fuel
+	tank := &models.Unit{UnitType: types.UnitTypeTank}
+	if factor := Fuel(tank, types.TileTypeGround, time.Hour, now); factor != 1 || tank.FlightTime != 0 {
+		t.Fatal("tank used fuel")
+	}
+}
@@ -0,0 +1,267 @@
+package pathfinding
+
+import (
+	"container/heap"
+	"projectt/types"
+	"time"
+)
+
+// legChunks is the number of chunks a single tile search of a long route
+// crosses
+const legChunks = 4
+
+var chunkSteps = [4]Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+
+// chunkInfo summarizes a chunk for one unit type. The passable tiles of a
+// chunk are split into regions connected within the chunk, which are the
+// nodes of the chunk graph.
+type chunkInfo struct {
+	// region of every tile, row by row, 0 for tiles the unit cannot enter
+	regions []uint16
+}
+
+// region is a connected part of a chunk
+type region struct {
+	chunk Point
+	id    uint16
+}
+
+func (f *Finder) chunkOf(p Point) Point {
+	return Point{p.X / f.cfg.ChunkSize, p.Y / f.cfg.ChunkSize}
+}
+
+// regionOf returns the region of a tile, which is 0 if the tile is not
+// passable
+func (f *Finder) regionOf(unit types.UnitType, p Point) region {
+	chunk := f.chunkOf(p)
+	info := f.chunk(unit, chunk)
+	size := f.cfg.ChunkSize
+	return region{chunk: chunk, id: info.regions[(p.Y-chunk.Y*size)*size+p.X-chunk.X*size]}
+}
+
+// chunk returns the summary of a chunk, computing it on first use
+func (f *Finder) chunk(unit types.UnitType, chunk Point) chunkInfo {
+	f.mu.Lock()
+	info, ok := f.chunks[chunk][unit]
+	generation := f.generation
+	f.mu.Unlock()
+	if ok {
+		return info
+	}
+
+	// the grid is read without holding the lock
+	size := f.cfg.ChunkSize
+	origin := Point{chunk.X * size, chunk.Y * size}
+	passable := make([]bool, size*size)
+	for i := range passable {
+		passable[i] = f.passable(unit, Point{origin.X + i%size, origin.Y + i/size})
+	}
+
+	// flood fill the passable tiles, moving the way astar does
+	info.regions = make([]uint16, size*size)
+	var next uint16
+	for start := range passable {
+		if !passable[start] || info.regions[start] != 0 {
+			continue
+		}
+		next++
+		info.regions[start] = next
+		for stack := []int{start}; len(stack) > 0; {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%size, i/size
+			inside := func(x, y int) bool {
+				return x >= 0 && y >= 0 && x < size && y < size && passable[y*size+x]
+			}
+			for _, d := range neighbours {
+				nx, ny := x+d.X, y+d.Y
+				if !inside(nx, ny) || info.regions[ny*size+nx] != 0 {
+					continue
+				}
+				if d.X != 0 && d.Y != 0 && (!inside(x+d.X, y) || !inside(x, y+d.Y)) {
+					continue
+				}
+				info.regions[ny*size+nx] = next
+				stack = append(stack, ny*size+nx)
+			}
+		}
+	}
+
+	f.mu.Lock()
+	if f.generation == generation {
+		if f.chunks[chunk] == nil {
+			f.chunks[chunk] = make(map[types.UnitType]chunkInfo)
+		}
+		f.chunks[chunk][unit] = info
+	}
+	f.mu.Unlock()
+	return info
+}
+
+// links returns the regions of the neighbouring chunks a unit can step into
+// from r
+func (f *Finder) links(unit types.UnitType, r region) []region {
+	size := f.cfg.ChunkSize
+	info := f.chunk(unit, r.chunk)
+	origin := Point{r.chunk.X * size, r.chunk.Y * size}
+
+	var result []region
+	for _, step := range chunkSteps {
+		for i := range size {
+			// the tile on the edge facing step
+			p := Point{origin.X + i, origin.Y + i}
+			switch {
+			case step.X > 0:
+				p.X = origin.X + size - 1
+			case step.X < 0:
+				p.X = origin.X
+			case step.Y > 0:
+				p.Y = origin.Y + size - 1
+			default:
+				p.Y = origin.Y
+			}
+			if info.regions[(p.Y-origin.Y)*size+p.X-origin.X] != r.id {
+				continue
+			}
+			next := Point{p.X + step.X, p.Y + step.Y}
+			if !f.inBounds(next) {
+				continue
+			}
+			if neighbour := f.regionOf(unit, next); neighbour.id != 0 && !containsRegion(result, neighbour) {
+				result = append(result, neighbour)
+			}
+		}
+	}
+	return result
+}
+
+func containsRegion(regions []region, r region) bool {
+	for _, other := range regions {
+		if other == r {
+			return true
+		}
+	}
+	return false
+}
+
+// planChunks searches the chunk graph and returns the regions along a route
+// between two regions, both included
+func (f *Finder) planChunks(unit types.UnitType, from, to region, deadline time.Time) ([]region, bool) {
+	cameFrom := map[region]region{}
+	cost := map[region]float64{from: 0}
+	open := &regionQueue{}
+	heap.Push(open, &regionNode{region: from, priority: manhattan(from.chunk, to.chunk)})
+
+	for expanded := 0; open.Len() > 0; expanded++ {
+		if f.cfg.MaxNodes > 0 && expanded >= f.cfg.MaxNodes || expired(deadline) {
+			break
+		}
+		current := heap.Pop(open).(*regionNode)
+		if current.region == to {
+			route := []region{}
+			for r := to; r != from; r = cameFrom[r] {
+				route = append(route, r)
+			}
+			route = append(route, from)
+			for i, j := 0, len(route)-1; i < j; i, j = i+1, j-1 {
+				route[i], route[j] = route[j], route[i]
+			}
+			return route, true
+		}
+		if current.cost > cost[current.region] {
+			continue
+		}
+
+		for _, next := range f.links(unit, current.region) {
+			nextCost := current.cost + 1
+			if known, ok := cost[next]; ok && known <= nextCost {
+				continue
+			}
+			cost[next] = nextCost
+			cameFrom[next] = current.region
+			heap.Push(open, &regionNode{region: next, cost: nextCost, priority: nextCost + manhattan(next.chunk, to.chunk)})
+		}
+	}
+	return nil, false
+}
+
+// refine turns a route of regions into tiles. The tile search is confined to
+// the chunks along the route and their neighbours, and runs in legs towards
+// a tile near the centre of every few regions so each search stays small.
+// A refinement cut off by the deadline returns the tiles found so far.
+func (f *Finder) refine(unit types.UnitType, from, to Point, route []region, deadline time.Time) ([]Point, bool) {
+	corridor := map[Point]struct{}{}
+	for _, r := range route {
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				corridor[Point{r.chunk.X + dx, r.chunk.Y + dy}] = struct{}{}
+			}
+		}
+	}
+	inCorridor := func(p Point) bool {
+		_, ok := corridor[f.chunkOf(p)]
+		return ok
+	}
+
+	path := []Point{}
+	current := from
+	for i := legChunks; i < len(route)-1; i += legChunks {
+		goal := f.anchor(unit, route[i])
+		leg, ok := f.astar(unit, current, goal, inCorridor, deadline)
+		path = append(path, leg...)
+		if !ok {
+			return path, false
+		}
+		current = goal
+	}
+	leg, ok := f.astar(unit, current, to, inCorridor, deadline)
+	return append(path, leg...), ok
+}
+
+// anchor returns the tile of a region closest to the centre of its chunk
+func (f *Finder) anchor(unit types.UnitType, r region) Point {
+	size := f.cfg.ChunkSize
+	info := f.chunk(unit, r.chunk)
+	origin := Point{r.chunk.X * size, r.chunk.Y * size}
+	centre := Point{origin.X + size/2, origin.Y + size/2}
+
+	var best Point
+	found := false
+	for i, id := range info.regions {
+		p := Point{origin.X + i%size, origin.Y + i/size}
+		if id == r.id && (!found || octile(p, centre) < octile(best, centre)) {
+			best, found = p, true
+		}
+	}
+	return best
+}
+
+// manhattan is the least number of steps between two chunks
+func manhattan(a, b Point) float64 {
+	return float64(abs(a.X-b.X) + abs(a.Y-b.Y))
+}
+
+type regionNode struct {
+	region   region
+	cost     float64
+	priority float64
+}
+
+// regionQueue is a min-heap of regions by priority
+type regionQueue []*regionNode
+
+func (q regionQueue) Len() int { return len(q) }
+func (q regionQueue) Less(i, j int) bool {
+	if q[i].priority == q[j].priority {
+		return q[i].cost > q[j].cost
+	}
+	return q[i].priority < q[j].priority
+}
+func (q regionQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *regionQueue) Push(x any)   { *q = append(*q, x.(*regionNode)) }
+func (q *regionQueue) Pop() any {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
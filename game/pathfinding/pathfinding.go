@@ -0,0 +1,452 @@
+// Package pathfinding finds routes across the tile map for a unit type.
+//
+// Short routes are searched with A* on tiles. Long routes are first planned
+// on a coarse graph whose nodes are the connected regions of fixed size
+// chunks of the map. The tiles are then searched leg by leg along that plan,
+// confined to a corridor of chunks around it. This keeps searches across the
+// whole world cheap. Chunk summaries and found paths are cached until the
+// tiles they were computed from change.
+package pathfinding
+
+import (
+	"container/heap"
+	"container/list"
+	"errors"
+	"math"
+	"projectt/game/movement"
+	"projectt/types"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoPath is returned when the goal cannot be reached, or not within
+	// the search budget
+	ErrNoPath = errors.New("no path")
+	// ErrOutOfBounds is returned for points outside the map
+	ErrOutOfBounds = errors.New("point outside the map")
+
+	// errDeadline is returned by search with the part of a route found
+	// before the deadline
+	errDeadline = errors.New("search deadline passed")
+)
+
+// Point is a tile coordinate
+type Point struct {
+	X, Y int
+}
+
+// Config sets the size of the map and of the searches
+type Config struct {
+	Width, Height int
+	ChunkSize     int
+	// Tiles expanded by a single tile search before it gives up
+	MaxNodes int
+	// Routes whose ends are at least this many chunks apart are planned on
+	// the chunk graph first
+	HierarchyDistance int
+	// Paths kept in the cache
+	CacheSize int
+}
+
+// Finder computes paths. It is safe for concurrent use. The grid may be
+// read from several goroutines at once and must not call back into the
+// Finder.
+type Finder struct {
+	cfg  Config
+	grid movement.Grid
+
+	mu sync.Mutex
+	// generation is increased by every tile change, so results computed
+	// from older tiles are not cached
+	generation uint64
+	chunks     map[Point]map[types.UnitType]chunkInfo
+	paths      map[pathKey]*list.Element
+	lru        *list.List // of *pathEntry, most recently used first
+}
+
+type pathKey struct {
+	unit     types.UnitType
+	from, to Point
+}
+
+type pathEntry struct {
+	key  pathKey
+	path []Point
+}
+
+// New returns a finder reading tiles from grid
+func New(grid movement.Grid, cfg Config) *Finder {
+	return &Finder{
+		cfg:    cfg,
+		grid:   grid,
+		chunks: make(map[Point]map[types.UnitType]chunkInfo),
+		paths:  make(map[pathKey]*list.Element),
+		lru:    list.New(),
+	}
+}
+
+// Find returns the waypoints a unit of the given type passes on its way
+// from one tile to another. The unit can move in a straight line from the
+// centre of one waypoint to the next, the last one is the goal. The start
+// tile is not included. Aircraft fly straight.
+func (f *Finder) Find(unit types.UnitType, from, to Point) ([]Point, error) {
+	return f.FindBefore(unit, from, to, time.Time{})
+}
+
+// FindBefore is Find with a deadline. A search still running at the
+// deadline returns the way to the tile closest to the goal it reached, tile
+// by tile, so the unit gets closer and the next search is shorter. If it got
+// nowhere it fails with ErrNoPath. Smoothing stops at the deadline as well.
+// Routes cut short either way are not cached. The zero time means no
+// deadline.
+func (f *Finder) FindBefore(unit types.UnitType, from, to Point, deadline time.Time) ([]Point, error) {
+	if !f.inBounds(from) || !f.inBounds(to) {
+		return nil, ErrOutOfBounds
+	}
+	if from == to {
+		return []Point{}, nil
+	}
+	if movement.ProfileOf(unit).IsAircraft() {
+		return []Point{to}, nil
+	}
+	if !f.passable(unit, to) {
+		return nil, ErrNoPath
+	}
+
+	key := pathKey{unit: unit, from: from, to: to}
+	f.mu.Lock()
+	if element, ok := f.paths[key]; ok {
+		f.lru.MoveToFront(element)
+		path := element.Value.(*pathEntry).path
+		f.mu.Unlock()
+		return clonePath(path), nil
+	}
+	generation := f.generation
+	f.mu.Unlock()
+
+	path, err := f.search(unit, from, to, deadline)
+	if errors.Is(err, errDeadline) {
+		return path, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	path, smoothed := f.smooth(unit, from, path, deadline)
+
+	f.mu.Lock()
+	if f.generation == generation && smoothed {
+		f.store(key, path)
+	}
+	f.mu.Unlock()
+	return clonePath(path), nil
+}
+
+// Invalidate drops everything computed from the tile at x,y. It must be
+// called whenever a tile changes.
+func (f *Finder) Invalidate(x, y int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.generation++
+	delete(f.chunks, f.chunkOf(Point{x, y}))
+	clear(f.paths)
+	f.lru.Init()
+}
+
+// CachedPaths returns the number of paths in the cache
+func (f *Finder) CachedPaths() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.paths)
+}
+
+func (f *Finder) store(key pathKey, path []Point) {
+	if f.cfg.CacheSize <= 0 {
+		return
+	}
+	if _, ok := f.paths[key]; ok {
+		return
+	}
+	f.paths[key] = f.lru.PushFront(&pathEntry{key: key, path: path})
+	for f.lru.Len() > f.cfg.CacheSize {
+		oldest := f.lru.Back()
+		f.lru.Remove(oldest)
+		delete(f.paths, oldest.Value.(*pathEntry).key)
+	}
+}
+
+// search returns every tile of a route, start excluded. A search cut off
+// by the deadline returns the tiles found so far with errDeadline.
+func (f *Finder) search(unit types.UnitType, from, to Point, deadline time.Time) ([]Point, error) {
+	fromChunk, toChunk := f.chunkOf(from), f.chunkOf(to)
+	if f.cfg.HierarchyDistance > 0 && chebyshev(fromChunk, toChunk) >= f.cfg.HierarchyDistance {
+		// units stuck on a tile they may not enter have no region and
+		// are searched on tiles
+		if start := f.regionOf(unit, from); start.id != 0 {
+			route, ok := f.planChunks(unit, start, f.regionOf(unit, to), deadline)
+			if !ok {
+				return nil, ErrNoPath
+			}
+			path, ok := f.refine(unit, from, to, route, deadline)
+			if ok {
+				return path, nil
+			}
+			if expired(deadline) {
+				return cutOff(path)
+			}
+		}
+	}
+
+	path, ok := f.astar(unit, from, to, nil, deadline)
+	if ok {
+		return path, nil
+	}
+	if expired(deadline) {
+		return cutOff(path)
+	}
+	return nil, ErrNoPath
+}
+
+// cutOff returns the part of a route a search found before its deadline
+func cutOff(path []Point) ([]Point, error) {
+	if len(path) == 0 {
+		return nil, ErrNoPath
+	}
+	return path, errDeadline
+}
+
+func (f *Finder) inBounds(p Point) bool {
+	return p.X >= 0 && p.Y >= 0 && p.X < f.cfg.Width && p.Y < f.cfg.Height
+}
+
+func (f *Finder) passable(unit types.UnitType, p Point) bool {
+	if !f.inBounds(p) {
+		return false
+	}
+	tile, ok := f.grid(p.X, p.Y)
+	return ok && movement.Passable(unit, tile)
+}
+
+var neighbours = [8]Point{
+	{1, 0}, {-1, 0}, {0, 1}, {0, -1},
+	{1, 1}, {1, -1}, {-1, 1}, {-1, -1},
+}
+
+// astar searches tiles from start to goal. allowed, if set, confines the
+// search. The start tile itself does not need to be passable, so units
+// stuck on a tile they may not enter can leave it. A search cut off by the
+// deadline returns the way to the tile closest to the goal it expanded.
+func (f *Finder) astar(unit types.UnitType, start, goal Point, allowed func(Point) bool, deadline time.Time) ([]Point, bool) {
+	cameFrom := map[Point]Point{}
+	cost := map[Point]float64{start: 0}
+	open := &nodeQueue{}
+	heap.Push(open, &node{point: start, priority: octile(start, goal)})
+
+	passable := map[Point]bool{}
+	canEnter := func(p Point) bool {
+		if allowed != nil && !allowed(p) {
+			return false
+		}
+		ok, seen := passable[p]
+		if !seen {
+			ok = f.passable(unit, p)
+			passable[p] = ok
+		}
+		return ok
+	}
+
+	closest := start
+	for expanded := 0; open.Len() > 0; expanded++ {
+		if f.cfg.MaxNodes > 0 && expanded >= f.cfg.MaxNodes {
+			return nil, false
+		}
+		if expired(deadline) {
+			return reconstruct(cameFrom, start, closest), false
+		}
+		current := heap.Pop(open).(*node)
+		if current.point == goal {
+			return reconstruct(cameFrom, start, goal), true
+		}
+		if current.cost > cost[current.point] {
+			continue // a cheaper way here was found after this one was queued
+		}
+		if octile(current.point, goal) < octile(closest, goal) {
+			closest = current.point
+		}
+
+		for _, d := range neighbours {
+			next := Point{current.point.X + d.X, current.point.Y + d.Y}
+			if !canEnter(next) {
+				continue
+			}
+			step := 1.0
+			if d.X != 0 && d.Y != 0 {
+				// no cutting corners past obstacles
+				if !canEnter(Point{current.point.X + d.X, current.point.Y}) || !canEnter(Point{current.point.X, current.point.Y + d.Y}) {
+					continue
+				}
+				step = math.Sqrt2
+			}
+
+			nextCost := current.cost + step
+			if known, ok := cost[next]; ok && known <= nextCost {
+				continue
+			}
+			cost[next] = nextCost
+			cameFrom[next] = current.point
+			heap.Push(open, &node{point: next, cost: nextCost, priority: nextCost + octile(next, goal)})
+		}
+	}
+	return nil, false
+}
+
+func reconstruct(cameFrom map[Point]Point, start, goal Point) []Point {
+	path := []Point{}
+	for p := goal; p != start; p = cameFrom[p] {
+		path = append(path, p)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// maxSkip is the most tiles of a path a single waypoint replaces. It bounds
+// the cost of smoothing long straight paths.
+const maxSkip = 64
+
+// smooth replaces the tiles of path by waypoints the unit can move between
+// in a straight line, keeping as few as possible. Tiles left at the deadline
+// are kept as they are, and smooth reports that it did not finish.
+func (f *Finder) smooth(unit types.UnitType, start Point, path []Point, deadline time.Time) ([]Point, bool) {
+	result := make([]Point, 0)
+	anchor := start
+	for i := 0; i < len(path); {
+		if expired(deadline) {
+			return append(result, path[i:]...), false
+		}
+		// walk on while the next tile of the path is still in sight
+		j := i
+		for j+1 < len(path) && j+1-i < maxSkip && !expired(deadline) && f.lineOfSight(unit, anchor, path[j+1]) {
+			j++
+		}
+		result = append(result, path[j])
+		anchor = path[j]
+		i = j + 1
+	}
+	return result, true
+}
+
+// lineOfSight reports whether a unit can move in a straight line from the
+// centre of tile a to the centre of tile b. Every tile the line touches must
+// be passable, including both tiles beside a corner it passes through.
+func (f *Finder) lineOfSight(unit types.UnitType, a, b Point) bool {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	nx, ny := abs(dx), abs(dy)
+	sx, sy := sign(dx), sign(dy)
+
+	p := a
+	for ix, iy := 0, 0; ix < nx || iy < ny; {
+		// compare where the line crosses the next vertical and horizontal
+		// tile boundaries, scaled to integers
+		switch decision := (1+2*ix)*ny - (1+2*iy)*nx; {
+		case decision == 0:
+			if !f.passable(unit, Point{p.X + sx, p.Y}) || !f.passable(unit, Point{p.X, p.Y + sy}) {
+				return false
+			}
+			p.X += sx
+			p.Y += sy
+			ix++
+			iy++
+		case decision < 0:
+			p.X += sx
+			ix++
+		default:
+			p.Y += sy
+			iy++
+		}
+		if !f.passable(unit, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// expired reports whether a search with the given deadline has to stop
+func expired(deadline time.Time) bool {
+	return !deadline.IsZero() && !time.Now().Before(deadline)
+}
+
+func clonePath(path []Point) []Point {
+	return append([]Point{}, path...)
+}
+
+// octile is the cost of the shortest 8-directional route on open ground
+func octile(a, b Point) float64 {
+	dx, dy := float64(abs(a.X-b.X)), float64(abs(a.Y-b.Y))
+	return math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy)
+}
+
+func chebyshev(a, b Point) int {
+	return max(abs(a.X-b.X), abs(a.Y-b.Y))
+}
+
+func sign(x int) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+type node struct {
+	point    Point
+	cost     float64
+	priority float64
+}
+
+// nodeQueue is a min-heap of nodes by priority
+type nodeQueue []*node
+
+func (q nodeQueue) Len() int { return len(q) }
+func (q nodeQueue) Less(i, j int) bool {
+	// among equal estimates prefer the node furthest along
+	if q[i].priority == q[j].priority {
+		return q[i].cost > q[j].cost
+	}
+	return q[i].priority < q[j].priority
+}
+func (q nodeQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *nodeQueue) Push(x any)   { *q = append(*q, x.(*node)) }
+func (q *nodeQueue) Pop() any {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
+
+// Steer returns the rest of a route followed from x,y and the direction to
+// its next waypoint, dropping the waypoints within reach. The route is nil
+// once it is done.
+func Steer(route []Point, x, y, reach float32) ([]Point, float32, float32) {
+	for len(route) > 0 {
+		// waypoints are tiles, units aim for their centres
+		dx := float32(route[0].X) + 0.5 - x
+		dy := float32(route[0].Y) + 0.5 - y
+		if dx*dx+dy*dy > reach*reach {
+			return route, dx, dy
+		}
+		route = route[1:]
+	}
+	return nil, 0, 0
+}
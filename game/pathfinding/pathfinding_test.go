@@ -0,0 +1,267 @@
+package pathfinding
+
+import (
+	"errors"
+	"projectt/game/movement"
+	"projectt/types"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mapGrid is a mutable test map of ground tiles with water where set
+type mapGrid struct {
+	width, height int
+	water         map[Point]bool
+	reads         atomic.Int64
+}
+
+func newMapGrid(width, height int) *mapGrid {
+	return &mapGrid{width: width, height: height, water: map[Point]bool{}}
+}
+
+func (g *mapGrid) grid() movement.Grid {
+	return func(x, y int) (types.TileType, bool) {
+		g.reads.Add(1)
+		if x >= g.width || y >= g.height {
+			return 0, false
+		}
+		if g.water[Point{x, y}] {
+			return types.TileTypeWater, true
+		}
+		return types.TileTypeGround, true
+	}
+}
+
+// wall fills column x with water, except for the gap rows
+func (g *mapGrid) wall(x int, gaps ...int) {
+	for y := range g.height {
+		if !slices.Contains(gaps, y) {
+			g.water[Point{x, y}] = true
+		}
+	}
+}
+
+func newTestFinder(g *mapGrid) *Finder {
+	return New(g.grid(), Config{
+		Width:             g.width,
+		Height:            g.height,
+		ChunkSize:         4,
+		MaxNodes:          10000,
+		HierarchyDistance: 3,
+		CacheSize:         2,
+	})
+}
+
+// walk checks that moving straight between the centres of the waypoints
+// only crosses tiles the unit may enter
+func walk(t *testing.T, g *mapGrid, unit types.UnitType, from Point, path []Point) {
+	t.Helper()
+
+	grid := g.grid()
+	p := from
+	for _, waypoint := range path {
+		for i := range 1000 {
+			x := float64(p.X) + 0.5 + float64(waypoint.X-p.X)*float64(i)/1000
+			y := float64(p.Y) + 0.5 + float64(waypoint.Y-p.Y)*float64(i)/1000
+			tile, ok := grid(int(x), int(y))
+			if !ok || !movement.Passable(unit, tile) {
+				t.Fatalf("path %v crosses %.2f,%.2f", path, x, y)
+			}
+		}
+		p = waypoint
+	}
+}
+
+func TestFindOpenGround(t *testing.T) {
+	g := newMapGrid(16, 16)
+	f := newTestFinder(g)
+
+	path, err := f.Find(types.UnitTypeInfantry, Point{1, 1}, Point{5, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a straight line needs no waypoints but the goal
+	if !slices.Equal(path, []Point{{5, 1}}) {
+		t.Fatalf("unexpected path %v", path)
+	}
+
+	path, err = f.Find(types.UnitTypeInfantry, Point{1, 1}, Point{4, 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(path, []Point{{4, 6}}) {
+		t.Fatalf("expected to move straight at any angle, got %v", path)
+	}
+}
+
+func TestFindAroundObstacles(t *testing.T) {
+	g := newMapGrid(16, 16)
+	g.wall(8, 14)
+	f := newTestFinder(g)
+
+	from, to := Point{2, 2}, Point{13, 2}
+	path, err := f.Find(types.UnitTypeTank, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walk(t, g, types.UnitTypeTank, from, path)
+	if len(path) != 3 || path[2] != to {
+		t.Fatalf("expected to turn at both sides of the gap, got %v", path)
+	}
+
+	// ships stay on the water
+	if _, err := f.Find(types.UnitTypeShip, Point{8, 0}, Point{8, 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Find(types.UnitTypeShip, Point{8, 0}, Point{2, 2}); !errors.Is(err, ErrNoPath) {
+		t.Fatalf("expected no path onto land, got %v", err)
+	}
+
+	// aircraft fly straight over everything
+	if path, err := f.Find(types.UnitTypeHelicopter, from, to); err != nil || !slices.Equal(path, []Point{to}) {
+		t.Fatalf("unexpected helicopter path %v, %v", path, err)
+	}
+}
+
+func TestFindUnreachable(t *testing.T) {
+	g := newMapGrid(16, 16)
+	g.wall(8)
+	f := newTestFinder(g)
+
+	if _, err := f.Find(types.UnitTypeInfantry, Point{2, 2}, Point{13, 2}); !errors.Is(err, ErrNoPath) {
+		t.Fatalf("expected no path, got %v", err)
+	}
+	if _, err := f.Find(types.UnitTypeInfantry, Point{2, 2}, Point{16, 2}); !errors.Is(err, ErrOutOfBounds) {
+		t.Fatalf("expected out of bounds, got %v", err)
+	}
+}
+
+func TestFindBeforeDeadline(t *testing.T) {
+	g := newMapGrid(16, 16)
+	f := newTestFinder(g)
+
+	from, to := Point{1, 1}, Point{14, 14}
+	if _, err := f.FindBefore(types.UnitTypeInfantry, from, to, time.Now()); !errors.Is(err, ErrNoPath) {
+		t.Fatalf("expected a search past its deadline to give up, got %v", err)
+	}
+	if f.CachedPaths() != 0 {
+		t.Fatal("a search that gave up was cached")
+	}
+	if _, err := f.FindBefore(types.UnitTypeInfantry, from, to, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("search within its deadline failed: %v", err)
+	}
+}
+
+func TestFindBeforeDeadlineReturnsPartialRoute(t *testing.T) {
+	g := newMapGrid(64, 64)
+	grid := g.grid()
+	slow := func(x, y int) (types.TileType, bool) {
+		// busy, sleeping is far coarser than this
+		for start := time.Now(); time.Since(start) < 20*time.Microsecond; {
+		}
+		return grid(x, y)
+	}
+	f := New(slow, Config{Width: 64, Height: 64, ChunkSize: 4, MaxNodes: 10000, CacheSize: 2})
+
+	from, to := Point{1, 1}, Point{62, 62}
+	path, err := f.FindBefore(types.UnitTypeInfantry, from, to, time.Now().Add(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("expected a partial route, got %v", err)
+	}
+	if len(path) == 0 || path[len(path)-1] == to {
+		t.Fatalf("expected a route part of the way, got %v", path)
+	}
+	if end := path[len(path)-1]; octile(end, to) >= octile(from, to) {
+		t.Fatalf("partial route to %v does not get closer to %v", end, to)
+	}
+	walk(t, g, types.UnitTypeInfantry, from, path)
+	if f.CachedPaths() != 0 {
+		t.Fatal("a partial route was cached")
+	}
+}
+
+func TestFindLongRouteUsesChunks(t *testing.T) {
+	// a maze of walls with alternating gaps forces a long detour
+	g := newMapGrid(64, 32)
+	for x := 8; x < 64; x += 8 {
+		if x%16 == 0 {
+			g.wall(x, 1)
+		} else {
+			g.wall(x, 30)
+		}
+	}
+	f := newTestFinder(g)
+
+	from, to := Point{1, 16}, Point{62, 16}
+	path, err := f.Find(types.UnitTypeInfantry, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walk(t, g, types.UnitTypeInfantry, from, path)
+	if path[len(path)-1] != to {
+		t.Fatalf("path does not end at the goal: %v", path)
+	}
+
+	// the chunk summaries are kept for later searches
+	f.mu.Lock()
+	chunks := len(f.chunks)
+	f.mu.Unlock()
+	if chunks == 0 {
+		t.Fatal("no chunk was summarized")
+	}
+
+	// a wall inside the chunks does not let the plan through
+	g.wall(42)
+	for y := range g.height {
+		f.Invalidate(42, y)
+	}
+	if _, err := f.Find(types.UnitTypeInfantry, from, to); !errors.Is(err, ErrNoPath) {
+		t.Fatalf("expected no path, got %v", err)
+	}
+}
+
+func TestPathCache(t *testing.T) {
+	g := newMapGrid(16, 16)
+	f := newTestFinder(g)
+
+	from, to := Point{1, 1}, Point{10, 12}
+	first, err := f.Find(types.UnitTypeInfantry, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reads := g.reads.Load()
+	second, err := f.Find(types.UnitTypeInfantry, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the goal tile is looked at again
+	if g.reads.Load() != reads+1 || !slices.Equal(first, second) {
+		t.Fatalf("path was searched again: %d reads", g.reads.Load()-reads)
+	}
+
+	// callers may not change the cached path
+	second[0] = Point{0, 0}
+	if third, _ := f.Find(types.UnitTypeInfantry, from, to); !slices.Equal(first, third) {
+		t.Fatal("cached path was modified")
+	}
+
+	// a changed tile drops the cache
+	g.wall(5)
+	f.Invalidate(5, 0)
+	if f.CachedPaths() != 0 {
+		t.Fatal("cache survived a tile change")
+	}
+	if _, err := f.Find(types.UnitTypeInfantry, from, to); !errors.Is(err, ErrNoPath) {
+		t.Fatalf("expected the wall to block the path, got %v", err)
+	}
+
+	// the cache is bounded
+	for x := range 4 {
+		f.Find(types.UnitTypeInfantry, Point{x, 0}, Point{x, 5})
+	}
+	if f.CachedPaths() != 2 {
+		t.Fatalf("expected 2 cached paths, got %d", f.CachedPaths())
+	}
+}
@@ -0,0 +1,68 @@
+package permissions
+
+import "projectt/types"
+
+// Permission names an action that is restricted to some players
+type Permission string
+
+const (
+	// Everyone
+	Chat        Permission = "chat"
+	Whisper     Permission = "chat.whisper"
+	CountryChat Permission = "chat.country"
+	LocalChat   Permission = "chat.local"
+	MoveTo      Permission = "player.move_to"
+
+	// Staff
+	Notice         Permission = "chat.notice"
+	Mute           Permission = "chat.mute"
+	Teleport       Permission = "player.teleport"
+	TeleportOthers Permission = "player.teleport.others"
+	Kick           Permission = "player.kick"
+	Ban            Permission = "player.ban"
+	BanIP          Permission = "player.ban.ip"
+	CheatAlerts    Permission = "player.cheat_alerts"
+)
+
+// rankPermissions are granted by in-game rank. Higher ranks inherit the
+// permissions of lower ones.
+var rankPermissions = map[types.PlayerRank][]Permission{
+	types.PlayerRankCitizen: {Chat, Whisper, CountryChat, LocalChat, MoveTo},
+}
+
+// staffPermissions are granted by staff role. Higher roles inherit the
+// permissions of lower ones.
+var staffPermissions = map[types.StaffRole][]Permission{
+	types.StaffRoleModerator: {Notice, Mute, Teleport, Kick, Ban, CheatAlerts},
+	types.StaffRoleAdmin:     {TeleportOthers, BanIP},
+}
+
+// Has reports whether a player with the given rank and staff role holds perm
+func Has(rank types.PlayerRank, role types.StaffRole, perm Permission) bool {
+	for r, perms := range rankPermissions {
+		if r <= rank && contains(perms, perm) {
+			return true
+		}
+	}
+	for r, perms := range staffPermissions {
+		if r <= role && contains(perms, perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Outranks reports whether staff with role may moderate a player with the
+// target role, which takes a strictly higher role
+func Outranks(role, target types.StaffRole) bool {
+	return role > target
+}
+
+func contains(perms []Permission, perm Permission) bool {
+	for _, p := range perms {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package permissions
+
+import (
+	"projectt/types"
+	"testing"
+)
+
+func TestHas(t *testing.T) {
+	tests := []struct {
+		rank types.PlayerRank
+		role types.StaffRole
+		perm Permission
+		want bool
+	}{
+		{types.PlayerRankCitizen, types.StaffRoleNone, Chat, true},
+		{types.PlayerRankLeader, types.StaffRoleNone, Whisper, true},
+		{types.PlayerRankLeader, types.StaffRoleNone, Notice, false},
+		{types.PlayerRankCitizen, types.StaffRoleModerator, Notice, true},
+		{types.PlayerRankCitizen, types.StaffRoleModerator, TeleportOthers, false},
+		{types.PlayerRankCitizen, types.StaffRoleAdmin, Notice, true},
+		{types.PlayerRankCitizen, types.StaffRoleAdmin, TeleportOthers, true},
+	}
+
+	for _, tt := range tests {
+		if got := Has(tt.rank, tt.role, tt.perm); got != tt.want {
+			t.Errorf("Has(%d, %s, %s) = %v, want %v", tt.rank, tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestOutranks(t *testing.T) {
+	if !Outranks(types.StaffRoleModerator, types.StaffRoleNone) || !Outranks(types.StaffRoleAdmin, types.StaffRoleModerator) {
+		t.Fatal("staff may not moderate lower roles")
+	}
+	if Outranks(types.StaffRoleModerator, types.StaffRoleAdmin) || Outranks(types.StaffRoleModerator, types.StaffRoleModerator) {
+		t.Fatal("staff may moderate their own or higher roles")
+	}
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketBurstAndRefill(t *testing.T) {
+	start := time.Unix(0, 0)
+	b := NewBucket(2, 3)
+
+	for i := range 3 {
+		if !b.AllowAt(start) {
+			t.Fatalf("event %d within burst was refused", i)
+		}
+	}
+	if b.AllowAt(start) {
+		t.Fatal("event beyond burst was allowed")
+	}
+
+	// 2 tokens per second: one token after 500ms
+	if !b.AllowAt(start.Add(500 * time.Millisecond)) {
+		t.Fatal("event after refill was refused")
+	}
+	if b.AllowAt(start.Add(500 * time.Millisecond)) {
+		t.Fatal("bucket refilled more than the elapsed time allows")
+	}
+
+	// refill never exceeds the burst size
+	later := start.Add(time.Hour)
+	for i := range 3 {
+		if !b.AllowAt(later) {
+			t.Fatalf("event %d after idle period was refused", i)
+		}
+	}
+	if b.AllowAt(later) {
+		t.Fatal("bucket grew beyond its burst size")
+	}
+}
+
+func TestKeyedPrune(t *testing.T) {
+	start := time.Unix(0, 0)
+	k := NewKeyed(1, 2)
+
+	for range 2 {
+		if !k.AllowAt("a", start) {
+			t.Fatal("event within burst was refused")
+		}
+	}
+	if k.AllowAt("a", start) {
+		t.Fatal("event beyond burst was allowed")
+	}
+	// keys are limited independently
+	if !k.AllowAt("b", start) {
+		t.Fatal("event of another key was refused")
+	}
+
+	// b refilled after a second, a needs two
+	k.Prune(start.Add(time.Second))
+	if k.Len() != 1 {
+		t.Fatalf("expected 1 bucket after pruning, got %d", k.Len())
+	}
+	k.Prune(start.Add(2 * time.Second))
+	if k.Len() != 0 {
+		t.Fatalf("expected no buckets after pruning, got %d", k.Len())
+	}
+}
@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Keyed keeps a separate bucket for every key, e.g. per IP address
+type Keyed struct {
+	rate  float64
+	burst int
+
+	mu      sync.Mutex
+	buckets map[string]*Bucket
+}
+
+// NewKeyed returns a limiter whose buckets hold burst tokens and refill at
+// rate tokens per second
+func NewKeyed(rate float64, burst int) *Keyed {
+	return &Keyed{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*Bucket),
+	}
+}
+
+// Allow takes a token from the bucket of key if one is available
+func (k *Keyed) Allow(key string) bool {
+	return k.AllowAt(key, time.Now())
+}
+
+// AllowAt is Allow with an explicit current time
+func (k *Keyed) AllowAt(key string, now time.Time) bool {
+	k.mu.Lock()
+	bucket, ok := k.buckets[key]
+	if !ok {
+		bucket = NewBucket(k.rate, k.burst)
+		k.buckets[key] = bucket
+	}
+	k.mu.Unlock()
+
+	return bucket.AllowAt(now)
+}
+
+// Prune forgets the buckets that have refilled completely by now. A new
+// bucket for their key would behave the same.
+func (k *Keyed) Prune(now time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for key, bucket := range k.buckets {
+		if bucket.fullAt(now) {
+			delete(k.buckets, key)
+		}
+	}
+}
+
+// Len returns the number of keys with a bucket
+func (k *Keyed) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.buckets)
+}
@@ -0,0 +1,141 @@
+package tick
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxCatchUpSteps bounds how many ticks are simulated back to back after a
+// stall. Anything beyond that is dropped so the loop cannot spiral.
+const maxCatchUpSteps = 5
+
+// Stats describes the loop's timing health
+type Stats struct {
+	Tick         uint64        // Last simulated tick
+	Overruns     uint64        // Ticks whose step took longer than the tick duration
+	Skipped      uint64        // Ticks dropped because catch-up was capped
+	LastDuration time.Duration // Duration of the last step
+	MaxDuration  time.Duration // Longest step seen
+}
+
+// Loop runs a fixed-timestep simulation. Real time is accumulated and
+// consumed in whole ticks, so the simulation advances at the configured
+// rate even if individual wakeups are late.
+type Loop struct {
+	ticksPerSecond int
+	dt             time.Duration
+
+	// Observe, if set, is called with the duration of every step
+	Observe func(d time.Duration)
+
+	tick     atomic.Uint64
+	mu       sync.Mutex
+	stats    Stats
+	lastWarn time.Time
+}
+
+func NewLoop(ticksPerSecond int) *Loop {
+	return &Loop{
+		ticksPerSecond: ticksPerSecond,
+		dt:             time.Second / time.Duration(ticksPerSecond),
+	}
+}
+
+// DeltaTime returns the simulated duration of one tick
+func (l *Loop) DeltaTime() time.Duration {
+	return l.dt
+}
+
+// TicksPerSecond returns the configured tick rate
+func (l *Loop) TicksPerSecond() int {
+	return l.ticksPerSecond
+}
+
+// Tick returns the last simulated tick. It starts at zero and only increases.
+func (l *Loop) Tick() uint64 {
+	return l.tick.Load()
+}
+
+// Stats returns a snapshot of the loop timing statistics
+func (l *Loop) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := l.stats
+	stats.Tick = l.Tick()
+	return stats
+}
+
+// Run calls step once per tick until done is closed
+func (l *Loop) Run(done <-chan struct{}, step func(tick uint64)) {
+	timer := time.NewTimer(l.dt)
+	defer timer.Stop()
+
+	last := time.Now()
+	var accumulator time.Duration
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		accumulator += now.Sub(last)
+		last = now
+
+		for steps := 0; accumulator >= l.dt; steps++ {
+			if steps == maxCatchUpSteps {
+				l.skip(uint64(accumulator / l.dt))
+				accumulator %= l.dt
+				break
+			}
+
+			tick := l.tick.Add(1)
+			start := time.Now()
+			step(tick)
+			l.record(tick, time.Since(start))
+			accumulator -= l.dt
+		}
+
+		timer.Reset(l.dt - accumulator)
+	}
+}
+
+func (l *Loop) record(tick uint64, d time.Duration) {
+	if l.Observe != nil {
+		l.Observe(d)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stats.LastDuration = d
+	if d > l.stats.MaxDuration {
+		l.stats.MaxDuration = d
+	}
+	if d > l.dt {
+		l.stats.Overruns++
+		l.warn("Tick %d took %v, budget is %v (%d overruns)", tick, d, l.dt, l.stats.Overruns)
+	}
+}
+
+func (l *Loop) skip(n uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stats.Skipped += n
+	l.warn("Tick loop fell behind, skipped %d ticks (%d total)", n, l.stats.Skipped)
+}
+
+// warn logs at most once per second; must be called with the mutex locked
+func (l *Loop) warn(format string, args ...any) {
+	if time.Since(l.lastWarn) < time.Second {
+		return
+	}
+	l.lastWarn = time.Now()
+	log.Printf(format, args...)
+}
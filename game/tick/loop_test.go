@@ -0,0 +1,54 @@
+package tick
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoopRate(t *testing.T) {
+	loop := NewLoop(100)
+	if loop.DeltaTime() != 10*time.Millisecond {
+		t.Fatalf("expected 10ms ticks, got %v", loop.DeltaTime())
+	}
+
+	done := make(chan struct{})
+	var last uint64
+	go loop.Run(done, func(tick uint64) {
+		if tick != last+1 {
+			t.Errorf("tick %d followed %d", tick, last)
+		}
+		last = tick
+	})
+	time.Sleep(500 * time.Millisecond)
+	close(done)
+
+	// 50 ticks expected, allow for scheduler jitter
+	if ticks := loop.Tick(); ticks < 35 || ticks > 55 {
+		t.Fatalf("expected about 50 ticks, got %d", ticks)
+	}
+}
+
+func TestLoopCatchUpAndOverrun(t *testing.T) {
+	loop := NewLoop(100)
+
+	done := make(chan struct{})
+	go loop.Run(done, func(tick uint64) {
+		if tick == 1 {
+			// stall for 20 ticks
+			time.Sleep(200 * time.Millisecond)
+		}
+	})
+	time.Sleep(400 * time.Millisecond)
+	close(done)
+
+	stats := loop.Stats()
+	if stats.Overruns == 0 {
+		t.Fatal("expected the stalled tick to be reported as an overrun")
+	}
+	if stats.Skipped == 0 {
+		t.Fatal("expected capped catch-up to skip ticks")
+	}
+	if stats.MaxDuration < 200*time.Millisecond {
+		t.Fatalf("unexpected max duration %v", stats.MaxDuration)
+	}
+}
@@ -1,47 +1,113 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"io/fs"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"projectt/admin"
 	"projectt/config"
 	"projectt/migrations"
 	"projectt/socket"
+	"projectt/storage"
 	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
 func main() {
 	// set timezone to utc
 	time.Local = time.UTC
 
-	// load environment variables
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+	// load environment variables from .env if present
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatalf("Error loading .env file: %v", err)
 	}
-	config.Init()
 
-	// database connection
-	config.ConnectDatabase()
-	// migrations and seeders
-	migrations.Migrate(config.DB)
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if config.DumpRequested(os.Args[1:]) {
+		if err := cfg.Dump(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	// start socket server
-	go func() {
-		socket.StartServer()
-	}()
+	server, err := socket.StartServer(cfg, openStore(cfg.Database))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var httpServers []*http.Server
+	// metrics endpoint
+	if cfg.Metrics.Addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", server.Metrics().Handler())
+		httpServers = append(httpServers, serveHTTP("Metrics endpoint", cfg.Metrics.Addr, mux))
+	}
+	// admin API
+	if cfg.Admin.Addr != "" {
+		httpServers = append(httpServers, serveHTTP("Admin API", cfg.Admin.Addr, admin.NewAPI(server, cfg.Admin.Token)))
+	}
 
 	// Graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Println("Shutting down server...")
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	<-ctx.Done()
+	// a second signal stops the process immediately
+	stop()
+	log.Printf("Shutting down server in %v...", cfg.App.ShutdownCountdown)
 
-	// Save any necessary data before exiting
-	socket.Save()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.App.ShutdownCountdown+cfg.App.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx, cfg.App.ShutdownCountdown); err != nil {
+		log.Printf("Server did not stop cleanly: %v", err)
+	}
+	for _, srv := range httpServers {
+		srv.Shutdown(shutdownCtx)
+	}
 
 	log.Println("Server gracefully stopped")
 }
+
+func serveHTTP(name, addr string, handler http.Handler) *http.Server {
+	srv := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		log.Printf("%s is running on %s", name, addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("%s stopped: %v", name, err)
+		}
+	}()
+	return srv
+}
+
+// openStore connects the configured storage backend and runs migrations
+func openStore(c config.DatabaseConfig) storage.Store {
+	if c.Driver == "memory" {
+		log.Println("Using in-memory storage, data will not be persisted")
+		return storage.NewMemory()
+	}
+
+	var db *gorm.DB
+	var err error
+	if c.Driver == "sqlite" {
+		db, err = config.ConnectSQLite(c)
+	} else {
+		db, err = config.ConnectDatabase(c)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// migrations and seeders
+	migrations.Migrate(db)
+
+	return storage.NewGorm(db)
+}
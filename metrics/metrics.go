@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "projectt"
+
+// Metrics holds the collectors of a single game server. Each server gets its
+// own registry so several servers can live in one process (e.g. in tests).
+type Metrics struct {
+	registry *prometheus.Registry
+
+	TickDuration     prometheus.Histogram
+	MessagesIn       *prometheus.CounterVec // by message type
+	MessagesOut      *prometheus.CounterVec // by message type
+	BytesSent        *prometheus.CounterVec // by transport (tcp, udp)
+	AutosaveDuration prometheus.Histogram
+	ChatBlocked      *prometheus.CounterVec // by reason (muted, rate_limited, spam)
+	UDPRejected      *prometheus.CounterVec // by reason (malformed, unknown, bad_mac, replay, stale_address, budget, queue_full)
+	UDPRebinds       prometheus.Counter
+	Violations       *prometheus.CounterVec // by reason (oversized, malformed, unknown_type)
+	MessagesDropped  *prometheus.CounterVec // by message type, over the per-connection rate limit
+	ConnRefused      *prometheus.CounterVec // by reason (rate_limited, banned, full)
+	CheatOffenses    *prometheus.CounterVec // by offense (clock_drift, input_rate, teleport)
+	CheatActions     *prometheus.CounterVec // by action (flag, kick)
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		TickDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "tick_duration_seconds",
+			Help:      "Time spent simulating a single tick.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .0167, .025, .05, .1},
+		}),
+		MessagesIn: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_received_total",
+			Help:      "Messages received from clients.",
+		}, []string{"type"}),
+		MessagesOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_sent_total",
+			Help:      "Messages sent to clients.",
+		}, []string{"type"}),
+		BytesSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bytes_sent_total",
+			Help:      "Bytes written to clients.",
+		}, []string{"transport"}),
+		AutosaveDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "autosave_duration_seconds",
+			Help:      "Time spent persisting players and tiles.",
+			Buckets:   prometheus.ExponentialBuckets(.005, 2, 12),
+		}),
+		ChatBlocked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "chat_blocked_total",
+			Help:      "Chat messages refused by moderation.",
+		}, []string{"reason"}),
+		UDPRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "udp_rejected_total",
+			Help:      "Datagrams dropped before they were handled.",
+		}, []string{"reason"}),
+		UDPRebinds: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "udp_rebinds_total",
+			Help:      "UDP bindings moved to a new client address.",
+		}),
+		Violations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "protocol_violations_total",
+			Help:      "Malformed or disallowed messages received from clients.",
+		}, []string{"reason"}),
+		MessagesDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_dropped_total",
+			Help:      "Client messages dropped by the per-connection rate limits.",
+		}, []string{"type"}),
+		ConnRefused: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "connections_refused_total",
+			Help:      "TCP connections closed right after they were accepted.",
+		}, []string{"reason"}),
+		CheatOffenses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cheat_offenses_total",
+			Help:      "Suspicious movement detected by the anti-cheat.",
+		}, []string{"offense"}),
+		CheatActions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cheat_actions_total",
+			Help:      "Players flagged for moderators or kicked by the anti-cheat.",
+		}, []string{"action"}),
+	}
+
+	m.registry.MustRegister(
+		m.TickDuration,
+		m.MessagesIn,
+		m.MessagesOut,
+		m.BytesSent,
+		m.AutosaveDuration,
+		m.ChatBlocked,
+		m.UDPRejected,
+		m.UDPRebinds,
+		m.Violations,
+		m.MessagesDropped,
+		m.ConnRefused,
+		m.CheatOffenses,
+		m.CheatActions,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Gauge registers a gauge whose value is read from fn on every scrape
+func (m *Metrics) Gauge(name, help string, fn func() float64) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
+
+// Counter registers a counter whose value is read from fn on every scrape
+func (m *Metrics) Counter(name, help string, fn func() float64) {
+	m.registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
+
+// DBStats registers database connection pool metrics read from fn
+func (m *Metrics) DBStats(fn func() sql.DBStats) {
+	m.registry.MustRegister(&dbStatsCollector{stats: fn})
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+type dbStatsCollector struct {
+	stats func() sql.DBStats
+}
+
+var (
+	dbOpenDesc = prometheus.NewDesc(namespace+"_db_open_connections",
+		"Established database connections, in use and idle.", nil, nil)
+	dbInUseDesc = prometheus.NewDesc(namespace+"_db_in_use_connections",
+		"Database connections currently in use.", nil, nil)
+	dbIdleDesc = prometheus.NewDesc(namespace+"_db_idle_connections",
+		"Idle database connections.", nil, nil)
+	dbWaitCountDesc = prometheus.NewDesc(namespace+"_db_wait_count_total",
+		"Total number of connections waited for.", nil, nil)
+	dbWaitDurationDesc = prometheus.NewDesc(namespace+"_db_wait_duration_seconds_total",
+		"Total time blocked waiting for a new connection.", nil, nil)
+)
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dbOpenDesc
+	ch <- dbInUseDesc
+	ch <- dbIdleDesc
+	ch <- dbWaitCountDesc
+	ch <- dbWaitDurationDesc
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats()
+	ch <- prometheus.MustNewConstMetric(dbOpenDesc, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbInUseDesc, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(dbIdleDesc, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(dbWaitCountDesc, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(dbWaitDurationDesc, prometheus.CounterValue, stats.WaitDuration.Seconds())
+}
@@ -14,6 +14,7 @@ func Migrate(db *gorm.DB) {
 	err := db.AutoMigrate(
 		&models.Country{}, &models.MapTile{},
 		&models.Player{}, &models.Unit{},
+		&models.ChatMessage{}, &models.Mail{}, &models.Ban{},
 	)
 	if err != nil {
 		log.Fatal("Migration failed:", err)
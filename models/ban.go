@@ -0,0 +1,33 @@
+package models
+
+import (
+	"net"
+	"time"
+)
+
+// Ban keeps a player account or an IP range off the server. A ban without an
+// expiry is permanent.
+type Ban struct {
+	ID        uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	CreatedAt time.Time  `json:"created_at"`
+	PlayerID  *uint      `json:"player_id" gorm:"index"` // account ban
+	Nickname  string     `json:"nickname"`               // banned player, for display
+	CIDR      string     `json:"cidr"`                   // IP ban, e.g. 203.0.113.7/32
+	Reason    string     `json:"reason"`
+	BannedBy  string     `json:"banned_by"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Active reports whether the ban is in effect at the given time
+func (b *Ban) Active(now time.Time) bool {
+	return b.ExpiresAt == nil || now.Before(*b.ExpiresAt)
+}
+
+// MatchesIP reports whether this is an IP ban covering ip
+func (b *Ban) MatchesIP(ip net.IP) bool {
+	if b.CIDR == "" || ip == nil {
+		return false
+	}
+	_, network, err := net.ParseCIDR(b.CIDR)
+	return err == nil && network.Contains(ip)
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// ChatMessage is a stored general or country chat message, replayed to
+// players when they log in
+type ChatMessage struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	Channel   uint8     `json:"channel" gorm:"type:smallint;not null;index:idx_chat_channel_country"` // binary.ChatMessageType
+	CountryID uint8     `json:"country_id" gorm:"not null;index:idx_chat_channel_country"`            // sender's country
+	FromID    uint      `json:"from_id"`
+	From      string    `json:"from" gorm:"not null"`
+	Message   string    `json:"message" gorm:"not null"`
+}
+
+// Mail is a whisper sent to an offline player, delivered on their next login
+type Mail struct {
+	ID          uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	CreatedAt   time.Time `json:"created_at"`
+	RecipientID uint      `json:"recipient_id" gorm:"not null;index"`
+	From        string    `json:"from" gorm:"not null"`
+	Message     string    `json:"message" gorm:"not null"`
+}
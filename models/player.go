@@ -2,6 +2,7 @@ package models
 
 import (
 	"math"
+	"projectt/game/permissions"
 	"projectt/types"
 	"time"
 )
@@ -15,6 +16,7 @@ type Player struct {
 	EXP       uint             `json:"exp" gorm:"default:0"`
 	Level     uint             `json:"level" gorm:"-"`
 	Rank      types.PlayerRank `json:"rank" gorm:"type:integer;default:1"`
+	StaffRole types.StaffRole  `json:"staff_role" gorm:"type:integer;default:0"`
 	Health    uint             `json:"health" gorm:"default:100"`
 	MaxHealth uint             `json:"max_health" gorm:"default:100"`
 	CoordX    float32          `json:"coord_x" gorm:"default:0"`
@@ -24,6 +26,11 @@ type Player struct {
 	UnitID    *uint            `json:"unit_id"`
 	Unit      *Unit            `json:"unit" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 
+	// Chat mute. A mute without an expiry is permanent.
+	Muted       bool       `json:"muted" gorm:"default:false"`
+	MuteExpires *time.Time `json:"mute_expires"`
+	MuteReason  string     `json:"mute_reason"`
+
 	// Movement fields
 	LastUpdatedTicks float32   `json:"last_updated_ticks" gorm:"-"`
 	LastUpdated      time.Time `json:"last_updated" gorm:"-"`
@@ -39,6 +46,7 @@ func (m *Player) Copy() *Player {
 		EXP:              m.EXP,
 		Level:            m.Level,
 		Rank:             m.Rank,
+		StaffRole:        m.StaffRole,
 		Health:           m.Health,
 		MaxHealth:        m.MaxHealth,
 		CoordX:           m.CoordX,
@@ -47,6 +55,9 @@ func (m *Player) Copy() *Player {
 		DirY:             m.DirY,
 		UnitID:           m.UnitID,
 		Unit:             m.Unit,
+		Muted:            m.Muted,
+		MuteExpires:      m.MuteExpires,
+		MuteReason:       m.MuteReason,
 		LastUpdatedTicks: m.LastUpdatedTicks,
 		LastUpdated:      m.LastUpdated,
 	}
@@ -108,6 +119,16 @@ func (m *Player) GetUnitType() types.UnitType {
 	return types.UnitTypeInfantry // default unit type
 }
 
+// Can reports whether the player holds the given permission
+func (m *Player) Can(perm permissions.Permission) bool {
+	return permissions.Has(m.Rank, m.StaffRole, perm)
+}
+
+// IsMuted reports whether the player may not chat at the given time
+func (m *Player) IsMuted(now time.Time) bool {
+	return m.Muted && (m.MuteExpires == nil || now.Before(*m.MuteExpires))
+}
+
 func (m *Player) IsMoving() bool {
 	return m.DirX != 0 || m.DirY != 0
 }
@@ -2,6 +2,7 @@ package models
 
 import (
 	"projectt/types"
+	"time"
 )
 
 type Unit struct {
@@ -16,6 +17,9 @@ type Unit struct {
 	DirX           float32        `json:"dir_x" gorm:"default:0"`
 	DirY           float32        `json:"dir_y" gorm:"default:0"`
 	MaxSpeed       float32        `json:"max_speed" gorm:"type:float;not null"`
+	// Seconds an aircraft flew since it was last refueled, see movement.Fuel
+	FlightTime float32   `json:"flight_time" gorm:"default:0"`
+	LastFlown  time.Time `json:"-" gorm:"-"`
 
 	// Players related fields
 	MaxPassengers uint8    `json:"max_passengers" gorm:"smallint;not null"`
@@ -0,0 +1,393 @@
+package socket
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	b "projectt/binary"
+	"projectt/models"
+	"projectt/storage"
+	"projectt/types"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPlayerNotFound  = errors.New("player not found")
+	ErrTileNotFound    = errors.New("tile not found")
+	ErrCountryNotFound = errors.New("country not found")
+	ErrInvalidPosition = errors.New("position outside the world")
+)
+
+// ConnectionInfo describes a client connection
+type ConnectionInfo struct {
+	ConnID        uint32    `json:"conn_id"`
+	RemoteAddr    string    `json:"remote_addr"`
+	UDPAddr       string    `json:"udp_addr,omitempty"`
+	PlayerID      uint      `json:"player_id,omitempty"`
+	Nickname      string    `json:"nickname,omitempty"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	Suspended     bool      `json:"suspended,omitempty"`
+	// Anti-cheat suspicion score of the player
+	Suspicion float64 `json:"suspicion,omitempty"`
+}
+
+// ServerStats is a snapshot of the server state
+type ServerStats struct {
+	Connections    int           `json:"connections"`
+	Players        int           `json:"players"`
+	MovingPlayers  int           `json:"moving_players"`
+	Entities       int           `json:"entities"`
+	DirtyTiles     int           `json:"dirty_tiles"`
+	Tiles          int           `json:"tiles"`
+	Countries      int           `json:"countries"`
+	Tick           uint64        `json:"tick"`
+	TicksPerSecond int           `json:"ticks_per_second"`
+	TickOverruns   uint64        `json:"tick_overruns"`
+	TicksSkipped   uint64        `json:"ticks_skipped"`
+	MaxTickTime    time.Duration `json:"max_tick_time_ns"`
+	Uptime         time.Duration `json:"uptime_ns"`
+}
+
+// connectionsSnapshot copies the connection list so it can be used without the server lock
+func (s *GameServer) connectionsSnapshot() []*GameConnection {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	connections := make([]*GameConnection, 0, len(s.connections))
+	for _, gc := range s.connections {
+		connections = append(connections, gc)
+	}
+	return connections
+}
+
+// findConnectionByNickname returns the connection of an online player
+func (s *GameServer) findConnectionByNickname(nickname string) *GameConnection {
+	for _, gc := range s.connectionsSnapshot() {
+		gc.mu.RLock()
+		found := gc.player != nil && strings.EqualFold(gc.player.Nickname, nickname)
+		gc.mu.RUnlock()
+		if found {
+			return gc
+		}
+	}
+	return nil
+}
+
+// Connections lists all open client connections
+func (s *GameServer) Connections() []ConnectionInfo {
+	connections := s.connectionsSnapshot()
+	infos := make([]ConnectionInfo, 0, len(connections))
+	for _, gc := range connections {
+		gc.mu.RLock()
+		info := ConnectionInfo{
+			ConnID:        gc.connID,
+			RemoteAddr:    gc.conn.RemoteAddr().String(),
+			LastHeartbeat: gc.lastHeartbeat,
+			Suspended:     !gc.suspendedAt.IsZero(),
+		}
+		if gc.udpAddr != nil {
+			info.UDPAddr = gc.udpAddr.String()
+		}
+		if gc.player != nil {
+			info.PlayerID = gc.player.ID
+			info.Nickname = gc.player.Nickname
+			info.Suspicion = s.anticheat.Score(gc.player.ID, time.Now())
+		}
+		gc.mu.RUnlock()
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// OnlinePlayers returns copies of all logged in players
+func (s *GameServer) OnlinePlayers() []*models.Player {
+	players := make([]*models.Player, 0)
+	for _, gc := range s.connectionsSnapshot() {
+		gc.mu.RLock()
+		if gc.player != nil {
+			players = append(players, gc.player.Copy())
+		}
+		gc.mu.RUnlock()
+	}
+	return players
+}
+
+// Kick disconnects an online player with the given reason
+func (s *GameServer) Kick(nickname, reason string) error {
+	gc := s.findConnectionByNickname(nickname)
+	if gc == nil {
+		return ErrPlayerNotFound
+	}
+	log.Printf("AUDIT: kicking %s: %s", nickname, reason)
+	gc.Disconnect(&b.DisconnectMessage{
+		Reason:  b.DisconnectReasonKicked,
+		Message: reason,
+	})
+	return nil
+}
+
+// editPlayer applies edit to a player, whether they are online or not, and
+// saves the result
+func (s *GameServer) editPlayer(nickname string, edit func(p *models.Player)) (*models.Player, error) {
+	player, err := s.store.FindPlayerByNickname(nickname)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrPlayerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// online players are saved from their connection so the change is not overwritten
+	if gc := s.findConnectionByNickname(nickname); gc != nil {
+		gc.mu.Lock()
+		if gc.player != nil {
+			edit(gc.player)
+			player = gc.player.Copy()
+		}
+		gc.mu.Unlock()
+	} else {
+		edit(player)
+	}
+
+	if err := s.store.SavePlayer(player); err != nil {
+		return nil, err
+	}
+	return player, nil
+}
+
+// staffRole returns the staff role of a player, whether they are online or
+// not
+func (s *GameServer) staffRole(nickname string) (types.StaffRole, error) {
+	if gc := s.findConnectionByNickname(nickname); gc != nil {
+		gc.mu.RLock()
+		defer gc.mu.RUnlock()
+		if gc.player != nil {
+			return gc.player.StaffRole, nil
+		}
+	}
+	player, err := s.store.FindPlayerByNickname(nickname)
+	if errors.Is(err, storage.ErrNotFound) {
+		return types.StaffRoleNone, ErrPlayerNotFound
+	}
+	if err != nil {
+		return types.StaffRoleNone, err
+	}
+	return player.StaffRole, nil
+}
+
+// SetStaffRole changes a player's staff role, whether they are online or not
+func (s *GameServer) SetStaffRole(nickname string, role types.StaffRole) error {
+	player, err := s.editPlayer(nickname, func(p *models.Player) {
+		p.StaffRole = role
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("AUDIT: staff role of %s set to %s", player.Nickname, role)
+
+	// available commands changed
+	if gc := s.findConnectionByNickname(nickname); gc != nil {
+		gc.sendCommandList()
+	}
+	return nil
+}
+
+// Mute stops a player from chatting for the given duration, or permanently
+// if duration is 0
+func (s *GameServer) Mute(nickname string, duration time.Duration, reason string) (*models.Player, error) {
+	var expires *time.Time
+	if duration > 0 {
+		at := time.Now().Add(duration)
+		expires = &at
+	}
+
+	player, err := s.editPlayer(nickname, func(p *models.Player) {
+		p.Muted = true
+		p.MuteExpires = expires
+		p.MuteReason = reason
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if expires != nil {
+		log.Printf("AUDIT: %s muted for %v: %s", player.Nickname, duration, reason)
+	} else {
+		log.Printf("AUDIT: %s muted permanently: %s", player.Nickname, reason)
+	}
+
+	if gc := s.findConnectionByNickname(nickname); gc != nil {
+		text := "You have been muted"
+		if expires != nil {
+			text += " for " + duration.String()
+		}
+		if reason != "" {
+			text += ": " + reason
+		}
+		gc.sendSystemMessage(text)
+	}
+	return player, nil
+}
+
+// Unmute lifts a player's mute
+func (s *GameServer) Unmute(nickname string) error {
+	player, err := s.editPlayer(nickname, func(p *models.Player) {
+		p.Muted = false
+		p.MuteExpires = nil
+		p.MuteReason = ""
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("AUDIT: %s unmuted", player.Nickname)
+
+	if gc := s.findConnectionByNickname(nickname); gc != nil {
+		gc.sendSystemMessage("You are no longer muted")
+	}
+	return nil
+}
+
+// inWorld reports whether x,y is a finite position inside the world
+func (s *GameServer) inWorld(x, y float32) bool {
+	fx, fy := float64(x), float64(y)
+	if math.IsNaN(fx) || math.IsNaN(fy) || math.IsInf(fx, 0) || math.IsInf(fy, 0) {
+		return false
+	}
+	return fx >= 0 && fy >= 0 && fx < float64(s.cfg.Game.WorldWidth) && fy < float64(s.cfg.Game.WorldHeight)
+}
+
+// Teleport moves an online player to the given coordinates. Positions
+// outside the world return ErrInvalidPosition.
+func (s *GameServer) Teleport(nickname string, x, y float32) (*models.Player, error) {
+	if !s.inWorld(x, y) {
+		return nil, ErrInvalidPosition
+	}
+
+	gc := s.findConnectionByNickname(nickname)
+	if gc == nil {
+		return nil, ErrPlayerNotFound
+	}
+
+	gc.mu.Lock()
+	p := gc.player
+	if p == nil {
+		gc.mu.Unlock()
+		return nil, ErrPlayerNotFound
+	}
+	s.anticheat.Teleported(p.ID)
+	gc.stopRoute()
+	p.CoordX, p.CoordY = x, y
+	p.LastUpdated = time.Now()
+	teleported := p.Copy()
+	entityID := gc.entity
+	gc.mu.Unlock()
+
+	// update moving players so nearby clients receive the new position
+	s.mu.Lock()
+	s.movingPlayers[p.ID] = gc
+	s.placeEntity(entityID, x, y)
+	s.mu.Unlock()
+
+	return teleported, nil
+}
+
+// BroadcastNotice sends a notice chat message to every connected client
+func (s *GameServer) BroadcastNotice(message string) error {
+	data, err := b.EncodeChatMessage(&b.ChatMessage{
+		Type:    b.ChatMessageTypeNotice,
+		From:    "Notice",
+		Message: message,
+	})
+	if err != nil {
+		return err
+	}
+	s.Broadcast(b.Message{
+		Type: types.ChatMessage,
+		Data: data,
+	})
+	return nil
+}
+
+// Tile returns the tile at the given coordinates
+func (s *GameServer) Tile(x, y uint16) (models.MapTile, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tile, exists := s.tiles[fmt.Sprintf("%d,%d", x, y)]
+	return tile, exists
+}
+
+// EditTile applies edit to an existing tile, marks it for saving and sends
+// it to the players who see it
+func (s *GameServer) EditTile(x, y uint16, edit func(tile *models.MapTile)) (models.MapTile, error) {
+	s.mu.Lock()
+	key := fmt.Sprintf("%d,%d", x, y)
+	tile, exists := s.tiles[key]
+	if !exists {
+		s.mu.Unlock()
+		return models.MapTile{}, ErrTileNotFound
+	}
+	edit(&tile)
+	tile.CoordX, tile.CoordY = x, y
+	s.tiles[key] = tile
+	s.updatedTiles[key] = tile
+	s.pathfinder.Invalidate(int(x), int(y))
+	s.indexTile(tile)
+	s.mu.Unlock()
+
+	s.broadcastTileChanges([]models.MapTile{tile})
+	return tile, nil
+}
+
+// Countries returns all countries
+func (s *GameServer) Countries() []models.Country {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	countries := make([]models.Country, 0, len(s.countries))
+	for _, country := range s.countries {
+		countries = append(countries, country)
+	}
+	return countries
+}
+
+// EditCountry applies edit to a country and persists it immediately
+func (s *GameServer) EditCountry(id uint8, edit func(country *models.Country)) (models.Country, error) {
+	s.mu.Lock()
+	country, exists := s.countries[id]
+	if !exists {
+		s.mu.Unlock()
+		return models.Country{}, ErrCountryNotFound
+	}
+	edit(&country)
+	country.ID = id
+	s.countries[id] = country
+	s.mu.Unlock()
+
+	return country, s.store.SaveCountry(&country)
+}
+
+// Stats returns a snapshot of the server state
+func (s *GameServer) Stats() ServerStats {
+	loopStats := s.loop.Stats()
+	players := len(s.OnlinePlayers())
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return ServerStats{
+		Connections:    len(s.connections),
+		Players:        players,
+		MovingPlayers:  len(s.movingPlayers),
+		Entities:       s.world.Len(),
+		DirtyTiles:     len(s.updatedTiles),
+		Tiles:          len(s.tiles),
+		Countries:      len(s.countries),
+		Tick:           loopStats.Tick,
+		TicksPerSecond: s.loop.TicksPerSecond(),
+		TickOverruns:   loopStats.Overruns,
+		TicksSkipped:   loopStats.Skipped,
+		MaxTickTime:    loopStats.MaxDuration,
+		Uptime:         time.Since(s.startedAt),
+	}
+}
@@ -0,0 +1,211 @@
+package socket
+
+import (
+	"errors"
+	"fmt"
+	"projectt/config"
+	"projectt/game/ai"
+	"projectt/game/entity"
+	"projectt/game/movement"
+	"projectt/game/pathfinding"
+	"projectt/models"
+	"projectt/types"
+	"time"
+)
+
+var (
+	ErrUnitNotFound = errors.New("unit not found")
+	// ErrSpawnBlocked is returned for tiles a unit may not be placed on
+	ErrSpawnBlocked = errors.New("tile cannot take the unit")
+)
+
+// newBrain returns the AI for cfg, nil when it is disabled
+func newBrain(cfg *config.Config) *ai.Brain {
+	if !cfg.AI.Enabled {
+		return nil
+	}
+	difficulty, _ := ai.ParseDifficulty(cfg.AI.Difficulty)
+	return ai.NewBrain(ai.Config{
+		Difficulty:    difficulty,
+		ThinkInterval: cfg.AI.ThinkInterval,
+		Budget:        cfg.AI.TickBudget,
+	})
+}
+
+// indexTile keeps the AI's view of the territories current. It must be
+// called for every loaded and every changed tile.
+func (s *GameServer) indexTile(tile models.MapTile) {
+	if s.brain != nil {
+		s.brain.Territory().Update(tile)
+	}
+}
+
+// captureTiles lets every standing occupier occupy the enemy tile it stands
+// on, or free an occupied tile of its own country, once it held the tile
+// uncontested for the capture time, and returns the changed tiles. s.mu
+// must be held.
+func (s *GameServer) captureTiles(dt time.Duration) []models.MapTile {
+	changed := make([]models.MapTile, 0)
+	for _, e := range s.world.All() {
+		if e.Occupier == nil {
+			continue
+		}
+
+		key := fmt.Sprintf("%d,%d", int(e.X), int(e.Y))
+		tile, exists := s.tiles[key]
+		occupier := uint8(0)
+		if tile.OccupiedByCountryID != nil {
+			occupier = *tile.OccupiedByCountryID
+		}
+		capture := tile.OwnerCountryID != 0 && tile.OwnerCountryID != e.Country && occupier != e.Country
+		liberate := tile.OwnerCountryID == e.Country && occupier != 0 && occupier != e.Country
+		if !exists || !(capture || liberate) || e.Moving() || s.contested(e) {
+			e.Occupier.Holding = 0
+			continue
+		}
+
+		e.Occupier.Holding += dt
+		if e.Occupier.Holding < s.cfg.AI.CaptureTime {
+			continue
+		}
+		e.Occupier.Holding = 0
+
+		if capture {
+			now := time.Now()
+			country := e.Country
+			tile.OccupiedByCountryID, tile.OccupiedAt = &country, &now
+		} else {
+			tile.OccupiedByCountryID, tile.OccupiedAt = nil, nil
+		}
+		s.tiles[key] = tile
+		s.updatedTiles[key] = tile
+		s.indexTile(tile)
+		s.captures.Add(1)
+		changed = append(changed, tile)
+	}
+	return changed
+}
+
+// contested reports whether a force of another country is close enough to
+// defend the tile e stands on. s.mu must be held.
+func (s *GameServer) contested(e *entity.Entity) bool {
+	for _, other := range s.world.Near(e.X, e.Y, ai.DefenseRadius) {
+		if isForce(other) && other.Country != e.Country {
+			return true
+		}
+	}
+	return false
+}
+
+// isForce reports whether an entity counts towards the strength of its
+// country: players, units and soldiers
+func isForce(e *entity.Entity) bool {
+	return e.Country != 0 && e.Projectile == nil
+}
+
+// aiWorld is the game as seen by the AI
+type aiWorld struct {
+	s *GameServer
+}
+
+func (w *aiWorld) AIControlled() []uint8 {
+	w.s.mu.RLock()
+	defer w.s.mu.RUnlock()
+
+	countries := make([]uint8, 0)
+	for id, country := range w.s.countries {
+		if country.IsAIControlled {
+			countries = append(countries, id)
+		}
+	}
+	return countries
+}
+
+func (w *aiWorld) Units(country uint8) []ai.Unit {
+	w.s.mu.RLock()
+	defer w.s.mu.RUnlock()
+
+	units := make([]ai.Unit, 0)
+	for _, e := range w.s.world.All() {
+		if e.Body != nil && e.Player == nil && e.Country == country {
+			units = append(units, ai.Unit{ID: uint(e.ID), Type: e.Body.UnitType, X: e.X, Y: e.Y, Moving: e.Body.Route != nil})
+		}
+	}
+	return units
+}
+
+func (w *aiWorld) Tile(p ai.Point) (uint8, uint8, bool) {
+	w.s.mu.RLock()
+	tile, exists := w.s.tiles[fmt.Sprintf("%d,%d", p.X, p.Y)]
+	w.s.mu.RUnlock()
+
+	occupier := uint8(0)
+	if tile.OccupiedByCountryID != nil {
+		occupier = *tile.OccupiedByCountryID
+	}
+	return tile.OwnerCountryID, occupier, exists
+}
+
+func (w *aiWorld) Strength(p ai.Point, radius float32) map[uint8]int {
+	w.s.mu.RLock()
+	defer w.s.mu.RUnlock()
+
+	strength := make(map[uint8]int)
+	for _, e := range w.s.world.Near(float32(p.X)+0.5, float32(p.Y)+0.5, radius) {
+		if isForce(e) {
+			strength[e.Country]++
+		}
+	}
+	return strength
+}
+
+func (w *aiWorld) Spawn(country uint8, unitType types.UnitType, at ai.Point) error {
+	s := w.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tile, exists := s.tiles[fmt.Sprintf("%d,%d", at.X, at.Y)]
+	if !exists {
+		return ErrTileNotFound
+	}
+	occupied := tile.OccupiedByCountryID != nil && *tile.OccupiedByCountryID != country
+	if tile.OwnerCountryID != country || occupied || !movement.Passable(unitType, tile.TileType) {
+		return ErrSpawnBlocked
+	}
+
+	e := ai.NewEntity(unitType, country)
+	e.X, e.Y = float32(at.X)+0.5, float32(at.Y)+0.5
+	s.world.Add(e)
+	return nil
+}
+
+func (w *aiWorld) Move(id uint, to ai.Point, deadline time.Time) error {
+	s := w.s
+	s.mu.RLock()
+	e := s.world.Get(entity.ID(id))
+	if e == nil || e.Body == nil {
+		s.mu.RUnlock()
+		return ErrUnitNotFound
+	}
+	unitType, x, y := e.Body.UnitType, e.X, e.Y
+	s.mu.RUnlock()
+
+	// the path is searched without the server lock, see newPathfinder
+	from := pathfinding.Point{X: int(x), Y: int(y)}
+	route, err := s.pathfinder.FindBefore(unitType, from, to, deadline)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.world.Get(entity.ID(id)) != e {
+		return ErrUnitNotFound
+	}
+	e.Body.Route = route
+	if len(route) == 0 {
+		// already there
+		e.Body.Route = nil
+	}
+	return nil
+}
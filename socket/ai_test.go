@@ -0,0 +1,178 @@
+package socket
+
+import (
+	"encoding/binary"
+	"projectt/config"
+	"projectt/game/ai"
+	"projectt/game/pathfinding"
+	"projectt/models"
+	"projectt/types"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// giveEast hands the tiles from x = 40 on to country 2, with border tiles
+// on both sides
+func giveEast(t *testing.T, server *GameServer) {
+	t.Helper()
+	for x := uint16(39); x < 64; x++ {
+		for y := uint16(0); y < 64; y++ {
+			_, err := server.EditTile(x, y, func(tile *models.MapTile) {
+				if x >= 40 {
+					tile.OwnerCountryID = 2
+				}
+				tile.IsBorder = x == 39 || x == 40
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestAICountryRetakesTiles(t *testing.T) {
+	server, _ := newTestServer(t, func(cfg *config.Config) {
+		cfg.AI.ThinkInterval = 10 * time.Millisecond
+		cfg.AI.CaptureTime = 100 * time.Millisecond
+	})
+	giveEast(t, server)
+
+	// country 1 holds a tile of country 2, which the AI frees
+	occupier := uint8(1)
+	now := time.Now()
+	if _, err := server.EditTile(50, 30, func(tile *models.MapTile) {
+		tile.OccupiedByCountryID, tile.OccupiedAt = &occupier, &now
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.EditCountry(2, func(country *models.Country) { country.IsAIControlled = true }); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		tile, _ := server.Tile(50, 30)
+		if tile.OccupiedByCountryID == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("tile still occupied, entities: %+v", server.Entities())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	units := 0
+	for _, e := range server.Entities() {
+		if e.Body != nil && e.Country == 2 {
+			units++
+		}
+	}
+	if units == 0 {
+		t.Fatal("no unit of country 2 in the world")
+	}
+	if server.captures.Load() == 0 {
+		t.Fatal("freeing the tile was not counted")
+	}
+}
+
+func TestAICapturesAreSent(t *testing.T) {
+	server, _ := newTestServer(t, func(cfg *config.Config) {
+		cfg.AI.ThinkInterval = 10 * time.Millisecond
+		cfg.AI.CaptureTime = 100 * time.Millisecond
+	})
+	giveEast(t, server)
+	occupier := uint8(1)
+	now := time.Now()
+	if _, err := server.EditTile(50, 30, func(tile *models.MapTile) {
+		tile.OccupiedByCountryID, tile.OccupiedAt = &occupier, &now
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	client := dialTestClient(t, server)
+	client.login("Alice")
+	if _, err := server.EditCountry(2, func(country *models.Country) { country.IsAIControlled = true }); err != nil {
+		t.Fatal(err)
+	}
+
+	// the freed tile comes again with its chunk 3,1, where it is tile 46
+	// as the chunk is sent column by column
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		msg, err := client.readWithin(time.Until(deadline))
+		if err != nil {
+			t.Fatalf("the freed tile was not sent: %v", err)
+		}
+		if msg.Type != types.ChunkDataMessage || binary.LittleEndian.Uint16(msg.Data) != 3 || binary.LittleEndian.Uint16(msg.Data[2:]) != 1 {
+			continue
+		}
+		if occupiedBy := msg.Data[4+(2*16+14)*6+5]; occupiedBy == 0 {
+			break
+		}
+	}
+}
+
+func TestAIDisabled(t *testing.T) {
+	server, _ := newTestServer(t, func(cfg *config.Config) {
+		cfg.AI.Enabled = false
+	})
+	giveEast(t, server)
+	if _, err := server.EditCountry(2, func(country *models.Country) { country.IsAIControlled = true }); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if entities := server.Entities(); len(entities) != 0 {
+		t.Fatalf("disabled AI spawned %d entities", len(entities))
+	}
+}
+
+func TestAIThinksWithinBudget(t *testing.T) {
+	server, _ := newTestServer(t, func(cfg *config.Config) {
+		cfg.AI.Enabled = false
+	})
+	giveEast(t, server)
+	if _, err := server.EditCountry(2, func(country *models.Country) { country.IsAIControlled = true }); err != nil {
+		t.Fatal(err)
+	}
+
+	// every tile the searches look at is slow, a route across the east
+	// takes far longer than the budget
+	var lookups atomic.Int64
+	server.mu.Lock()
+	server.pathfinder = pathfinding.New(func(x, y int) (types.TileType, bool) {
+		lookups.Add(1)
+		// busy, sleeping is far coarser than this
+		for start := time.Now(); time.Since(start) < 100*time.Microsecond; {
+		}
+		server.mu.RLock()
+		defer server.mu.RUnlock()
+		return server.tileType(x, y)
+	}, pathfinding.Config{Width: 64, Height: 64, ChunkSize: 16, MaxNodes: 200000})
+	server.mu.Unlock()
+
+	world := &aiWorld{s: server}
+	for y := 10; y < 60; y += 10 {
+		if err := world.Spawn(2, types.UnitTypeInfantry, ai.Point{X: 63, Y: y}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const budget = 20 * time.Millisecond
+	brain := ai.NewBrain(ai.Config{Difficulty: ai.Normal, ThinkInterval: time.Second, Budget: budget})
+	for x := uint16(0); x < 64; x++ {
+		for y := uint16(0); y < 64; y++ {
+			tile, _ := server.Tile(x, y)
+			brain.Territory().Update(tile)
+		}
+	}
+
+	start := time.Now()
+	brain.Think(world, start)
+	if elapsed := time.Since(start); elapsed > 2*budget {
+		t.Fatalf("thinking took %v with a budget of %v", elapsed, budget)
+	}
+	if lookups.Load() == 0 {
+		t.Fatal("no route was searched")
+	}
+}
@@ -0,0 +1,74 @@
+package socket
+
+import (
+	"fmt"
+	"log"
+	"projectt/config"
+	"projectt/game/anticheat"
+	"projectt/game/permissions"
+	"time"
+)
+
+// newValidator returns the movement validator for cfg, nil when the
+// anti-cheat is disabled
+func newValidator(cfg *config.Config) *anticheat.Validator {
+	if !cfg.AntiCheat.Enabled {
+		return nil
+	}
+	return anticheat.New(anticheat.Config{
+		TicksPerSecond: cfg.Game.TicksPerSecond,
+		MaxClockDrift:  cfg.AntiCheat.MaxClockDrift,
+		ScoreDecay:     cfg.AntiCheat.ScoreDecay,
+		FlagScore:      cfg.AntiCheat.FlagScore,
+		KickScore:      cfg.AntiCheat.KickScore,
+	})
+}
+
+// inputDropped reports a movement input refused by the rate limit to the
+// anti-cheat. The caller must not hold the connection or server mutex.
+func (gc *GameConnection) inputDropped() {
+	gc.mu.RLock()
+	player := gc.player
+	gc.mu.RUnlock()
+	if player == nil {
+		return
+	}
+	gc.server.reportCheat(player.ID, player.Nickname, gc.server.anticheat.InputDropped(player.ID, time.Now()))
+}
+
+// reportCheat logs an offense found by the anti-cheat, then alerts
+// moderators or kicks the player as the report asks. The caller must not
+// hold a connection or the server mutex.
+func (s *GameServer) reportCheat(playerID uint, nickname string, report anticheat.Report) {
+	if report.Offense == "" {
+		return
+	}
+	s.metrics.CheatOffenses.WithLabelValues(string(report.Offense)).Inc()
+	// input rate offenses come in floods and are only logged once they lead to an action
+	if report.Offense != anticheat.InputRate || report.Action != anticheat.None {
+		log.Printf("ANTICHEAT: %s (id %d) %s, suspicion %.0f", nickname, playerID, report.Offense, report.Score)
+	}
+
+	switch report.Action {
+	case anticheat.Flag:
+		s.metrics.CheatActions.WithLabelValues("flag").Inc()
+		s.alertStaff(fmt.Sprintf("Anti-cheat: %s is suspicious (%s, score %.0f)", nickname, report.Offense, report.Score))
+	case anticheat.Kick:
+		s.metrics.CheatActions.WithLabelValues("kick").Inc()
+		s.alertStaff(fmt.Sprintf("Anti-cheat: %s was kicked (%s, score %.0f)", nickname, report.Offense, report.Score))
+		s.Kick(nickname, "Kicked for suspicious movement")
+	}
+}
+
+// alertStaff sends a system message to every online player allowed to see
+// anti-cheat alerts
+func (s *GameServer) alertStaff(text string) {
+	for _, gc := range s.connectionsSnapshot() {
+		gc.mu.RLock()
+		staff := gc.player != nil && gc.player.Can(permissions.CheatAlerts)
+		gc.mu.RUnlock()
+		if staff {
+			gc.sendSystemMessage(text)
+		}
+	}
+}
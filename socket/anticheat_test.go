@@ -0,0 +1,105 @@
+package socket
+
+import (
+	"encoding/binary"
+	"math"
+	b "projectt/binary"
+	"projectt/config"
+	"projectt/types"
+	"testing"
+	"time"
+)
+
+// move sends a movement input over TCP
+func (c *testClient) move(dirX, dirY, timestamp float32) {
+	data := make([]byte, 12)
+	binary.LittleEndian.PutUint32(data[0:], math.Float32bits(dirX))
+	binary.LittleEndian.PutUint32(data[4:], math.Float32bits(dirY))
+	binary.LittleEndian.PutUint32(data[8:], math.Float32bits(timestamp))
+	c.send(b.Message{Type: types.PlayerMovementMessage, Data: data})
+}
+
+func suspicion(server *GameServer, nickname string) float64 {
+	for _, info := range server.Connections() {
+		if info.Nickname == nickname {
+			return info.Suspicion
+		}
+	}
+	return 0
+}
+
+func TestAntiCheatClockDrift(t *testing.T) {
+	server, _ := newTestServer(t, func(cfg *config.Config) {
+		cfg.AntiCheat.ScoreDecay = 0
+		cfg.AntiCheat.FlagScore = 10
+		cfg.AntiCheat.KickScore = 20
+	})
+	alice := dialTestClient(t, server)
+	mod := dialTestClient(t, server)
+	alice.login("Alice")
+	mod.login("Mod")
+
+	alice.move(1, 0, 1)
+	// a forged timestamp far ahead of the server clock is dropped and
+	// reported to moderators
+	alice.move(0, 1, 100000)
+	mod.expectChat("Anti-cheat: Alice is suspicious (clock_drift, score 10)")
+	if score := suspicion(server, "Alice"); score != 10 {
+		t.Fatalf("expected a suspicion score of 10, got %v", score)
+	}
+
+	// repeat offenders are kicked
+	alice.move(0, 1, 200000)
+	msg := alice.expect(types.DisconnectMessage)
+	if reason, _, _ := decodeTestDisconnect(t, msg.Data); reason != b.DisconnectReasonKicked {
+		t.Fatalf("expected a kick, got reason %d", reason)
+	}
+	mod.expectChat("Anti-cheat: Alice was kicked (clock_drift, score 20)")
+}
+
+func TestAntiCheatTeleport(t *testing.T) {
+	server, _ := newTestServer(t)
+	mod := dialTestClient(t, server)
+	mod.login("Mod")
+
+	mod.move(1, 0, 1)
+	time.Sleep(50 * time.Millisecond)
+
+	// sanctioned teleports are not mistaken for cheating
+	mod.chat("/tp @me 60 60")
+	mod.expectChat("Player Mod successfully teleported to 60,60")
+	time.Sleep(100 * time.Millisecond)
+	if score := suspicion(server, "Mod"); score != 0 {
+		t.Fatalf("teleport raised suspicion to %v", score)
+	}
+
+	// anything else moving a player that far is reported
+	gc := server.findConnectionByNickname("Mod")
+	gc.mu.Lock()
+	gc.player.CoordX = 10
+	gc.mu.Unlock()
+	time.Sleep(100 * time.Millisecond)
+	if score := suspicion(server, "Mod"); score == 0 {
+		t.Fatal("jump was not detected")
+	}
+}
+
+func TestAntiCheatDisabled(t *testing.T) {
+	server, _ := newTestServer(t, func(cfg *config.Config) {
+		cfg.AntiCheat.Enabled = false
+	})
+	alice := dialTestClient(t, server)
+	alice.login("Alice")
+
+	alice.move(1, 0, 1)
+	alice.move(0, 1, 100000)
+	time.Sleep(50 * time.Millisecond)
+
+	gc := server.findConnectionByNickname("Alice")
+	gc.mu.RLock()
+	dirY := gc.player.DirY
+	gc.mu.RUnlock()
+	if dirY != 1 {
+		t.Fatal("input was dropped with the anti-cheat disabled")
+	}
+}
@@ -0,0 +1,221 @@
+package socket
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	b "projectt/binary"
+	"projectt/models"
+	"projectt/storage"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBanNotFound    = errors.New("ban not found")
+	ErrInvalidAddress = errors.New("invalid IP address or CIDR range")
+)
+
+// remoteIP returns the client's IP address, or nil for transports without
+// one (e.g. in-memory pipes)
+func remoteIP(conn net.Conn) net.IP {
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
+
+// banExpiry returns when a ban of the given duration ends, nil if permanent
+func banExpiry(duration time.Duration) *time.Time {
+	if duration <= 0 {
+		return nil
+	}
+	expires := time.Now().Add(duration)
+	return &expires
+}
+
+// banDisconnectMessage tells a banned client why and for how long
+func banDisconnectMessage(ban *models.Ban) *b.DisconnectMessage {
+	msg := &b.DisconnectMessage{
+		Reason:  b.DisconnectReasonBanned,
+		Message: ban.Reason,
+	}
+	if ban.ExpiresAt != nil {
+		msg.Until = ban.ExpiresAt.UnixMilli()
+	}
+	return msg
+}
+
+// findBan returns an active ban on the player account or the IP address.
+// A playerID of 0 only checks IP bans.
+func (s *GameServer) findBan(playerID uint, ip net.IP) *models.Ban {
+	now := time.Now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := range s.bans {
+		ban := &s.bans[i]
+		if !ban.Active(now) {
+			continue
+		}
+		if (playerID != 0 && ban.PlayerID != nil && *ban.PlayerID == playerID) || ban.MatchesIP(ip) {
+			found := *ban
+			return &found
+		}
+	}
+	return nil
+}
+
+// Bans returns the bans that are currently in effect
+func (s *GameServer) Bans() []models.Ban {
+	now := time.Now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	bans := make([]models.Ban, 0, len(s.bans))
+	for _, ban := range s.bans {
+		if ban.Active(now) {
+			bans = append(bans, ban)
+		}
+	}
+	return bans
+}
+
+func (s *GameServer) addBan(ban *models.Ban) error {
+	if err := s.store.SaveBan(ban); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.bans = append(s.bans, *ban)
+	s.mu.Unlock()
+	return nil
+}
+
+// BanPlayer bans a player account for the given duration, or permanently if
+// duration is 0, and disconnects the player if they are online
+func (s *GameServer) BanPlayer(nickname string, duration time.Duration, reason, bannedBy string) (*models.Ban, error) {
+	player, err := s.store.FindPlayerByNickname(nickname)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrPlayerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ban := &models.Ban{
+		PlayerID:  &player.ID,
+		Nickname:  player.Nickname,
+		Reason:    reason,
+		BannedBy:  bannedBy,
+		ExpiresAt: banExpiry(duration),
+	}
+	if err := s.addBan(ban); err != nil {
+		return nil, err
+	}
+	log.Printf("AUDIT: %s banned %s (ban %d, %s): %s", bannedBy, player.Nickname, ban.ID, banLength(duration), reason)
+
+	if gc := s.findConnectionByNickname(player.Nickname); gc != nil {
+		gc.Disconnect(banDisconnectMessage(ban))
+	}
+	return ban, nil
+}
+
+// BanIP bans an IP address or CIDR range for the given duration, or
+// permanently if duration is 0, and disconnects every client connected from it
+func (s *GameServer) BanIP(address string, duration time.Duration, reason, bannedBy string) (*models.Ban, error) {
+	cidr := address
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return nil, ErrInvalidAddress
+		}
+		if ip.To4() != nil {
+			cidr += "/32"
+		} else {
+			cidr += "/128"
+		}
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, ErrInvalidAddress
+	}
+
+	ban := &models.Ban{
+		CIDR:      network.String(),
+		Reason:    reason,
+		BannedBy:  bannedBy,
+		ExpiresAt: banExpiry(duration),
+	}
+	if err := s.addBan(ban); err != nil {
+		return nil, err
+	}
+	log.Printf("AUDIT: %s banned %s (ban %d, %s): %s", bannedBy, ban.CIDR, ban.ID, banLength(duration), reason)
+
+	for _, gc := range s.connectionsSnapshot() {
+		if ban.MatchesIP(remoteIP(gc.conn)) {
+			gc.Disconnect(banDisconnectMessage(ban))
+		}
+	}
+	return ban, nil
+}
+
+// Unban lifts a ban by ID
+func (s *GameServer) Unban(id uint) error {
+	err := s.store.DeleteBan(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrBanNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	for i, ban := range s.bans {
+		if ban.ID == id {
+			s.bans = append(s.bans[:i], s.bans[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	log.Printf("AUDIT: ban %d lifted", id)
+	return nil
+}
+
+// UnbanPlayer lifts every account ban of a player
+func (s *GameServer) UnbanPlayer(nickname string) error {
+	player, err := s.store.FindPlayerByNickname(nickname)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrPlayerNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uint, 0)
+	s.mu.RLock()
+	for _, ban := range s.bans {
+		if ban.PlayerID != nil && *ban.PlayerID == player.ID {
+			ids = append(ids, ban.ID)
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(ids) == 0 {
+		return ErrBanNotFound
+	}
+	for _, id := range ids {
+		if err := s.Unban(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func banLength(duration time.Duration) string {
+	if duration <= 0 {
+		return "permanent"
+	}
+	return fmt.Sprintf("for %v", duration)
+}
@@ -0,0 +1,358 @@
+package socket
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	b "projectt/binary"
+	"projectt/game/permissions"
+	"projectt/models"
+	"projectt/storage"
+	"projectt/types"
+	"sort"
+	"time"
+)
+
+// chatError is an error code sent to the client in the message error field
+type chatError string
+
+func (e chatError) Error() string {
+	return string(e)
+}
+
+const (
+	errInvalidChannel    chatError = "error.chat.invalid_channel"
+	errRecipientRequired chatError = "error.chat.recipient_required"
+	errMailboxFull       chatError = "error.chat.mailbox_full"
+	errChatUnavailable   chatError = "error.chat.unavailable"
+	errChatEncoding      chatError = "error.chat.encoding_failed"
+	errMuted             chatError = "error.chat.muted"
+	errRateLimited       chatError = "error.chat.rate_limited"
+	errSpam              chatError = "error.chat.spam"
+	errPlayerNotFound    chatError = "error.general.player_not_found"
+)
+
+// channelPermissions maps client chat channels to the permission they need
+var channelPermissions = map[b.ChatMessageType]permissions.Permission{
+	b.ChatMessageTypeGeneral: permissions.Chat,
+	b.ChatMessageTypeWhisper: permissions.Whisper,
+	b.ChatMessageTypeCountry: permissions.CountryChat,
+	b.ChatMessageTypeLocal:   permissions.LocalChat,
+}
+
+func (gc *GameConnection) handleChat(data []byte) {
+	gc.mu.RLock()
+	if gc.player == nil {
+		gc.mu.RUnlock()
+		gc.SendTCPMessage(b.Message{
+			Type:  types.ChatMessage,
+			Error: "error.login.required",
+		})
+		return
+	}
+	player := gc.player.Copy()
+	gc.mu.RUnlock()
+
+	msg, err := b.DecodeChatMessage(data)
+	if err != nil {
+		gc.violation("malformed")
+		gc.SendTCPMessage(b.Message{
+			Type:  types.ChatMessage,
+			Error: "error.chat.invalid",
+		})
+		return
+	}
+
+	if len(msg.Message) == 0 {
+		gc.SendTCPMessage(b.Message{
+			Type:  types.ChatMessage,
+			Error: "error.chat.empty",
+		})
+		return
+	}
+
+	// Commands count towards the rate limit too
+	if !gc.chatLimiter.Allow() {
+		gc.server.metrics.ChatBlocked.WithLabelValues("rate_limited").Inc()
+		gc.SendTCPMessage(b.Message{
+			Type:  types.ChatMessage,
+			Error: string(errRateLimited),
+		})
+		return
+	}
+
+	if msg.Message[0] == '/' {
+		gc.server.commands.execute(gc, player, msg.Message)
+		return
+	}
+
+	if !msg.Type.IsClientChannel() {
+		gc.SendTCPMessage(b.Message{
+			Type:  types.ChatMessage,
+			Error: string(errInvalidChannel),
+		})
+		return
+	}
+
+	if err := gc.sendChat(player, msg.Type, msg.Message, msg.To); err != nil {
+		gc.SendTCPMessage(b.Message{
+			Type:  types.ChatMessage,
+			Error: err.Error(),
+		})
+	}
+}
+
+// sendChat delivers a message from player on the given channel. Whispers go
+// to the named online player and are echoed back to the sender, country chat
+// to players of the sender's country and local chat to players within view
+// distance.
+func (gc *GameConnection) sendChat(player *models.Player, channel b.ChatMessageType, text, to string) error {
+	if !gc.authorize(player, channelPermissions[channel], "") {
+		return nil
+	}
+
+	if player.IsMuted(time.Now()) {
+		gc.server.metrics.ChatBlocked.WithLabelValues("muted").Inc()
+		return errMuted
+	}
+	if !gc.chatRepeats.Allow(text, time.Now()) {
+		gc.server.metrics.ChatBlocked.WithLabelValues("spam").Inc()
+		return errSpam
+	}
+
+	chatMessage := b.ChatMessage{
+		Type:    channel,
+		From:    player.Nickname,
+		Message: gc.server.chatFilter.Clean(text),
+	}
+
+	var recipient *GameConnection
+	if channel == b.ChatMessageTypeWhisper {
+		if to == "" {
+			return errRecipientRequired
+		}
+		recipient = gc.server.findConnectionByNickname(to)
+		if recipient == nil {
+			return gc.sendOfflineWhisper(player, to, chatMessage.Message)
+		}
+		recipient.mu.RLock()
+		chatMessage.To = recipient.player.Nickname
+		recipient.mu.RUnlock()
+	}
+
+	data, err := b.EncodeChatMessage(&chatMessage)
+	if err != nil {
+		return errChatEncoding
+	}
+	msg := b.Message{
+		Type: types.ChatMessage,
+		Data: data,
+	}
+
+	switch channel {
+	case b.ChatMessageTypeWhisper:
+		if err := recipient.SendTCPMessage(msg); errors.Is(err, errSessionSuspended) {
+			// the recipient gets it as mail when the session resumes
+			return gc.sendOfflineWhisper(player, chatMessage.To, chatMessage.Message)
+		}
+		if recipient != gc {
+			gc.SendTCPMessage(msg)
+		}
+	case b.ChatMessageTypeCountry:
+		gc.server.BroadcastToCountry(msg, player.CountryID)
+		gc.server.recordChat(player, channel, chatMessage.Message)
+	case b.ChatMessageTypeLocal:
+		gc.server.BroadcastInRange(msg, player.CoordX, player.CoordY, true)
+	default:
+		// Broadcast chat message to all clients
+		gc.server.Broadcast(msg)
+		gc.server.recordChat(player, channel, chatMessage.Message)
+	}
+	return nil
+}
+
+// sendOfflineWhisper queues a whisper as mail for a player who is not online
+func (gc *GameConnection) sendOfflineWhisper(from *models.Player, to, text string) error {
+	recipient, err := gc.server.store.FindPlayerByNickname(to)
+	if errors.Is(err, storage.ErrNotFound) {
+		return errPlayerNotFound
+	}
+	if err != nil {
+		log.Printf("Error finding whisper recipient %s: %v", to, err)
+		return errChatUnavailable
+	}
+
+	count, err := gc.server.store.CountMail(recipient.ID)
+	if err != nil {
+		log.Printf("Error counting mail of %s: %v", recipient.Nickname, err)
+		return errChatUnavailable
+	}
+	if count >= int64(gc.server.cfg.Chat.MaxOfflineMessages) {
+		return errMailboxFull
+	}
+
+	err = gc.server.store.SaveMail(&models.Mail{
+		RecipientID: recipient.ID,
+		From:        from.Nickname,
+		Message:     text,
+	})
+	if err != nil {
+		log.Printf("Error saving mail for %s: %v", recipient.Nickname, err)
+		return errChatUnavailable
+	}
+
+	// Echo the whisper like a delivered one
+	data, err := b.EncodeChatMessage(&b.ChatMessage{
+		Type:    b.ChatMessageTypeWhisper,
+		From:    from.Nickname,
+		Message: text,
+		To:      recipient.Nickname,
+	})
+	if err != nil {
+		return errChatEncoding
+	}
+	gc.SendTCPMessage(b.Message{
+		Type: types.ChatMessage,
+		Data: data,
+	})
+	return gc.sendSystemMessage(fmt.Sprintf("%s is offline and will receive your message on their next login", recipient.Nickname))
+}
+
+// deliverMail sends the whispers a player received while offline. Mail is
+// deleted once it was sent, what could not be sent waits for the next login.
+func (gc *GameConnection) deliverMail(player *models.Player) {
+	mail, err := gc.server.store.LoadMail(player.ID)
+	if err != nil {
+		log.Printf("Error loading mail of %s: %v", player.Nickname, err)
+		return
+	}
+
+	delivered := make([]uint, 0, len(mail))
+	for _, m := range mail {
+		data, err := b.EncodeChatMessage(&b.ChatMessage{
+			Type:    b.ChatMessageTypeWhisper,
+			From:    m.From,
+			Message: m.Message,
+			To:      player.Nickname,
+		})
+		if err != nil {
+			// it would fail on every login
+			delivered = append(delivered, m.ID)
+			continue
+		}
+		if err := gc.SendTCPMessage(b.Message{Type: types.ChatMessage, Data: data}); err != nil {
+			break
+		}
+		delivered = append(delivered, m.ID)
+	}
+
+	if len(delivered) == 0 {
+		return
+	}
+	if err := gc.server.store.DeleteMail(delivered); err != nil {
+		log.Printf("Error deleting delivered mail of %s: %v", player.Nickname, err)
+	}
+}
+
+// recordChat stores a general or country message for the chat history
+func (s *GameServer) recordChat(from *models.Player, channel b.ChatMessageType, text string) {
+	if s.cfg.Chat.HistorySize == 0 {
+		return
+	}
+
+	err := s.store.SaveChatMessage(&models.ChatMessage{
+		Channel:   uint8(channel),
+		CountryID: from.CountryID,
+		FromID:    from.ID,
+		From:      from.Nickname,
+		Message:   text,
+	})
+	if err != nil {
+		log.Printf("Error saving chat message: %v", err)
+	}
+}
+
+// chatHistory returns the latest general messages and those of a country,
+// oldest first. Players without a country only get the general messages.
+func (s *GameServer) chatHistory(countryID uint8) []b.ChatHistoryEntry {
+	size := s.cfg.Chat.HistorySize
+	if size == 0 {
+		return nil
+	}
+
+	general, err := s.store.RecentChatMessages(uint8(b.ChatMessageTypeGeneral), 0, size)
+	if err != nil {
+		log.Printf("Error loading chat history: %v", err)
+		return nil
+	}
+	messages := general
+	if countryID != 0 {
+		// the store reads country 0 as every country
+		country, err := s.store.RecentChatMessages(uint8(b.ChatMessageTypeCountry), countryID, size)
+		if err != nil {
+			log.Printf("Error loading country chat history: %v", err)
+			return nil
+		}
+		messages = append(messages, country...)
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].ID < messages[j].ID
+	})
+
+	history := make([]b.ChatHistoryEntry, 0, len(messages))
+	for _, msg := range messages {
+		history = append(history, b.ChatHistoryEntry{
+			SentAt: msg.CreatedAt.UnixMilli(),
+			Message: b.ChatMessage{
+				Type:    b.ChatMessageType(msg.Channel),
+				From:    msg.From,
+				Message: msg.Message,
+			},
+		})
+	}
+	return history
+}
+
+// pruneChatHistory deletes messages older than the retention period
+func (s *GameServer) pruneChatHistory() {
+	deleted, err := s.store.DeleteChatMessagesBefore(time.Now().Add(-s.cfg.Chat.HistoryRetention))
+	if err != nil {
+		log.Printf("Error pruning chat history: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Pruned %d chat messages", deleted)
+	}
+}
+
+// sendSystemMessage sends a system chat line to this connection only
+func (gc *GameConnection) sendSystemMessage(text string) error {
+	data, err := b.EncodeChatMessage(&b.ChatMessage{
+		Type:    b.ChatMessageTypeSystem,
+		From:    "System",
+		Message: text,
+	})
+	if err != nil {
+		return err
+	}
+	return gc.SendTCPMessage(b.Message{
+		Type: types.ChatMessage,
+		Data: data,
+	})
+}
+
+// authorize reports whether player holds perm. Denied attempts are answered
+// with error.chat.permission_denied and written to the audit log.
+func (gc *GameConnection) authorize(player *models.Player, perm permissions.Permission, command string) bool {
+	if player.Can(perm) {
+		return true
+	}
+
+	log.Printf("AUDIT: %s (id %d, rank %d, role %s) denied %s: %q",
+		player.Nickname, player.ID, player.Rank, player.StaffRole, perm, command)
+	gc.SendTCPMessage(b.Message{
+		Type:  types.ChatMessage,
+		Error: "error.chat.permission_denied",
+	})
+	return false
+}
@@ -0,0 +1,551 @@
+package socket
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	b "projectt/binary"
+	"projectt/game/permissions"
+	"projectt/models"
+	"projectt/types"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	errCommandUsage   chatError = "error.chat.usage"
+	errUnknownCommand chatError = "error.chat.unknown_command"
+	errCommandFailed  chatError = "error.chat.command_failed"
+	errBanNotFound    chatError = "error.chat.ban_not_found"
+	errOutranked      chatError = "error.chat.permission_denied"
+)
+
+type commandArg struct {
+	Name     string
+	Kind     b.CommandArgKind
+	Optional bool
+}
+
+type chatCommand struct {
+	Name        string
+	Aliases     []string
+	Description string
+	Args        []commandArg
+	// Permission required to run the command
+	Permission permissions.Permission
+	Handler    func(ctx *commandContext) error
+}
+
+// Usage returns the command syntax, e.g. "/tp <player> <x> <y>"
+func (c *chatCommand) Usage() string {
+	usage := "/" + c.Name
+	for _, arg := range c.Args {
+		name := arg.Name
+		if arg.Kind == b.CommandArgText {
+			name += "..."
+		}
+		if arg.Optional {
+			usage += " [" + name + "]"
+		} else {
+			usage += " <" + name + ">"
+		}
+	}
+	return usage
+}
+
+// usageError returns the error code sent when the arguments do not match
+func (c *chatCommand) usageError() chatError {
+	return chatError(fmt.Sprintf("%s_%s", errCommandUsage, c.Name))
+}
+
+// parseArgs checks the words after the command name against the argument
+// spec and returns them by name
+func (c *chatCommand) parseArgs(words []string) (map[string]string, bool) {
+	args := make(map[string]string, len(c.Args))
+	for i, spec := range c.Args {
+		if i >= len(words) {
+			if spec.Optional {
+				break
+			}
+			return nil, false
+		}
+
+		if spec.Kind == b.CommandArgText {
+			args[spec.Name] = strings.Join(words[i:], " ")
+			return args, true
+		}
+		if spec.Kind == b.CommandArgNumber {
+			n, err := strconv.ParseFloat(words[i], 32)
+			if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+				return nil, false
+			}
+		}
+		args[spec.Name] = words[i]
+	}
+
+	if len(words) > len(c.Args) {
+		return nil, false
+	}
+	return args, true
+}
+
+type commandContext struct {
+	gc      *GameConnection
+	server  *GameServer
+	player  *models.Player // copy of the sender
+	command *chatCommand
+	line    string
+	args    map[string]string
+}
+
+// Arg returns a named argument, or "" if an optional argument was not given
+func (ctx *commandContext) Arg(name string) string {
+	return ctx.args[name]
+}
+
+// Number returns a named number argument
+func (ctx *commandContext) Number(name string) float32 {
+	value, _ := strconv.ParseFloat(ctx.args[name], 32)
+	return float32(value)
+}
+
+// Duration returns a named duration argument such as 10m. "perm" returns 0.
+func (ctx *commandContext) Duration(name string) (time.Duration, bool) {
+	arg := ctx.args[name]
+	if strings.EqualFold(arg, "perm") {
+		return 0, true
+	}
+	duration, err := time.ParseDuration(arg)
+	if err != nil || duration <= 0 {
+		return 0, false
+	}
+	return duration, true
+}
+
+// Player returns a named player argument, resolving @me to the sender
+func (ctx *commandContext) Player(name string) string {
+	if strings.EqualFold(ctx.args[name], "@me") {
+		return ctx.player.Nickname
+	}
+	return ctx.args[name]
+}
+
+// Authorize checks an additional permission inside a handler
+func (ctx *commandContext) Authorize(perm permissions.Permission) bool {
+	return ctx.gc.authorize(ctx.player, perm, ctx.line)
+}
+
+// AuthorizeTarget checks that the sender outranks the player a moderation
+// command acts on. Denied attempts are written to the audit log.
+func (ctx *commandContext) AuthorizeTarget(nickname string) error {
+	role, err := ctx.gc.server.staffRole(nickname)
+	if err != nil {
+		return commandFailure(err)
+	}
+	if permissions.Outranks(ctx.player.StaffRole, role) {
+		return nil
+	}
+	log.Printf("AUDIT: %s (role %s) denied acting on %s (role %s): %q",
+		ctx.player.Nickname, ctx.player.StaffRole, nickname, role, ctx.line)
+	return errOutranked
+}
+
+// Reply sends a system message to the sender
+func (ctx *commandContext) Reply(format string, args ...any) error {
+	return ctx.gc.sendSystemMessage(fmt.Sprintf(format, args...))
+}
+
+type commandRegistry struct {
+	commands []*chatCommand
+	byName   map[string]*chatCommand // names and aliases
+}
+
+func newCommandRegistry() *commandRegistry {
+	return &commandRegistry{byName: make(map[string]*chatCommand)}
+}
+
+// Register adds a command. It panics if the name or an alias is taken.
+func (r *commandRegistry) Register(cmd *chatCommand) {
+	for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+		if _, exists := r.byName[name]; exists {
+			panic(fmt.Sprintf("chat command %q registered twice", name))
+		}
+		r.byName[name] = cmd
+	}
+	r.commands = append(r.commands, cmd)
+	sort.Slice(r.commands, func(i, j int) bool {
+		return r.commands[i].Name < r.commands[j].Name
+	})
+}
+
+func (r *commandRegistry) lookup(name string) *chatCommand {
+	return r.byName[strings.ToLower(name)]
+}
+
+// available returns the commands player may run
+func (r *commandRegistry) available(player *models.Player) []*chatCommand {
+	commands := make([]*chatCommand, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		if player.Can(cmd.Permission) {
+			commands = append(commands, cmd)
+		}
+	}
+	return commands
+}
+
+func (r *commandRegistry) execute(gc *GameConnection, player *models.Player, line string) {
+	words := strings.Fields(line)
+	cmd := r.lookup(strings.TrimPrefix(words[0], "/"))
+	if cmd == nil {
+		gc.SendTCPMessage(b.Message{
+			Type:  types.ChatMessage,
+			Error: string(errUnknownCommand),
+		})
+		return
+	}
+
+	if !gc.authorize(player, cmd.Permission, line) {
+		return
+	}
+
+	args, ok := cmd.parseArgs(words[1:])
+	if !ok {
+		gc.sendUsage(cmd)
+		return
+	}
+
+	err := cmd.Handler(&commandContext{
+		gc:      gc,
+		server:  gc.server,
+		player:  player,
+		command: cmd,
+		line:    line,
+		args:    args,
+	})
+	if err == errCommandUsage {
+		gc.sendUsage(cmd)
+	} else if err != nil {
+		gc.SendTCPMessage(b.Message{
+			Type:  types.ChatMessage,
+			Error: err.Error(),
+		})
+	}
+}
+
+// commandFailure maps an error from a server operation to the code sent to
+// the client. Unexpected errors are logged instead of being shown.
+func commandFailure(err error) error {
+	if errors.Is(err, ErrPlayerNotFound) {
+		return errPlayerNotFound
+	}
+	if errors.Is(err, ErrBanNotFound) {
+		return errBanNotFound
+	}
+	log.Printf("Error running command: %v", err)
+	return errCommandFailed
+}
+
+// sendUsage answers with the command's usage error code and its syntax
+func (gc *GameConnection) sendUsage(cmd *chatCommand) {
+	data, _ := b.EncodeChatMessage(&b.ChatMessage{
+		Type:    b.ChatMessageTypeSystem,
+		From:    "System",
+		Message: "Usage: " + cmd.Usage(),
+	})
+	gc.SendTCPMessage(b.Message{
+		Type:  types.ChatMessage,
+		Data:  data,
+		Error: string(cmd.usageError()),
+	})
+}
+
+// sendCommandList sends the commands available to the player for help and
+// tab completion
+func (gc *GameConnection) sendCommandList() {
+	gc.mu.RLock()
+	if gc.player == nil {
+		gc.mu.RUnlock()
+		return
+	}
+	player := gc.player.Copy()
+	gc.mu.RUnlock()
+
+	commands := gc.server.commands.available(player)
+	infos := make([]b.CommandInfo, 0, len(commands))
+	for _, cmd := range commands {
+		args := make([]b.CommandArg, 0, len(cmd.Args))
+		for _, arg := range cmd.Args {
+			args = append(args, b.CommandArg{Name: arg.Name, Kind: arg.Kind, Optional: arg.Optional})
+		}
+		infos = append(infos, b.CommandInfo{
+			Name:        cmd.Name,
+			Aliases:     cmd.Aliases,
+			Usage:       cmd.Usage(),
+			Description: cmd.Description,
+			Args:        args,
+		})
+	}
+
+	data, err := b.EncodeCommandList(infos)
+	if err != nil {
+		return
+	}
+	gc.SendTCPMessage(b.Message{
+		Type: types.CommandListMessage,
+		Data: data,
+	})
+}
+
+// registerDefaultCommands registers the built-in chat commands
+func (s *GameServer) registerDefaultCommands() {
+	s.commands.Register(&chatCommand{
+		Name:        "help",
+		Aliases:     []string{"?"},
+		Description: "List commands or show the usage of one",
+		Args:        []commandArg{{Name: "command", Kind: b.CommandArgWord, Optional: true}},
+		Permission:  permissions.Chat,
+		Handler:     s.commandHelp,
+	})
+	s.commands.Register(&chatCommand{
+		Name:        "whisper",
+		Aliases:     []string{"w", "msg"},
+		Description: "Send a private message",
+		Args: []commandArg{
+			{Name: "player", Kind: b.CommandArgPlayer},
+			{Name: "message", Kind: b.CommandArgText},
+		},
+		Permission: permissions.Whisper,
+		Handler:    s.commandWhisper,
+	})
+	s.commands.Register(&chatCommand{
+		Name:        "country",
+		Aliases:     []string{"c"},
+		Description: "Send a message to your country",
+		Args:        []commandArg{{Name: "message", Kind: b.CommandArgText}},
+		Permission:  permissions.CountryChat,
+		Handler:     s.commandCountryChat,
+	})
+	s.commands.Register(&chatCommand{
+		Name:        "local",
+		Aliases:     []string{"l"},
+		Description: "Send a message to players nearby",
+		Args:        []commandArg{{Name: "message", Kind: b.CommandArgText}},
+		Permission:  permissions.LocalChat,
+		Handler:     s.commandLocalChat,
+	})
+	s.commands.Register(&chatCommand{
+		Name:        "moveto",
+		Aliases:     []string{"goto"},
+		Description: "Move to a tile, finding a way around obstacles",
+		Args: []commandArg{
+			{Name: "x", Kind: b.CommandArgNumber},
+			{Name: "y", Kind: b.CommandArgNumber},
+		},
+		Permission: permissions.MoveTo,
+		Handler:    s.commandMoveTo,
+	})
+	s.commands.Register(&chatCommand{
+		Name:        "kick",
+		Description: "Disconnect a player",
+		Args: []commandArg{
+			{Name: "player", Kind: b.CommandArgPlayer},
+			{Name: "reason", Kind: b.CommandArgText, Optional: true},
+		},
+		Permission: permissions.Kick,
+		Handler:    s.commandKick,
+	})
+	s.commands.Register(&chatCommand{
+		Name:        "ban",
+		Description: "Ban a player for a duration such as 1d12h, or perm",
+		Args: []commandArg{
+			{Name: "player", Kind: b.CommandArgPlayer},
+			{Name: "duration", Kind: b.CommandArgWord},
+			{Name: "reason", Kind: b.CommandArgText, Optional: true},
+		},
+		Permission: permissions.Ban,
+		Handler:    s.commandBan,
+	})
+	s.commands.Register(&chatCommand{
+		Name:        "unban",
+		Description: "Lift a player's bans",
+		Args:        []commandArg{{Name: "player", Kind: b.CommandArgPlayer}},
+		Permission:  permissions.Ban,
+		Handler:     s.commandUnban,
+	})
+	s.commands.Register(&chatCommand{
+		Name:        "banip",
+		Description: "Ban an IP address or CIDR range",
+		Args: []commandArg{
+			{Name: "address", Kind: b.CommandArgWord},
+			{Name: "duration", Kind: b.CommandArgWord},
+			{Name: "reason", Kind: b.CommandArgText, Optional: true},
+		},
+		Permission: permissions.BanIP,
+		Handler:    s.commandBanIP,
+	})
+	s.commands.Register(&chatCommand{
+		Name:        "mute",
+		Description: "Mute a player for a duration such as 10m, or perm",
+		Args: []commandArg{
+			{Name: "player", Kind: b.CommandArgPlayer},
+			{Name: "duration", Kind: b.CommandArgWord},
+			{Name: "reason", Kind: b.CommandArgText, Optional: true},
+		},
+		Permission: permissions.Mute,
+		Handler:    s.commandMute,
+	})
+	s.commands.Register(&chatCommand{
+		Name:        "unmute",
+		Description: "Lift a player's mute",
+		Args:        []commandArg{{Name: "player", Kind: b.CommandArgPlayer}},
+		Permission:  permissions.Mute,
+		Handler:     s.commandUnmute,
+	})
+	s.commands.Register(&chatCommand{
+		Name:        "notice",
+		Description: "Broadcast a notice to every player",
+		Args:        []commandArg{{Name: "message", Kind: b.CommandArgText}},
+		Permission:  permissions.Notice,
+		Handler:     s.commandNotice,
+	})
+	s.commands.Register(&chatCommand{
+		Name:        "tp",
+		Aliases:     []string{"teleport"},
+		Description: "Teleport a player (@me for yourself)",
+		Args: []commandArg{
+			{Name: "player", Kind: b.CommandArgPlayer},
+			{Name: "x", Kind: b.CommandArgNumber},
+			{Name: "y", Kind: b.CommandArgNumber},
+		},
+		Permission: permissions.Teleport,
+		Handler:    s.commandTeleport,
+	})
+}
+
+func (s *GameServer) commandHelp(ctx *commandContext) error {
+	if name := ctx.Arg("command"); name != "" {
+		cmd := s.commands.lookup(strings.TrimPrefix(name, "/"))
+		if cmd == nil || !ctx.player.Can(cmd.Permission) {
+			return errUnknownCommand
+		}
+		return ctx.Reply("%s - %s", cmd.Usage(), cmd.Description)
+	}
+
+	for _, cmd := range s.commands.available(ctx.player) {
+		if err := ctx.Reply("%s - %s", cmd.Usage(), cmd.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *GameServer) commandWhisper(ctx *commandContext) error {
+	return ctx.gc.sendChat(ctx.player, b.ChatMessageTypeWhisper, ctx.Arg("message"), ctx.Arg("player"))
+}
+
+func (s *GameServer) commandCountryChat(ctx *commandContext) error {
+	return ctx.gc.sendChat(ctx.player, b.ChatMessageTypeCountry, ctx.Arg("message"), "")
+}
+
+func (s *GameServer) commandLocalChat(ctx *commandContext) error {
+	return ctx.gc.sendChat(ctx.player, b.ChatMessageTypeLocal, ctx.Arg("message"), "")
+}
+
+func (s *GameServer) commandKick(ctx *commandContext) error {
+	target := ctx.Player("player")
+	if err := ctx.AuthorizeTarget(target); err != nil {
+		return err
+	}
+	if err := s.Kick(target, ctx.Arg("reason")); err != nil {
+		return commandFailure(err)
+	}
+	return ctx.Reply("Player %s kicked", target)
+}
+
+func (s *GameServer) commandBan(ctx *commandContext) error {
+	duration, ok := ctx.Duration("duration")
+	if !ok {
+		return errCommandUsage
+	}
+
+	if err := ctx.AuthorizeTarget(ctx.Player("player")); err != nil {
+		return err
+	}
+	ban, err := s.BanPlayer(ctx.Player("player"), duration, ctx.Arg("reason"), ctx.player.Nickname)
+	if err != nil {
+		return commandFailure(err)
+	}
+	return ctx.Reply("Player %s banned (%s, ban %d)", ban.Nickname, banLength(duration), ban.ID)
+}
+
+func (s *GameServer) commandUnban(ctx *commandContext) error {
+	if err := s.UnbanPlayer(ctx.Player("player")); err != nil {
+		return commandFailure(err)
+	}
+	return ctx.Reply("Player %s unbanned", ctx.Player("player"))
+}
+
+func (s *GameServer) commandBanIP(ctx *commandContext) error {
+	duration, ok := ctx.Duration("duration")
+	if !ok {
+		return errCommandUsage
+	}
+
+	ban, err := s.BanIP(ctx.Arg("address"), duration, ctx.Arg("reason"), ctx.player.Nickname)
+	if errors.Is(err, ErrInvalidAddress) {
+		return errCommandUsage
+	}
+	if err != nil {
+		return commandFailure(err)
+	}
+	return ctx.Reply("Address %s banned (%s, ban %d)", ban.CIDR, banLength(duration), ban.ID)
+}
+
+func (s *GameServer) commandMute(ctx *commandContext) error {
+	duration, ok := ctx.Duration("duration")
+	if !ok {
+		return errCommandUsage
+	}
+
+	if err := ctx.AuthorizeTarget(ctx.Player("player")); err != nil {
+		return err
+	}
+	p, err := s.Mute(ctx.Player("player"), duration, ctx.Arg("reason"))
+	if err != nil {
+		return commandFailure(err)
+	}
+	if duration == 0 {
+		return ctx.Reply("Player %s muted permanently", p.Nickname)
+	}
+	return ctx.Reply("Player %s muted for %v", p.Nickname, duration)
+}
+
+func (s *GameServer) commandUnmute(ctx *commandContext) error {
+	if err := s.Unmute(ctx.Player("player")); err != nil {
+		return commandFailure(err)
+	}
+	return ctx.Reply("Player %s unmuted", ctx.Player("player"))
+}
+
+func (s *GameServer) commandNotice(ctx *commandContext) error {
+	return s.BroadcastNotice(ctx.Arg("message"))
+}
+
+func (s *GameServer) commandTeleport(ctx *commandContext) error {
+	target := ctx.Player("player")
+	if !strings.EqualFold(target, ctx.player.Nickname) && !ctx.Authorize(permissions.TeleportOthers) {
+		return nil
+	}
+
+	p, err := s.Teleport(target, ctx.Number("x"), ctx.Number("y"))
+	if errors.Is(err, ErrInvalidPosition) {
+		return errCommandUsage
+	}
+	if err != nil {
+		return errPlayerNotFound
+	}
+	return ctx.Reply("Player %s successfully teleported to %s,%s", p.Nickname, ctx.Arg("x"), ctx.Arg("y"))
+}
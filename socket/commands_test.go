@@ -0,0 +1,81 @@
+package socket
+
+import (
+	b "projectt/binary"
+	"projectt/types"
+	"strings"
+	"testing"
+)
+
+func TestCommandParseArgs(t *testing.T) {
+	cmd := &chatCommand{
+		Name: "tp",
+		Args: []commandArg{
+			{Name: "player", Kind: b.CommandArgPlayer},
+			{Name: "x", Kind: b.CommandArgNumber},
+			{Name: "y", Kind: b.CommandArgNumber, Optional: true},
+		},
+	}
+
+	if args, ok := cmd.parseArgs([]string{"bob", "1.5", "2"}); !ok || args["x"] != "1.5" || args["y"] != "2" {
+		t.Fatalf("unexpected args %v %v", args, ok)
+	}
+	if _, ok := cmd.parseArgs([]string{"bob", "1"}); !ok {
+		t.Fatal("optional argument should be allowed to be missing")
+	}
+	for _, words := range [][]string{{"bob"}, {"bob", "x"}, {"bob", "1", "2", "3"}, {"bob", "NaN"}, {"bob", "1", "Inf"}, {"bob", "-inf"}} {
+		if _, ok := cmd.parseArgs(words); ok {
+			t.Errorf("expected %v to be rejected", words)
+		}
+	}
+	if usage := cmd.Usage(); usage != "/tp <player> <x> [y]" {
+		t.Fatalf("unexpected usage %q", usage)
+	}
+
+	text := &chatCommand{Name: "notice", Args: []commandArg{{Name: "message", Kind: b.CommandArgText}}}
+	if args, ok := text.parseArgs([]string{"hello", "there"}); !ok || args["message"] != "hello there" {
+		t.Fatalf("unexpected args %v %v", args, ok)
+	}
+}
+
+func TestCommandListAndHelp(t *testing.T) {
+	server, _ := newTestServer(t)
+	alice := dialTestClient(t, server)
+	alice.login("Alice")
+
+	list := alice.expect(types.CommandListMessage)
+	if len(list.Data) == 0 || list.Data[0] != 5 {
+		t.Fatalf("expected help, whisper, moveto and chat channels for a regular player, got %v", list.Data)
+	}
+
+	alice.chat("/help")
+	lines := []string{}
+	for range 5 {
+		_, _, text := decodeTestChat(t, alice.expect(types.ChatMessage).Data)
+		lines = append(lines, text)
+	}
+	if !strings.HasPrefix(lines[0], "/country <message...>") || !strings.HasPrefix(lines[1], "/help [command]") ||
+		!strings.HasPrefix(lines[3], "/moveto <x> <y>") || !strings.HasPrefix(lines[4], "/whisper <player> <message...>") {
+		t.Fatalf("unexpected help output %q", lines)
+	}
+}
+
+func TestCommandUsageError(t *testing.T) {
+	server, _ := newTestServer(t)
+	mod := dialTestClient(t, server)
+	mod.login("Mod")
+
+	mod.chat("/teleport @me north 5")
+	msg := mod.expect(types.ChatMessage)
+	if msg.Error != "error.chat.usage_tp" {
+		t.Fatalf("unexpected error %q", msg.Error)
+	}
+	if _, _, text := decodeTestChat(t, msg.Data); text != "Usage: /tp <player> <x> <y>" {
+		t.Fatalf("unexpected usage %q", text)
+	}
+
+	mod.chat("/dance")
+	if msg := mod.expect(types.ChatMessage); msg.Error != "error.chat.unknown_command" {
+		t.Fatalf("unexpected error %q", msg.Error)
+	}
+}
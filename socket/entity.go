@@ -0,0 +1,132 @@
+package socket
+
+import (
+	b "projectt/binary"
+	"projectt/game/entity"
+	"projectt/models"
+	"projectt/types"
+	"time"
+)
+
+// playerState is the position of a player the tick copies to its entity
+type playerState struct {
+	entity     entity.ID
+	x, y       float32
+	velX, velY float32
+}
+
+// addPlayerEntity places the entity of a player that logged in. s.mu must
+// be held.
+func (s *GameServer) addPlayerEntity(p *models.Player, connID uint32) entity.ID {
+	e := s.world.Add(entity.Entity{
+		Type:    types.EntityTypePlayer,
+		X:       p.CoordX,
+		Y:       p.CoordY,
+		Country: p.CountryID,
+		Player:  &entity.Player{PlayerID: p.ID, ConnID: connID},
+	})
+	return e.ID
+}
+
+// placeEntity moves an entity to x,y and stops it. s.mu must be held.
+func (s *GameServer) placeEntity(id entity.ID, x, y float32) {
+	if e := s.world.Get(id); e != nil {
+		s.world.Move(e, x, y)
+		e.VelX, e.VelY = 0, 0
+	}
+}
+
+// stopEntity zeroes the velocity of an entity. s.mu must be held.
+func (s *GameServer) stopEntity(id entity.ID) {
+	if e := s.world.Get(id); e != nil {
+		e.VelX, e.VelY = 0, 0
+	}
+}
+
+// Entities returns copies of all entities in the world
+func (s *GameServer) Entities() []entity.Entity {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entities := make([]entity.Entity, 0, s.world.Len())
+	for _, e := range s.world.All() {
+		entities = append(entities, e.Copy())
+	}
+	return entities
+}
+
+// connectionsInRange returns the connections of the players whose entities
+// are within MaxViewDistance of x,y. s.mu must be held.
+func (s *GameServer) connectionsInRange(x, y float32) []*GameConnection {
+	connections := make([]*GameConnection, 0)
+	for _, e := range s.world.Near(x, y, float32(s.cfg.Game.MaxViewDistance())) {
+		if e.Player == nil {
+			continue
+		}
+		if gc, ok := s.connections[e.Player.ConnID]; ok {
+			connections = append(connections, gc)
+		}
+	}
+	return connections
+}
+
+// tickEntities copies the moved players to their entities, steps the world
+// and sends the entities and tiles that changed to the players in range.
+// Players are sent by the tick itself.
+func (s *GameServer) tickEntities(tick uint64, dt time.Duration, players []playerState) {
+	heartbeat := uint64(s.cfg.Game.TicksPerSecond)
+
+	s.mu.Lock()
+	for _, p := range players {
+		if e := s.world.Get(p.entity); e != nil {
+			s.world.Move(e, p.x, p.y)
+			e.VelX, e.VelY = p.velX, p.velY
+		}
+	}
+	// the world is stepped under the lock, so the tiles are read without it
+	s.world.Step(s.tileType, dt)
+	captured := s.captureTiles(dt)
+
+	added, removed := s.world.Changes()
+	fresh := make(map[entity.ID]bool, len(added))
+	for _, id := range added {
+		fresh[id] = true
+	}
+	updates := make([]b.EntityMovementData, 0)
+	for _, e := range s.world.All() {
+		if e.Player != nil {
+			continue
+		}
+		// projectiles fly straight, clients need only their start. Standing
+		// entities are sent about once a second for clients that came into
+		// range.
+		send := fresh[e.ID]
+		if e.Projectile == nil {
+			send = send || e.Moving() || tick%heartbeat == uint64(e.ID)%heartbeat
+		}
+		if send {
+			updates = append(updates, getEntityMovementData(e, tick))
+		}
+	}
+	s.mu.Unlock()
+
+	s.broadcastTileChanges(captured)
+	for i := range updates {
+		s.BroadcastInRange(b.Message{
+			Type: types.EntityMovementMessage,
+			Data: b.EncodeEntityMovementData(&updates[i]),
+		}, updates[i].PosX, updates[i].PosY, false)
+	}
+	for _, e := range removed {
+		if e.Player != nil {
+			// players leave with PlayerLeftMessage
+			continue
+		}
+		// clients drop projectiles out of range on their own, losing the
+		// removal of one does no harm
+		s.BroadcastInRange(b.Message{
+			Type: types.EntityRemovedMessage,
+			Data: b.EncodeEntityRemoved(uint32(e.ID)),
+		}, e.X, e.Y, e.Projectile == nil)
+	}
+}
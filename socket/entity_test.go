@@ -0,0 +1,56 @@
+package socket
+
+import (
+	"encoding/binary"
+	"projectt/game/ai"
+	"projectt/game/entity"
+	"projectt/game/pathfinding"
+	"projectt/types"
+	"slices"
+	"testing"
+)
+
+func TestEntities(t *testing.T) {
+	server, _ := newTestServer(t)
+	alice := dialTestClient(t, server)
+	alice.login("Alice")
+	alice.expect(types.SyncStateMessage)
+	alice.dialUDP(server)
+	if stats := server.Stats(); stats.Entities != 1 {
+		t.Fatalf("expected the entity of Alice, got %d entities", stats.Entities)
+	}
+
+	// a soldier of country 2 walks up to an unarmed one of country 1 and
+	// shoots it
+	server.mu.Lock()
+	shooter := ai.NewEntity(types.UnitTypeInfantry, 2)
+	shooter.X, shooter.Y = 26.5, 20.5
+	shooter.Weapon.Range = 3
+	shooter.Body.Route = []pathfinding.Point{{X: 28, Y: 20}}
+	shooterID := server.world.Add(shooter).ID
+	targetID := server.world.Add(entity.Entity{
+		Type:    types.EntityTypeSoldier,
+		X:       30.5,
+		Y:       20.5,
+		Country: 1,
+		Health:  &entity.Health{Current: 10, Max: 10},
+	}).ID
+	server.mu.Unlock()
+
+	msg := alice.expectUDP(types.EntityMovementMessage)
+	if id := binary.LittleEndian.Uint32(msg.Data); entity.ID(id) != shooterID && entity.ID(id) != targetID {
+		t.Fatalf("unexpected entity %d", id)
+	}
+	removed := alice.expect(types.EntityRemovedMessage)
+	if id := entity.ID(binary.LittleEndian.Uint32(removed.Data)); id != targetID {
+		t.Fatalf("expected the target to be removed, got %d", id)
+	}
+
+	// players logging in later see the shooter but not themselves
+	bob := dialTestClient(t, server)
+	bob.login("Bob")
+	_, ids := decodeTestSyncState(t, bob.expect(types.SyncStateMessage).Data)
+	if !slices.Equal(ids, []uint32{uint32(shooterID)}) {
+		t.Fatalf("unexpected entities in the sync state %v", ids)
+	}
+}
@@ -7,14 +7,49 @@ import (
 	"time"
 )
 
+const (
+	// maxViolations malformed or disallowed messages are tolerated in a
+	// burst, one more every 1/violationRate seconds. Past that the client
+	// is disconnected.
+	maxViolations = 10
+	violationRate = 0.1
+)
+
 func handleMessage(server *GameServer, gc *GameConnection, data []byte) {
+	if len(data) == 0 {
+		gc.violation("malformed")
+		return
+	}
+	msgType := types.MessageType(data[0])
+	limit := b.MaxMessageSize(msgType)
+	if limit == 0 {
+		gc.violation("unknown_type")
+		return
+	}
+	if len(data) > limit {
+		gc.violation("oversized")
+		return
+	}
+	gc.mu.RLock()
+	bucket := gc.limits[msgType]
+	gc.mu.RUnlock()
+	if bucket != nil && !bucket.Allow() {
+		server.metrics.MessagesDropped.WithLabelValues(msgType.String()).Inc()
+		if msgType == types.PlayerMovementMessage {
+			gc.inputDropped()
+		}
+		return
+	}
+
 	// Decode and handle message
 	msg, err := b.DecodeRawMessage(data)
 	if err != nil {
-		log.Printf("Error decoding message: %v - Packet length: %d - Data: %v", err, len(data), data)
+		gc.violation("malformed")
 		return
 	}
 
+	server.metrics.MessagesIn.WithLabelValues(msg.Type.String()).Inc()
+
 	// Update last heartbeat time
 	gc.mu.Lock()
 	gc.lastHeartbeat = time.Now()
@@ -24,6 +59,8 @@ func handleMessage(server *GameServer, gc *GameConnection, data []byte) {
 	switch msg.Type {
 	case types.LoginMessage:
 		gc.handleLogin(msg.Data)
+	case types.SessionMessage:
+		gc.handleResume(msg.Data)
 	case types.ChatMessage:
 		gc.handleChat(msg.Data)
 	case types.PlayerMovementMessage:
@@ -33,13 +70,30 @@ func handleMessage(server *GameServer, gc *GameConnection, data []byte) {
 	case types.ChunkRequestMessage:
 		gc.handleChunkRequest(msg.Data)
 	case types.DisconnectMessage:
+		gc.conn.Close()
 		server.mu.Lock()
 		gc.handleDisconnect()
 		server.mu.Unlock()
 		return
 	case types.PingPongMessage:
 		gc.handlePingPong(*msg)
-	default:
-		// unknown message
 	}
 }
+
+// violation records a malformed or disallowed message from the client.
+// Clients that keep sending them are disconnected. Only the first violation
+// of a connection is logged so a client sending garbage cannot flood the
+// log, the metric counts all of them. The caller must not hold the
+// connection or server mutex.
+func (gc *GameConnection) violation(reason string) {
+	gc.server.metrics.Violations.WithLabelValues(reason).Inc()
+	if gc.violationLogged.CompareAndSwap(false, true) {
+		log.Printf("Protocol violation from %s (%s), further ones are only counted", gc.conn.RemoteAddr(), reason)
+	}
+	if gc.violations.Allow() {
+		return
+	}
+
+	log.Printf("Disconnecting %s after repeated protocol violations (%s)", gc.conn.RemoteAddr(), reason)
+	gc.Disconnect(&b.DisconnectMessage{Reason: b.DisconnectReasonProtocolViolation})
+}
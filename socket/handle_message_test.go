@@ -0,0 +1,95 @@
+package socket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"math"
+	"os"
+	b "projectt/binary"
+	"projectt/types"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOversizedFrame(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := dialTestClient(t, server)
+
+	// Only the length is sent, the server must not wait for or allocate the rest
+	lenBuf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(lenBuf, 1<<30)
+	if _, err := client.conn.Write(lenBuf); err != nil {
+		t.Fatal(err)
+	}
+	if reason, _, _ := decodeTestDisconnect(t, client.expect(types.DisconnectMessage).Data); reason != b.DisconnectReasonProtocolViolation {
+		t.Fatalf("unexpected disconnect reason %d", reason)
+	}
+	if _, err := client.read(); err == nil {
+		t.Fatal("connection was not closed")
+	}
+}
+
+func TestProtocolViolations(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := dialTestClient(t, server)
+
+	// A few malformed messages are tolerated
+	client.send(b.Message{Type: types.MessageType(200)})
+	client.send(b.Message{Type: types.LoginMessage, Data: []byte{10, 'A'}})
+	client.expectError(types.LoginMessage, "error.request.invalid")
+	client.login("Alice")
+
+	nan := make([]byte, 12)
+	binary.LittleEndian.PutUint32(nan, math.Float32bits(float32(math.NaN())))
+	for range maxViolations {
+		client.send(b.Message{Type: types.PlayerMovementMessage, Data: nan})
+	}
+	if reason, _, _ := decodeTestDisconnect(t, client.expect(types.DisconnectMessage).Data); reason != b.DisconnectReasonProtocolViolation {
+		t.Fatalf("unexpected disconnect reason %d", reason)
+	}
+
+	gc := server.findConnectionByNickname("alice")
+	if gc != nil {
+		t.Fatal("abusive client is still connected")
+	}
+}
+
+// lockedBuffer collects log output written from several goroutines
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *lockedBuffer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *lockedBuffer) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestViolationsLoggedOnce(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := dialTestClient(t, server)
+
+	var logged lockedBuffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	for range maxViolations / 2 {
+		client.send(b.Message{Type: types.MessageType(200)})
+	}
+	// answered once the garbage before it was handled
+	client.chat("hello")
+	client.expectError(types.ChatMessage, "error.login.required")
+
+	if lines := strings.Count(logged.String(), "Protocol violation"); lines != 1 {
+		t.Fatalf("expected a single log line for %d violations, got %d", maxViolations/2, lines)
+	}
+}
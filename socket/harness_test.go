@@ -0,0 +1,413 @@
+package socket
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	b "projectt/binary"
+	"projectt/config"
+	"projectt/models"
+	"projectt/storage"
+	"projectt/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testTimeout = 2 * time.Second
+
+// countingStore records how often each player was saved
+type countingStore struct {
+	storage.Store
+	mu    sync.Mutex
+	saved map[uint]int
+}
+
+func (s *countingStore) savedCount(id uint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saved[id]
+}
+
+func (s *countingStore) SavePlayer(player *models.Player) error {
+	s.mu.Lock()
+	s.saved[player.ID]++
+	s.mu.Unlock()
+	return s.Store.SavePlayer(player)
+}
+
+// newTestServer starts a game server on loopback listeners with a small ground
+// map around (0,0)-(64,64), three players of country 1 and one of country 2.
+// configure functions may adjust the test configuration before it is used.
+func newTestServer(t *testing.T, configure ...func(cfg *config.Config)) (*GameServer, *countingStore) {
+	t.Helper()
+
+	store := &countingStore{Store: storage.NewMemory(), saved: make(map[uint]int)}
+	store.SaveCountry(&models.Country{ID: 1, Code: "TR"})
+	store.SaveCountry(&models.Country{ID: 2, Code: "DE"})
+	tiles := make([]models.MapTile, 0, 64*64)
+	for x := uint16(0); x < 64; x++ {
+		for y := uint16(0); y < 64; y++ {
+			tiles = append(tiles, models.MapTile{CoordX: x, CoordY: y, OwnerCountryID: 1, TileType: types.TileTypeGround})
+		}
+	}
+	store.SaveTiles(tiles)
+	store.Store.SavePlayers([]*models.Player{
+		{Model: models.Model{ID: 1}, Nickname: "Alice", CountryID: 1, CoordX: 20, CoordY: 20, Rank: types.PlayerRankCitizen},
+		{Model: models.Model{ID: 2}, Nickname: "Bob", CountryID: 1, CoordX: 22, CoordY: 20, Rank: types.PlayerRankCitizen},
+		{Model: models.Model{ID: 3}, Nickname: "Mod", CountryID: 1, CoordX: 24, CoordY: 20, Rank: types.PlayerRankCitizen, StaffRole: types.StaffRoleModerator},
+		{Model: models.Model{ID: 4}, Nickname: "Hans", CountryID: 2, CoordX: 60, CoordY: 60, Rank: types.PlayerRankCitizen},
+	})
+
+	cfg := config.Default()
+	cfg.Game.MaxPlayers = 10
+	cfg.Game.TicksPerSecond = 100
+	cfg.Chat.RateBurst = 100
+	cfg.Limits.ConnectionBurst = 100
+	for _, f := range configure {
+		f(cfg)
+	}
+	server := NewGameServer(cfg, store)
+	if err := server.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TLS.Enabled {
+		tlsConfig, err := NewTLSConfig(cfg.TLS, "localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Serve(listener, udpConn)
+	t.Cleanup(server.Close)
+
+	return server, store
+}
+
+// testClient speaks the game protocol over TCP and UDP
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	udp    *net.UDPConn
+	connID uint32
+	codec  b.DatagramCodec
+	udpSeq uint64
+}
+
+func dialTestClient(t *testing.T, server *GameServer) *testClient {
+	t.Helper()
+
+	var conn net.Conn
+	var err error
+	if server.cfg.TLS.Enabled {
+		// the test certificate is self-signed
+		conn, err = tls.Dial("tcp", server.listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	} else {
+		conn, err = net.Dial("tcp", server.listener.Addr().String())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	c := &testClient{t: t, conn: conn}
+	c.readWelcome()
+	return c
+}
+
+// pipeTestClient attaches a client to the server through an in-memory pipe
+func pipeTestClient(t *testing.T, server *GameServer) *testClient {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	go server.ServeConn(serverConn)
+	t.Cleanup(func() { clientConn.Close() })
+
+	c := &testClient{t: t, conn: clientConn}
+	c.readWelcome()
+	return c
+}
+
+func (c *testClient) readWelcome() {
+	c.t.Helper()
+
+	welcome := c.expect(types.WelcomeMessage)
+	c.connID = binary.LittleEndian.Uint32(welcome.Data)
+	key := [b.DatagramKeySize]byte(welcome.Data[10 : 10+b.DatagramKeySize])
+	if welcome.Data[10+b.DatagramKeySize] == 1 {
+		c.codec = b.NewEncryptedCodec(key)
+	} else {
+		c.codec = b.NewSignedCodec(key)
+	}
+}
+
+func (c *testClient) send(msg b.Message) {
+	c.t.Helper()
+
+	raw, err := b.EncodeRawMessage(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	frame := make([]byte, 4+len(raw))
+	binary.LittleEndian.PutUint32(frame, uint32(len(raw)))
+	copy(frame[4:], raw)
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) read() (*b.Message, error) {
+	return c.readWithin(testTimeout)
+}
+
+// readWithin reads the next TCP message, waiting at most timeout
+func (c *testClient) readWithin(timeout time.Duration) (*b.Message, error) {
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	lenBuf := make([]byte, 4)
+	if _, err := io.ReadFull(c.conn, lenBuf); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.LittleEndian.Uint32(lenBuf))
+	if _, err := io.ReadFull(c.conn, data); err != nil {
+		return nil, err
+	}
+	return b.DecodeRawMessage(data)
+}
+
+// expect reads TCP messages until one of the given type arrives
+func (c *testClient) expect(msgType types.MessageType) *b.Message {
+	c.t.Helper()
+
+	for {
+		msg, err := c.read()
+		if err != nil {
+			c.t.Fatalf("waiting for message type %d: %v", msgType, err)
+		}
+		if msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+// expectError skips messages of msgType until one carries an error code
+func (c *testClient) expectError(msgType types.MessageType, code string) {
+	c.t.Helper()
+
+	for {
+		msg := c.expect(msgType)
+		if msg.Error == "" {
+			continue
+		}
+		if msg.Error != code {
+			c.t.Fatalf("expected error %q, got %q", code, msg.Error)
+		}
+		return
+	}
+}
+
+// expectChat skips chat messages until one with the given text arrives
+func (c *testClient) expectChat(text string) b.ChatMessageType {
+	c.t.Helper()
+
+	for {
+		msg := c.expect(types.ChatMessage)
+		if len(msg.Data) == 0 {
+			continue
+		}
+		if chatType, _, got := decodeTestChat(c.t, msg.Data); got == text {
+			return chatType
+		}
+	}
+}
+
+func (c *testClient) login(nickname string) *b.Message {
+	c.t.Helper()
+
+	data := append([]byte{byte(len(nickname))}, nickname...)
+	c.send(b.Message{Type: types.LoginMessage, Data: data})
+	return c.expect(types.LoginMessage)
+}
+
+func (c *testClient) chat(text string) {
+	c.t.Helper()
+	c.chatOn(b.ChatMessageTypeGeneral, text, "")
+}
+
+// chatOn sends a chat message on a channel, with an optional whisper recipient
+func (c *testClient) chatOn(channel b.ChatMessageType, text, to string) {
+	c.t.Helper()
+
+	data := append([]byte{byte(channel), byte(len(text))}, text...)
+	if to != "" {
+		data = append(append(data, byte(len(to))), to...)
+	}
+	c.send(b.Message{Type: types.ChatMessage, Data: data})
+}
+
+// dialUDP opens the client's UDP socket and binds it to the connection with a ping
+func (c *testClient) dialUDP(server *GameServer) {
+	c.t.Helper()
+
+	udp, err := net.DialUDP("udp", nil, server.udpConn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.t.Cleanup(func() { udp.Close() })
+	c.udp = udp
+
+	c.sendUDP(b.Message{Type: types.PingPongMessage, Data: []byte("bind")})
+	c.expectUDP(types.PingPongMessage)
+}
+
+func (c *testClient) sendUDP(msg b.Message) {
+	c.t.Helper()
+
+	raw, err := b.EncodeRawMessage(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.udpSeq++
+	packet := c.codec.SealClient(c.connID, c.udpSeq, raw)
+	if _, err := c.udp.Write(packet); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) expectUDP(msgType types.MessageType) *b.Message {
+	c.t.Helper()
+
+	buffer := make([]byte, 1500)
+	c.udp.SetReadDeadline(time.Now().Add(testTimeout))
+	for {
+		n, err := c.udp.Read(buffer)
+		if err != nil {
+			c.t.Fatalf("waiting for UDP message type %d: %v", msgType, err)
+		}
+		payload, err := c.codec.OpenServer(buffer[:n])
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		msg, err := b.DecodeRawMessage(payload)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+func decodeTestChat(t *testing.T, data []byte) (b.ChatMessageType, string, string) {
+	t.Helper()
+
+	r := bytes.NewReader(data)
+	readString := func() string {
+		n, err := r.ReadByte()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := make([]byte, n)
+		if _, err := io.ReadFull(r, s); err != nil {
+			t.Fatal(err)
+		}
+		return string(s)
+	}
+	chatType, err := r.ReadByte()
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := readString()
+	return b.ChatMessageType(chatType), from, readString()
+}
+
+// decodeTestHistory returns the chat history texts of a sync state message
+func decodeTestHistory(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	history, _ := decodeTestSyncState(t, data)
+	return history
+}
+
+// decodeTestSyncState returns the chat history and the IDs of the entities
+// in a sync state
+func decodeTestSyncState(t *testing.T, data []byte) ([]string, []uint32) {
+	t.Helper()
+
+	r := bytes.NewReader(data)
+	// players and countries: count, byte length, data
+	for range 2 {
+		r.ReadByte()
+		var size uint16
+		binary.Read(r, binary.LittleEndian, &size)
+		r.Seek(int64(size), io.SeekCurrent)
+	}
+	r.ReadByte() // online count
+
+	count, err := r.ReadByte()
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := make([]string, 0, count)
+	for range count {
+		var sentAt int64
+		binary.Read(r, binary.LittleEndian, &sentAt)
+		rest := data[len(data)-r.Len():]
+		_, _, text := decodeTestChat(t, rest)
+		texts = append(texts, text)
+		// type, from, message and recipient
+		r.ReadByte()
+		for range 3 {
+			n, _ := r.ReadByte()
+			r.Seek(int64(n), io.SeekCurrent)
+		}
+	}
+
+	var entities uint16
+	if err := binary.Read(r, binary.LittleEndian, &entities); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]uint32, 0, entities)
+	for range entities {
+		var id uint32
+		binary.Read(r, binary.LittleEndian, &id)
+		ids = append(ids, id)
+		// type, unit type, country, position, velocity and health
+		r.Seek(3+4*4+2*4, io.SeekCurrent)
+	}
+	if r.Len() != 0 {
+		t.Fatalf("%d bytes left after the sync state", r.Len())
+	}
+	return texts, ids
+}
+
+func decodeTestDisconnect(t *testing.T, data []byte) (b.DisconnectReason, string, int64) {
+	t.Helper()
+
+	if len(data) < 2 || len(data) != 2+int(data[1])+8 {
+		t.Fatalf("invalid disconnect message %v", data)
+	}
+	until := int64(binary.LittleEndian.Uint64(data[2+int(data[1]):]))
+	return b.DisconnectReason(data[0]), string(data[2 : 2+int(data[1])]), until
+}
+
+func decodeTestSession(t *testing.T, data []byte) (uint32, [b.SessionTokenSize]byte) {
+	t.Helper()
+
+	var token [b.SessionTokenSize]byte
+	if len(data) != 4+b.SessionTokenSize {
+		t.Fatalf("invalid session message %v", data)
+	}
+	copy(token[:], data[4:])
+	return binary.LittleEndian.Uint32(data), token
+}
@@ -0,0 +1,48 @@
+package socket
+
+import (
+	"projectt/config"
+	"projectt/game/ratelimit"
+	"projectt/types"
+)
+
+// messageLimit is the sustained rate per second and the burst at which a
+// single connection may send one message type
+type messageLimit struct {
+	rate  float64
+	burst int
+}
+
+// messageLimits bounds how often a client may send each message type.
+// Messages beyond the limit are dropped. Types missing here are not limited;
+// chat additionally has its own configurable limit.
+func messageLimits(cfg *config.Config) map[types.MessageType]messageLimit {
+	ticks := float64(cfg.Game.TicksPerSecond)
+	return map[types.MessageType]messageLimit{
+		types.LoginMessage:   {rate: 1, burst: 3},
+		types.SessionMessage: {rate: 1, burst: 3},
+		types.ChatMessage:    {rate: 10, burst: 20},
+		// at most one input per tick
+		types.PlayerMovementMessage: {rate: ticks, burst: 2 * cfg.Game.TicksPerSecond},
+		types.PlayerDataMessage:     {rate: 20, burst: 50},
+		// a client entering the world asks for every chunk in view at once
+		types.ChunkRequestMessage: {rate: 30, burst: chunksInView(cfg.Game)},
+		types.PingPongMessage:     {rate: 5, burst: 10},
+	}
+}
+
+// chunksInView is the number of chunks a client may request around itself
+func chunksInView(g config.GameConfig) int {
+	side := 2*g.MaxChunkViewDistance + 1
+	return side * side
+}
+
+// newMessageLimiters returns full buckets for the limits of a new connection
+func newMessageLimiters(cfg *config.Config) map[types.MessageType]*ratelimit.Bucket {
+	limits := messageLimits(cfg)
+	buckets := make(map[types.MessageType]*ratelimit.Bucket, len(limits))
+	for msgType, limit := range limits {
+		buckets[msgType] = ratelimit.NewBucket(limit.rate, limit.burst)
+	}
+	return buckets
+}
@@ -0,0 +1,78 @@
+package socket
+
+import (
+	"io"
+	"net"
+	b "projectt/binary"
+	"projectt/config"
+	"projectt/types"
+	"testing"
+	"time"
+)
+
+// countUDPReplies counts the datagrams arriving on c until none arrives
+// for a short time
+func countUDPReplies(c *testClient) int {
+	buffer := make([]byte, maxDatagramSize)
+	count := 0
+	for {
+		c.udp.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		if _, err := c.udp.Read(buffer); err != nil {
+			return count
+		}
+		count++
+	}
+}
+
+func TestMessageRateLimit(t *testing.T) {
+	server, _ := newTestServer(t)
+	alice := dialTestClient(t, server)
+	alice.login("Alice")
+	alice.dialUDP(server)
+
+	// dialUDP used one ping of the burst
+	burst := messageLimits(server.cfg)[types.PingPongMessage].burst
+	for range 2 * burst {
+		alice.sendUDP(b.Message{Type: types.PingPongMessage, Data: []byte("flood")})
+	}
+	// the bucket may refill by a token while the pings are sent
+	if replies := countUDPReplies(alice); replies < burst-1 || replies > burst {
+		t.Fatalf("expected %d replies, got %d", burst-1, replies)
+	}
+}
+
+func TestUDPPacketBudget(t *testing.T) {
+	server, _ := newTestServer(t, func(cfg *config.Config) {
+		cfg.Limits.UDPPacketRate = 0.001
+		cfg.Limits.UDPPacketBurst = 4
+	})
+	alice := dialTestClient(t, server)
+	alice.login("Alice")
+	alice.dialUDP(server)
+
+	for range 6 {
+		alice.sendUDP(b.Message{Type: types.PingPongMessage, Data: []byte("flood")})
+	}
+	if replies := countUDPReplies(alice); replies != 3 {
+		t.Fatalf("expected 3 replies within the budget, got %d", replies)
+	}
+}
+
+func TestConnectionRateLimit(t *testing.T) {
+	server, _ := newTestServer(t, func(cfg *config.Config) {
+		cfg.Limits.ConnectionRate = 0.001
+		cfg.Limits.ConnectionBurst = 2
+	})
+	dialTestClient(t, server)
+	dialTestClient(t, server)
+
+	conn, err := net.Dial("tcp", server.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(testTimeout))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected the connection to be closed, got %v", err)
+	}
+}
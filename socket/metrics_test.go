@@ -0,0 +1,34 @@
+package socket
+
+import (
+	"io"
+	"net/http/httptest"
+	"projectt/types"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := dialTestClient(t, server)
+	client.login("Alice")
+	client.expect(types.SyncStateMessage)
+
+	recorder := httptest.NewRecorder()
+	server.Metrics().Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Body)
+
+	for _, want := range []string{
+		"projectt_connected_clients 1",
+		"projectt_logged_in_players 1",
+		`projectt_messages_received_total{type="login"} 1`,
+		`projectt_messages_sent_total{type="welcome"} 1`,
+		`projectt_bytes_sent_total{transport="tcp"}`,
+		"projectt_tick_duration_seconds_bucket",
+		"projectt_dirty_tiles 0",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics output is missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package socket
+
+import (
+	"errors"
+	"fmt"
+	"projectt/game/pathfinding"
+	"projectt/models"
+	"projectt/types"
+	"time"
+)
+
+const errNoPath chatError = "error.chat.no_path"
+
+// newPathfinder returns a route finder reading the tiles of s. Every lookup
+// takes the server lock, so the finder must not be used while holding it.
+func newPathfinder(s *GameServer) *pathfinding.Finder {
+	grid := func(x, y int) (types.TileType, bool) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return s.tileType(x, y)
+	}
+	return pathfinding.New(grid, pathfinding.Config{
+		Width:             s.cfg.Game.WorldWidth,
+		Height:            s.cfg.Game.WorldHeight,
+		ChunkSize:         s.cfg.Game.ChunkSize,
+		MaxNodes:          s.cfg.Pathfinding.MaxNodes,
+		HierarchyDistance: s.cfg.Pathfinding.HierarchyDistance,
+		CacheSize:         s.cfg.Pathfinding.CacheSize,
+	})
+}
+
+// tileType returns the type of the tile at x,y and whether it exists.
+// s.mu must be held.
+func (s *GameServer) tileType(x, y int) (types.TileType, bool) {
+	tile, exists := s.tiles[fmt.Sprintf("%d,%d", x, y)]
+	return tile.TileType, exists
+}
+
+// FindPath returns the waypoints a unit of the given type passes from one
+// position to another, see pathfinding.Finder.Find
+func (s *GameServer) FindPath(unit types.UnitType, fromX, fromY, toX, toY float32) ([]pathfinding.Point, error) {
+	if fromX < 0 || fromY < 0 || toX < 0 || toY < 0 {
+		return nil, pathfinding.ErrOutOfBounds
+	}
+	from := pathfinding.Point{X: int(fromX), Y: int(fromY)}
+	to := pathfinding.Point{X: int(toX), Y: int(toY)}
+	return s.pathfinder.Find(unit, from, to)
+}
+
+// MoveTo sends a player along a route to the tile at x,y. The player follows
+// it until it arrives, is blocked or moves on its own.
+func (s *GameServer) MoveTo(nickname string, x, y float32) (*models.Player, error) {
+	gc := s.findConnectionByNickname(nickname)
+	if gc == nil {
+		return nil, ErrPlayerNotFound
+	}
+
+	gc.mu.RLock()
+	p := gc.player
+	if p == nil {
+		gc.mu.RUnlock()
+		return nil, ErrPlayerNotFound
+	}
+	unit, fromX, fromY := p.GetUnitType(), p.CoordX, p.CoordY
+	gc.mu.RUnlock()
+
+	route, err := s.FindPath(unit, fromX, fromY, x, y)
+	if err != nil {
+		return nil, err
+	}
+
+	gc.mu.Lock()
+	if gc.player != p {
+		gc.mu.Unlock()
+		return nil, ErrPlayerNotFound
+	}
+	gc.route = route
+	p.LastUpdated = time.Now()
+	moving := p.Copy()
+	gc.mu.Unlock()
+
+	s.mu.Lock()
+	s.movingPlayers[p.ID] = gc
+	s.mu.Unlock()
+	return moving, nil
+}
+
+// followRoute points the player at the next waypoint of its route, passing
+// the waypoints it reaches within distance. It reports whether the player
+// is still on a route. gc.mu must be held.
+func (gc *GameConnection) followRoute(distance float32) bool {
+	p := gc.player
+	route, dirX, dirY := pathfinding.Steer(gc.route, p.CoordX, p.CoordY, distance)
+	if route == nil {
+		gc.stopRoute()
+		return false
+	}
+	gc.route = route
+	p.DirX, p.DirY = dirX, dirY
+	p.LastUpdated = time.Now()
+	return true
+}
+
+// stopRoute drops the route of the player and stops it if it was on one.
+// gc.mu must be held.
+func (gc *GameConnection) stopRoute() {
+	if gc.route == nil {
+		return
+	}
+	gc.route = nil
+	gc.player.DirX, gc.player.DirY = 0, 0
+}
+
+func (s *GameServer) commandMoveTo(ctx *commandContext) error {
+	_, err := s.MoveTo(ctx.player.Nickname, ctx.Number("x"), ctx.Number("y"))
+	if errors.Is(err, pathfinding.ErrOutOfBounds) {
+		return errCommandUsage
+	}
+	if errors.Is(err, pathfinding.ErrNoPath) {
+		return errNoPath
+	}
+	if err != nil {
+		return commandFailure(err)
+	}
+	return ctx.Reply("Moving to %s,%s", ctx.Arg("x"), ctx.Arg("y"))
+}
@@ -0,0 +1,860 @@
+package socket
+
+import (
+	"context"
+	"encoding/binary"
+	"maps"
+	"math"
+	"net"
+	b "projectt/binary"
+	"projectt/config"
+	"projectt/models"
+	"projectt/types"
+	"testing"
+	"time"
+)
+
+func TestLogin(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := dialTestClient(t, server)
+
+	msg := client.login("alice")
+	if msg.Error != "" {
+		t.Fatalf("login failed: %s", msg.Error)
+	}
+	if id := binary.LittleEndian.Uint32(msg.Data); id != 1 {
+		t.Fatalf("expected player 1, got %d", id)
+	}
+	client.expect(types.SyncStateMessage)
+}
+
+func TestLoginOverPipe(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := pipeTestClient(t, server)
+
+	if msg := client.login("Bob"); msg.Error != "" {
+		t.Fatalf("login failed: %s", msg.Error)
+	}
+}
+
+func TestLoginUnknownPlayer(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := dialTestClient(t, server)
+
+	if msg := client.login("Mallory"); msg.Error != "error.player.not_found" {
+		t.Fatalf("unexpected error %q", msg.Error)
+	}
+}
+
+func TestDuplicateLogin(t *testing.T) {
+	server, _ := newTestServer(t)
+	first := dialTestClient(t, server)
+	second := dialTestClient(t, server)
+
+	if msg := first.login("Alice"); msg.Error != "" {
+		t.Fatalf("login failed: %s", msg.Error)
+	}
+	if msg := first.login("Alice"); msg.Error != "error.player.already_connected" {
+		t.Fatalf("unexpected error %q", msg.Error)
+	}
+
+	// Logging in elsewhere replaces the first connection
+	if msg := second.login("ALICE"); msg.Error != "" {
+		t.Fatalf("second login failed: %s", msg.Error)
+	}
+	if reason, _, _ := decodeTestDisconnect(t, first.expect(types.DisconnectMessage).Data); reason != b.DisconnectReasonReplacedByLogin {
+		t.Fatalf("unexpected disconnect reason %d", reason)
+	}
+	if gc := server.findConnectionByNickname("alice"); gc == nil || gc.connID != second.connID {
+		t.Fatal("player is not bound to the new connection")
+	}
+
+	// a login that fails leaves the player where it is
+	if msg := dialTestClient(t, server).login("Nobody"); msg.Error != "error.player.not_found" {
+		t.Fatalf("unexpected error %q", msg.Error)
+	}
+	aliceID := uint(1)
+	server.mu.Lock()
+	server.bans = append(server.bans, models.Ban{PlayerID: &aliceID, Reason: "test"})
+	server.mu.Unlock()
+	if msg := dialTestClient(t, server).login("Alice"); msg.Error != "error.player.banned" {
+		t.Fatalf("unexpected error %q", msg.Error)
+	}
+	if gc := server.findConnectionByNickname("alice"); gc == nil || gc.connID != second.connID {
+		t.Fatal("a banned login replaced the online player")
+	}
+}
+
+func TestDisconnectTimeoutAndShutdown(t *testing.T) {
+	server, _ := newTestServer(t)
+	idle := dialTestClient(t, server)
+	active := dialTestClient(t, server)
+	active.login("Alice")
+	active.dialUDP(server)
+
+	// Only the idle client misses the heartbeat timeout
+	gc := server.findConnectionByNickname("alice")
+	gc.mu.Lock()
+	gc.lastHeartbeat = time.Now().Add(time.Hour)
+	gc.mu.Unlock()
+	server.disconnectInactive(time.Now().Add(2 * heartbeatTimeout))
+	if reason, _, _ := decodeTestDisconnect(t, idle.expect(types.DisconnectMessage).Data); reason != b.DisconnectReasonTimeout {
+		t.Fatalf("unexpected disconnect reason %d", reason)
+	}
+	if _, err := idle.read(); err == nil {
+		t.Fatal("connection was not closed after the timeout")
+	}
+
+	server.Close()
+	if reason, _, _ := decodeTestDisconnect(t, active.expect(types.DisconnectMessage).Data); reason != b.DisconnectReasonServerShutdown {
+		t.Fatalf("unexpected disconnect reason %d", reason)
+	}
+	gc.mu.RLock()
+	bound := gc.udpAddr != nil
+	gc.mu.RUnlock()
+	if bound {
+		t.Fatal("UDP binding was not released")
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	server, store := newTestServer(t)
+	alice := dialTestClient(t, server)
+	alice.login("Alice")
+	if _, err := server.EditTile(5, 5, func(tile *models.MapTile) { tile.OwnerCountryID = 2 }); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- server.Shutdown(ctx, 1100*time.Millisecond) }()
+
+	alice.expectChat("Server is shutting down in 2s")
+	alice.expectChat("Server is shutting down in 1s")
+	if reason, _, _ := decodeTestDisconnect(t, alice.expect(types.DisconnectMessage).Data); reason != b.DisconnectReasonServerShutdown {
+		t.Fatalf("unexpected disconnect reason %d", reason)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if store.savedCount(1) == 0 {
+		t.Fatal("player was not saved on shutdown")
+	}
+	tiles, err := store.LoadTiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tile := range tiles {
+		if tile.CoordX == 5 && tile.CoordY == 5 && tile.OwnerCountryID != 2 {
+			t.Fatal("edited tile was not saved on shutdown")
+		}
+	}
+	if conn, err := net.Dial("tcp", server.listener.Addr().String()); err == nil {
+		conn.Close()
+		t.Fatal("listener still accepts connections")
+	}
+}
+
+// Clients that stopped reading are disconnected side by side, so the final
+// save is not held up by each of them in turn
+func TestShutdownWithUnresponsiveClients(t *testing.T) {
+	server, store := newTestServer(t)
+	// writes to a pipe block until the other end reads
+	for _, nickname := range []string{"Alice", "Bob", "Mod", "Hans"} {
+		pipeTestClient(t, server).login(nickname)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*disconnectWriteTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if store.savedCount(1) == 0 {
+		t.Fatal("player was not saved on shutdown")
+	}
+}
+
+func TestChatBroadcast(t *testing.T) {
+	server, _ := newTestServer(t)
+	alice := dialTestClient(t, server)
+	bob := dialTestClient(t, server)
+	alice.login("Alice")
+	bob.login("Bob")
+
+	alice.chat("  hello world  ")
+
+	for _, c := range []*testClient{alice, bob} {
+		msg := c.expect(types.ChatMessage)
+		chatType, from, text := decodeTestChat(t, msg.Data)
+		if chatType != b.ChatMessageTypeGeneral || from != "Alice" || text != "hello world" {
+			t.Fatalf("unexpected chat message %d %q %q", chatType, from, text)
+		}
+	}
+}
+
+func TestChatChannels(t *testing.T) {
+	server, _ := newTestServer(t, func(cfg *config.Config) {
+		cfg.Game.MaxChunkViewDistance = 1
+	})
+	alice := dialTestClient(t, server)
+	bob := dialTestClient(t, server)
+	hans := dialTestClient(t, server)
+	alice.login("Alice")
+	bob.login("Bob")
+	hans.login("Hans")
+
+	// Broadcasts are written concurrently, so compare without ordering
+	readChats := func(c *testClient, n int) map[string]b.ChatMessageType {
+		t.Helper()
+		got := make(map[string]b.ChatMessageType)
+		for range n {
+			chatType, from, text := decodeTestChat(t, c.expect(types.ChatMessage).Data)
+			if from != "Alice" {
+				t.Fatalf("unexpected sender %q", from)
+			}
+			got[text] = chatType
+		}
+		return got
+	}
+
+	// Country and local chat only reach Bob; Hans is abroad and far away
+	alice.chatOn(b.ChatMessageTypeCountry, "for TR", "")
+	alice.chatOn(b.ChatMessageTypeLocal, "nearby", "")
+	alice.chat("/w bob secret")
+	want := map[string]b.ChatMessageType{
+		"for TR": b.ChatMessageTypeCountry,
+		"nearby": b.ChatMessageTypeLocal,
+		"secret": b.ChatMessageTypeWhisper,
+	}
+	for _, c := range []*testClient{alice, bob} {
+		if got := readChats(c, len(want)); !maps.Equal(got, want) {
+			t.Fatalf("unexpected chat messages %v", got)
+		}
+	}
+
+	alice.chat("everyone")
+	for _, c := range []*testClient{alice, hans} {
+		if got := readChats(c, 1); got["everyone"] != b.ChatMessageTypeGeneral || len(got) != 1 {
+			t.Fatalf("unexpected chat messages %v", got)
+		}
+	}
+
+	alice.chatOn(b.ChatMessageTypeWhisper, "hello?", "Nobody")
+	if msg := alice.expect(types.ChatMessage); msg.Error != "error.general.player_not_found" {
+		t.Fatalf("unexpected error %q", msg.Error)
+	}
+	alice.chatOn(b.ChatMessageTypeSystem, "fake", "")
+	if msg := alice.expect(types.ChatMessage); msg.Error != "error.chat.invalid_channel" {
+		t.Fatalf("unexpected error %q", msg.Error)
+	}
+}
+
+func TestChatModeration(t *testing.T) {
+	server, store := newTestServer(t, func(cfg *config.Config) {
+		cfg.Chat.FilteredWords = []string{"darn"}
+	})
+	alice := dialTestClient(t, server)
+	bob := dialTestClient(t, server)
+	mod := dialTestClient(t, server)
+	alice.login("Alice")
+	bob.login("Bob")
+	mod.login("Mod")
+
+	alice.chat("darn it")
+	bob.expectChat("**** it")
+
+	alice.chat("buy gold")
+	alice.chat("buy gold")
+	alice.chat("buy gold")
+	alice.expectError(types.ChatMessage, "error.chat.spam")
+
+	mod.chat("/mute alice 10m spamming")
+	mod.expectChat("Player Alice muted for 10m0s")
+	alice.expectChat("You have been muted for 10m0s: spamming")
+	if p, _ := store.FindPlayerByNickname("Alice"); !p.IsMuted(time.Now()) {
+		t.Fatal("mute was not saved")
+	}
+
+	alice.chat("hello")
+	alice.expectError(types.ChatMessage, "error.chat.muted")
+	alice.chat("/w bob hello")
+	alice.expectError(types.ChatMessage, "error.chat.muted")
+
+	alice.chat("/mute bob perm")
+	alice.expectError(types.ChatMessage, "error.chat.permission_denied")
+
+	mod.chat("/unmute alice")
+	mod.expectChat("Player alice unmuted")
+	alice.chat("hello again")
+	bob.expectChat("hello again")
+}
+
+func TestChatHistoryAndMail(t *testing.T) {
+	server, store := newTestServer(t)
+	alice := dialTestClient(t, server)
+	alice.login("Alice")
+
+	alice.chat("hi all")
+	alice.chatOn(b.ChatMessageTypeCountry, "for TR", "")
+	alice.chat("/w bob see you")
+	alice.expectChat("Bob is offline and will receive your message on their next login")
+
+	bob := dialTestClient(t, server)
+	bob.login("Bob")
+	history := decodeTestHistory(t, bob.expect(types.SyncStateMessage).Data)
+	if len(history) != 2 || history[0] != "hi all" || history[1] != "for TR" {
+		t.Fatalf("unexpected history for Bob %q", history)
+	}
+	if chatType := bob.expectChat("see you"); chatType != b.ChatMessageTypeWhisper {
+		t.Fatalf("mail delivered as chat type %d", chatType)
+	}
+
+	hans := dialTestClient(t, server)
+	hans.login("Hans")
+	history = decodeTestHistory(t, hans.expect(types.SyncStateMessage).Data)
+	if len(history) != 1 || history[0] != "hi all" {
+		t.Fatalf("unexpected history for Hans %q", history)
+	}
+
+	// players without a country read no country's chat
+	if err := store.SavePlayer(&models.Player{Nickname: "Nomad", CoordX: 30, CoordY: 30, Rank: types.PlayerRankCitizen}); err != nil {
+		t.Fatal(err)
+	}
+	nomad := dialTestClient(t, server)
+	nomad.login("Nomad")
+	history = decodeTestHistory(t, nomad.expect(types.SyncStateMessage).Data)
+	if len(history) != 1 || history[0] != "hi all" {
+		t.Fatalf("unexpected history for a player without a country %q", history)
+	}
+}
+
+func TestMailKeptWhenDeliveryFails(t *testing.T) {
+	server, store := newTestServer(t, func(cfg *config.Config) { cfg.Game.ResumeGracePeriod = 0 })
+	alice := dialTestClient(t, server)
+	alice.login("Alice")
+	alice.chat("/w bob see you")
+	alice.expectChat("Bob is offline and will receive your message on their next login")
+
+	// the connection drops before the mail is sent, writes to a closed pipe
+	// fail
+	bob := pipeTestClient(t, server)
+	bob.login("Bob")
+	bob.expect(types.CommandListMessage)
+	bob.conn.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for server.findConnectionByNickname("Bob") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Bob is still online")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if count, _ := store.CountMail(2); count != 1 {
+		t.Fatalf("undelivered mail was lost, %d letters left", count)
+	}
+
+	bob = dialTestClient(t, server)
+	bob.login("Bob")
+	bob.expectChat("see you")
+	for count, _ := store.CountMail(2); count != 0; count, _ = store.CountMail(2) {
+		if time.Now().After(deadline) {
+			t.Fatalf("delivered mail was kept, %d letters left", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestChatRateLimit(t *testing.T) {
+	server, _ := newTestServer(t, func(cfg *config.Config) {
+		cfg.Chat.RateLimit = 0.01
+		cfg.Chat.RateBurst = 2
+	})
+	alice := dialTestClient(t, server)
+	alice.login("Alice")
+
+	alice.chat("one")
+	alice.chat("two")
+	alice.chat("three")
+	alice.expectError(types.ChatMessage, "error.chat.rate_limited")
+}
+
+func TestChatRequiresLogin(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := dialTestClient(t, server)
+
+	client.chat("hello")
+	if msg := client.expect(types.ChatMessage); msg.Error != "error.login.required" {
+		t.Fatalf("unexpected error %q", msg.Error)
+	}
+}
+
+func TestMovement(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := dialTestClient(t, server)
+	client.login("Alice")
+	client.dialUDP(server)
+
+	data := make([]byte, 12)
+	binary.LittleEndian.PutUint32(data[0:], math.Float32bits(1))
+	binary.LittleEndian.PutUint32(data[4:], math.Float32bits(0))
+	binary.LittleEndian.PutUint32(data[8:], math.Float32bits(1))
+	client.sendUDP(b.Message{Type: types.PlayerMovementMessage, Data: data})
+
+	msg := client.expectUDP(types.PlayerMovementMessage)
+	if id := binary.LittleEndian.Uint32(msg.Data); id != 1 {
+		t.Fatalf("expected movement of player 1, got %d", id)
+	}
+	posX := math.Float32frombits(binary.LittleEndian.Uint32(msg.Data[4:]))
+	if posX <= 20 {
+		t.Fatalf("expected player to move right, x = %f", posX)
+	}
+
+	// every update carries the tick it was taken on
+	first := binary.LittleEndian.Uint32(msg.Data[29:])
+	next := binary.LittleEndian.Uint32(client.expectUDP(types.PlayerMovementMessage).Data[29:])
+	if first == 0 || next <= first {
+		t.Fatalf("expected increasing ticks, got %d then %d", first, next)
+	}
+}
+
+func TestMovementCollision(t *testing.T) {
+	server, _ := newTestServer(t)
+	// a river east of Alice
+	for y := uint16(0); y < 64; y++ {
+		if _, err := server.EditTile(21, y, func(tile *models.MapTile) { tile.TileType = types.TileTypeWater }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := dialTestClient(t, server)
+	client.login("Alice")
+	client.dialUDP(server)
+
+	// walking diagonally into the river slides along its bank
+	data := make([]byte, 12)
+	binary.LittleEndian.PutUint32(data[0:], math.Float32bits(1))
+	binary.LittleEndian.PutUint32(data[4:], math.Float32bits(1))
+	binary.LittleEndian.PutUint32(data[8:], math.Float32bits(1))
+	client.sendUDP(b.Message{Type: types.PlayerMovementMessage, Data: data})
+	time.Sleep(300 * time.Millisecond)
+
+	gc := server.findConnectionByNickname("Alice")
+	gc.mu.RLock()
+	x, y := gc.player.CoordX, gc.player.CoordY
+	gc.mu.RUnlock()
+	if x >= 21 || x < 20.9 {
+		t.Fatalf("expected Alice to stop at the river bank, x = %f", x)
+	}
+	if y <= 21 {
+		t.Fatalf("expected Alice to slide along the bank, y = %f", y)
+	}
+}
+
+// Tiles edited while players move must not race with the tick
+func TestEditTileWhileMoving(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := dialTestClient(t, server)
+	client.login("Alice")
+	client.dialUDP(server)
+	client.move(0, 1, 1)
+
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for i := 0; time.Now().Before(deadline); i++ {
+		tileType := types.TileTypeGround
+		if i%2 == 0 {
+			tileType = types.TileTypeWater
+		}
+		if _, err := server.EditTile(uint16(i%64), 40, func(tile *models.MapTile) { tile.TileType = tileType }); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMoveTo(t *testing.T) {
+	server, _ := newTestServer(t)
+	// a lake between Alice and her goal, open to the north
+	for x := uint16(25); x < 28; x++ {
+		for y := uint16(5); y < 64; y++ {
+			if _, err := server.EditTile(x, y, func(tile *models.MapTile) { tile.TileType = types.TileTypeWater }); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	client := dialTestClient(t, server)
+	client.login("Alice")
+
+	client.chat("/moveto 26 20")
+	if msg := client.expect(types.ChatMessage); msg.Error != "error.chat.no_path" {
+		t.Fatalf("expected no path into the lake, got %q", msg.Error)
+	}
+	client.chat("/moveto 30 20")
+	client.expectChat("Moving to 30,20")
+
+	gc := server.findConnectionByNickname("Alice")
+	position := func() (float32, float32, bool) {
+		gc.mu.RLock()
+		defer gc.mu.RUnlock()
+		return gc.player.CoordX, gc.player.CoordY, gc.route != nil
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		x, y, onRoute := position()
+		if !onRoute {
+			if int(x) != 30 || int(y) != 20 {
+				t.Fatalf("route ended at %f,%f", x, y)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("still on the way at %f,%f", x, y)
+		}
+		if tile, _ := server.Tile(uint16(x), uint16(y)); tile.TileType == types.TileTypeWater {
+			t.Fatalf("walked into the lake at %f,%f", x, y)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// moving by hand cancels a route
+	client.chat("/moveto 20 20")
+	client.expectChat("Moving to 20,20")
+	client.move(1, 0, 1)
+	time.Sleep(50 * time.Millisecond)
+	if _, _, onRoute := position(); onRoute {
+		t.Fatal("route survived a movement input")
+	}
+}
+
+func TestChunkRequest(t *testing.T) {
+	server, _ := newTestServer(t)
+	client := dialTestClient(t, server)
+	client.login("Alice")
+
+	client.send(b.Message{Type: types.ChunkRequestMessage, Data: []byte{1, 0, 1, 0}})
+	msg := client.expect(types.ChunkDataMessage)
+	if len(msg.Data) != 4+256*6 {
+		t.Fatalf("unexpected chunk packet length %d", len(msg.Data))
+	}
+	if chunkX := binary.LittleEndian.Uint16(msg.Data); chunkX != 1 {
+		t.Fatalf("expected chunk 1, got %d", chunkX)
+	}
+	// first tile is owned ground
+	if countryID, tileType := msg.Data[4], msg.Data[6]; countryID != 1 || tileType != uint8(types.TileTypeGround) {
+		t.Fatalf("unexpected tile country %d type %d", countryID, tileType)
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	server, store := newTestServer(t, func(cfg *config.Config) { cfg.Game.ResumeGracePeriod = 0 })
+	alice := dialTestClient(t, server)
+	bob := dialTestClient(t, server)
+	alice.login("Alice")
+	bob.login("Bob")
+
+	bob.conn.Close()
+
+	msg := alice.expect(types.PlayerLeftMessage)
+	if id := binary.LittleEndian.Uint32(msg.Data); id != 2 {
+		t.Fatalf("expected player 2 to leave, got %d", id)
+	}
+
+	deadline := time.Now().Add(testTimeout)
+	for store.savedCount(2) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("player was not saved on disconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	server.mu.RLock()
+	connections := len(server.connections)
+	server.mu.RUnlock()
+	if connections != 1 {
+		t.Fatalf("expected 1 connection, got %d", connections)
+	}
+}
+
+func TestSessionResume(t *testing.T) {
+	server, store := newTestServer(t)
+	alice := dialTestClient(t, server)
+	bob := dialTestClient(t, server)
+	alice.login("Alice")
+	connID, token := decodeTestSession(t, alice.expect(types.SessionMessage).Data)
+	if connID != alice.connID {
+		t.Fatalf("expected connection ID %d, got %d", alice.connID, connID)
+	}
+	alice.dialUDP(server)
+	bob.login("Bob")
+
+	// The dropped player stays in the world
+	alice.conn.Close()
+	gc := server.findConnectionByNickname("alice")
+	deadline := time.Now().Add(testTimeout)
+	for {
+		gc.mu.RLock()
+		suspended := !gc.suspendedAt.IsZero()
+		gc.mu.RUnlock()
+		if suspended {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("session was not suspended")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// whispers to the suspended player wait for the session to resume
+	bob.chatOn(b.ChatMessageTypeWhisper, "are you there", "alice")
+	bob.expectChat("Alice is offline and will receive your message on their next login")
+
+	resumed := dialTestClient(t, server)
+	resumed.send(b.Message{Type: types.SessionMessage, Data: token[:]})
+	newConnID, newToken := decodeTestSession(t, resumed.expect(types.SessionMessage).Data)
+	if newConnID != connID || newToken == token {
+		t.Fatalf("expected connection ID %d with a new token, got %d", connID, newConnID)
+	}
+	resumed.expect(types.SyncStateMessage)
+	if chatType := resumed.expectChat("are you there"); chatType != b.ChatMessageTypeWhisper {
+		t.Fatalf("whisper delivered as chat type %d", chatType)
+	}
+	if store.savedCount(1) != 0 {
+		t.Fatal("player was saved and removed instead of suspended")
+	}
+
+	// The UDP binding carries over to the new connection
+	resumed.udp, resumed.connID = alice.udp, connID
+	resumed.sendUDP(b.Message{Type: types.PingPongMessage, Data: []byte("resumed")})
+	resumed.expectUDP(types.PingPongMessage)
+
+	// Tokens are single use
+	replay := dialTestClient(t, server)
+	replay.send(b.Message{Type: types.SessionMessage, Data: token[:]})
+	replay.expectError(types.SessionMessage, errSessionInvalid)
+
+	// A live session is taken over, closing the old connection
+	takeover := dialTestClient(t, server)
+	takeover.send(b.Message{Type: types.SessionMessage, Data: newToken[:]})
+	takeover.expect(types.SessionMessage)
+	if reason, _, _ := decodeTestDisconnect(t, resumed.expect(types.DisconnectMessage).Data); reason != b.DisconnectReasonReplacedByLogin {
+		t.Fatalf("unexpected disconnect reason %d", reason)
+	}
+
+	// Nobody saw the player leave or join again
+	bob.chat("ping")
+	for {
+		msg := bob.expect(types.ChatMessage)
+		if _, _, text := decodeTestChat(t, msg.Data); text == "ping" {
+			break
+		}
+	}
+	for {
+		msg, err := bob.readWithin(50 * time.Millisecond)
+		if err != nil {
+			break
+		}
+		if msg.Type == types.PlayerLeftMessage || msg.Type == types.PlayerJoinedMessage {
+			t.Fatalf("unexpected message type %d", msg.Type)
+		}
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	server, store := newTestServer(t)
+	alice := dialTestClient(t, server)
+	bob := dialTestClient(t, server)
+	alice.login("Alice")
+	_, token := decodeTestSession(t, alice.expect(types.SessionMessage).Data)
+	bob.login("Bob")
+
+	alice.conn.Close()
+	gc := server.findConnectionByNickname("alice")
+	deadline := time.Now().Add(testTimeout)
+	for {
+		gc.mu.RLock()
+		suspended := !gc.suspendedAt.IsZero()
+		gc.mu.RUnlock()
+		if suspended {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("session was not suspended")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	server.expireSessions(time.Now().Add(2 * server.cfg.Game.ResumeGracePeriod))
+	if id := binary.LittleEndian.Uint32(bob.expect(types.PlayerLeftMessage).Data); id != 1 {
+		t.Fatalf("expected player 1 to leave, got %d", id)
+	}
+	if store.savedCount(1) == 0 {
+		t.Fatal("player was not saved when the session expired")
+	}
+
+	late := dialTestClient(t, server)
+	late.send(b.Message{Type: types.SessionMessage, Data: token[:]})
+	late.expectError(types.SessionMessage, errSessionInvalid)
+}
+
+func TestKickAndBan(t *testing.T) {
+	server, store := newTestServer(t)
+	alice := dialTestClient(t, server)
+	bob := dialTestClient(t, server)
+	mod := dialTestClient(t, server)
+	alice.login("Alice")
+	bob.login("Bob")
+	mod.login("Mod")
+
+	mod.chat("/ban bob 1h spamming")
+	if reason, text, until := decodeTestDisconnect(t, bob.expect(types.DisconnectMessage).Data); reason != b.DisconnectReasonBanned || text != "spamming" || until == 0 {
+		t.Fatalf("unexpected disconnect %d %q %d", reason, text, until)
+	}
+	msg := alice.expect(types.PlayerLeftMessage)
+	if id := binary.LittleEndian.Uint32(msg.Data); id != 2 {
+		t.Fatalf("expected player 2 to leave, got %d", id)
+	}
+	if bans, _ := store.LoadBans(); len(bans) != 1 || *bans[0].PlayerID != 2 || bans[0].BannedBy != "Mod" {
+		t.Fatalf("ban was not saved: %+v", bans)
+	}
+
+	again := dialTestClient(t, server)
+	if msg := again.login("Bob"); msg.Error != "error.player.banned" {
+		t.Fatalf("unexpected error %q", msg.Error)
+	}
+
+	mod.chat("/unban bob")
+	mod.expectChat("Player bob unbanned")
+	if msg := again.login("Bob"); msg.Error != "" {
+		t.Fatalf("login after unban failed: %s", msg.Error)
+	}
+
+	mod.chat("/kick alice afk")
+	if reason, text, _ := decodeTestDisconnect(t, alice.expect(types.DisconnectMessage).Data); reason != b.DisconnectReasonKicked || text != "afk" {
+		t.Fatalf("unexpected disconnect %d %q", reason, text)
+	}
+}
+
+// A client that stopped reading must not block whoever disconnects it
+func TestKickUnresponsiveClient(t *testing.T) {
+	server, _ := newTestServer(t)
+	// writes to a pipe block until the other end reads
+	client := pipeTestClient(t, server)
+	client.login("Bob")
+
+	kicked := make(chan error, 1)
+	go func() { kicked <- server.Kick("Bob", "afk") }()
+	select {
+	case err := <-kicked:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * disconnectWriteTimeout):
+		t.Fatal("kick blocked on a client that does not read")
+	}
+	if server.findConnectionByNickname("Bob") != nil {
+		t.Fatal("kicked player is still connected")
+	}
+}
+
+func TestIPBan(t *testing.T) {
+	server, _ := newTestServer(t)
+	alice := dialTestClient(t, server)
+	alice.login("Alice")
+
+	if _, err := server.BanIP("not an address", 0, "", "test"); err != ErrInvalidAddress {
+		t.Fatalf("expected ErrInvalidAddress, got %v", err)
+	}
+	ban, err := server.BanIP("127.0.0.0/8", time.Hour, "proxy", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason, _, _ := decodeTestDisconnect(t, alice.expect(types.DisconnectMessage).Data); reason != b.DisconnectReasonBanned {
+		t.Fatalf("unexpected disconnect reason %d", reason)
+	}
+
+	// New connections from the range are refused before the welcome message
+	conn, err := net.Dial("tcp", server.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	refused := &testClient{t: t, conn: conn}
+	if msg, err := refused.read(); err != nil || msg.Type != types.DisconnectMessage {
+		t.Fatalf("expected a disconnect message, got %v, %v", msg, err)
+	}
+
+	if err := server.Unban(ban.ID); err != nil {
+		t.Fatal(err)
+	}
+	dialTestClient(t, server).login("Alice")
+}
+
+func TestCommandPermissions(t *testing.T) {
+	server, _ := newTestServer(t)
+	alice := dialTestClient(t, server)
+	mod := dialTestClient(t, server)
+	alice.login("Alice")
+	mod.login("Mod")
+
+	alice.chat("/notice hello everyone")
+	if msg := alice.expect(types.ChatMessage); msg.Error != "error.chat.permission_denied" {
+		t.Fatalf("unexpected error %q", msg.Error)
+	}
+
+	// moderators may teleport themselves but not others
+	mod.chat("/tp alice 1 1")
+	if msg := mod.expect(types.ChatMessage); msg.Error != "error.chat.permission_denied" {
+		t.Fatalf("unexpected error %q", msg.Error)
+	}
+	mod.chat("/tp @me 30 30")
+	if msg := mod.expect(types.ChatMessage); msg.Error != "" {
+		t.Fatalf("unexpected error %q", msg.Error)
+	}
+	mod.chat("/tp @me 30 -1")
+	if msg := mod.expect(types.ChatMessage); msg.Error != "error.chat.usage_tp" {
+		t.Fatalf("teleport outside the world: unexpected error %q", msg.Error)
+	}
+
+	mod.chat("/notice server restart")
+	msg := alice.expect(types.ChatMessage)
+	if chatType, _, text := decodeTestChat(t, msg.Data); chatType != b.ChatMessageTypeNotice || text != "server restart" {
+		t.Fatalf("unexpected notice %d %q", chatType, text)
+	}
+
+	// promoted players gain the permission immediately
+	if err := server.SetStaffRole("Alice", types.StaffRoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	alice.chat("/tp mod 1 1")
+	if msg := alice.expect(types.ChatMessage); msg.Error != "" {
+		t.Fatalf("unexpected error %q", msg.Error)
+	}
+}
+
+func TestModerationNeedsHigherRole(t *testing.T) {
+	server, store := newTestServer(t)
+	if err := server.SetStaffRole("Alice", types.StaffRoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	alice := dialTestClient(t, server)
+	mod := dialTestClient(t, server)
+	alice.login("Alice")
+	mod.login("Mod")
+
+	// a moderator may not act on an admin, nor on another moderator
+	for _, line := range []string{"/ban alice perm", "/mute alice 10m", "/kick alice", "/kick @me"} {
+		mod.chat(line)
+		if msg := mod.expect(types.ChatMessage); msg.Error != "error.chat.permission_denied" {
+			t.Fatalf("%s: unexpected error %q", line, msg.Error)
+		}
+	}
+	if bans := server.Bans(); len(bans) != 0 {
+		t.Fatalf("admin was banned: %+v", bans)
+	}
+	if p, _ := store.FindPlayerByNickname("Alice"); p.Muted {
+		t.Fatal("admin was muted")
+	}
+
+	// the admin outranks the moderator
+	alice.chat("/mute mod 10m")
+	alice.expectChat("Player Mod muted for 10m0s")
+}
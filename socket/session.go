@@ -0,0 +1,186 @@
+package socket
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"log"
+	b "projectt/binary"
+	"projectt/types"
+	"time"
+)
+
+// errSessionSuspended is returned when sending to a player whose connection
+// dropped and who has not resumed the session yet
+var errSessionSuspended = errors.New("session suspended")
+
+const errSessionInvalid = "error.session.invalid"
+
+// issueSession gives the player a new resume token and sends it together
+// with the connection ID the client has to use for UDP
+func (gc *GameConnection) issueSession() {
+	var token [b.SessionTokenSize]byte
+	rand.Read(token[:])
+
+	gc.mu.Lock()
+	gc.resumeToken = token
+	connID := gc.connID
+	gc.mu.Unlock()
+
+	gc.SendTCPMessage(b.Message{
+		Type: types.SessionMessage,
+		Data: b.EncodeSessionMessage(&b.SessionMessage{ConnectionID: connID, Token: token}),
+	})
+}
+
+// suspend keeps the player of a dropped connection in the world for the
+// resume grace period. It reports false if the connection has to be removed
+// right away instead. The caller must hold the server mutex.
+func (gc *GameConnection) suspend(now time.Time) bool {
+	if gc.server.cfg.Game.ResumeGracePeriod <= 0 {
+		return false
+	}
+
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if gc.disconnected || gc.player == nil {
+		return false
+	}
+	gc.suspendedAt = now
+
+	// The player waits in place instead of walking on without input
+	gc.player.DirX, gc.player.DirY = 0, 0
+	delete(gc.server.movingPlayers, gc.player.ID)
+	gc.server.stopEntity(gc.entity)
+
+	log.Printf("Suspended session of %s for %v", gc.player.Nickname, gc.server.cfg.Game.ResumeGracePeriod)
+	return true
+}
+
+// handleResume moves the session matching the token onto this connection.
+// The player keeps its connection ID and UDP binding and stays in the world,
+// so nearby players see neither a leave nor a join. A session whose old
+// connection is still open is taken over and the old connection is closed.
+func (gc *GameConnection) handleResume(data []byte) {
+	token, err := b.DecodeResumeRequest(data)
+	if err != nil {
+		gc.violation("malformed")
+		gc.SendTCPMessage(b.Message{
+			Type:  types.SessionMessage,
+			Error: "error.request.invalid",
+		})
+		return
+	}
+
+	gc.mu.RLock()
+	loggedIn := gc.player != nil
+	gc.mu.RUnlock()
+	if loggedIn {
+		gc.SendTCPMessage(b.Message{
+			Type:  types.SessionMessage,
+			Error: "error.player.already_connected",
+		})
+		return
+	}
+
+	s := gc.server
+	s.mu.Lock()
+	old := s.findSession(token)
+	if old == nil {
+		s.mu.Unlock()
+		gc.SendTCPMessage(b.Message{
+			Type:  types.SessionMessage,
+			Error: errSessionInvalid,
+		})
+		return
+	}
+	live := gc.takeOver(old)
+	s.mu.Unlock()
+
+	if live {
+		old.sendDisconnect(&b.DisconnectMessage{Reason: b.DisconnectReasonReplacedByLogin})
+	}
+	old.conn.Close()
+
+	gc.mu.RLock()
+	player := gc.player
+	log.Printf("Resumed session of %s from %s", player.Nickname, gc.conn.RemoteAddr())
+	gc.mu.RUnlock()
+
+	gc.issueSession()
+	gc.sendSyncState()
+	// whispers sent while the session was suspended
+	gc.deliverMail(player)
+}
+
+// findSession returns the logged in connection holding token. The caller
+// must hold the server mutex.
+func (s *GameServer) findSession(token [b.SessionTokenSize]byte) *GameConnection {
+	for _, gc := range s.connections {
+		gc.mu.RLock()
+		match := gc.player != nil && !gc.disconnected &&
+			subtle.ConstantTimeCompare(gc.resumeToken[:], token[:]) == 1
+		gc.mu.RUnlock()
+		if match {
+			return gc
+		}
+	}
+	return nil
+}
+
+// takeOver moves the player, connection ID, UDP binding, rate limits and
+// chat state of old onto gc and retires old without saving or announcing a leave. gc keeps
+// the UDP key from its own welcome message. It reports whether old was still
+// connected. The caller must hold the server mutex.
+func (gc *GameConnection) takeOver(old *GameConnection) bool {
+	old.mu.Lock()
+	live := old.suspendedAt.IsZero()
+	old.disconnected = true
+	connID := old.connID
+	player := old.player
+	udpAddr, udpConn := old.udpAddr, old.udpConn
+	chatLimiter, chatRepeats, limits := old.chatLimiter, old.chatRepeats, old.limits
+	entityID := old.entity
+	old.udpAddr = nil
+	old.udpConn = nil
+	old.mu.Unlock()
+
+	delete(gc.server.connections, gc.connID)
+	if player != nil {
+		// the player waits for the first input on the new connection
+		delete(gc.server.movingPlayers, player.ID)
+		gc.server.stopEntity(entityID)
+	}
+
+	gc.mu.Lock()
+	gc.connID = connID
+	gc.player = player
+	gc.entity = entityID
+	gc.udpAddr, gc.udpConn = udpAddr, udpConn
+	gc.chatLimiter, gc.chatRepeats, gc.limits = chatLimiter, chatRepeats, limits
+	gc.lastHeartbeat = time.Now()
+	gc.mu.Unlock()
+
+	gc.server.connections[connID] = gc
+	if player != nil {
+		gc.server.anticheat.Reset(player.ID)
+	}
+	return live
+}
+
+// expireSessions removes the players of connections that were suspended for
+// longer than the resume grace period before now
+func (s *GameServer) expireSessions(now time.Time) {
+	grace := s.cfg.Game.ResumeGracePeriod
+	for _, gc := range s.connectionsSnapshot() {
+		gc.mu.RLock()
+		expired := !gc.suspendedAt.IsZero() && now.Sub(gc.suspendedAt) > grace
+		gc.mu.RUnlock()
+
+		if expired {
+			s.mu.Lock()
+			gc.handleDisconnect()
+			s.mu.Unlock()
+		}
+	}
+}
@@ -1,33 +1,48 @@
 package socket
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
 	"math"
-	"math/rand/v2"
+	mathrand "math/rand/v2"
 	"net"
-	"os"
 	b "projectt/binary"
 	"projectt/config"
+	"projectt/game/ai"
+	"projectt/game/anticheat"
+	"projectt/game/entity"
+	"projectt/game/moderation"
+	"projectt/game/movement"
+	"projectt/game/pathfinding"
+	"projectt/game/ratelimit"
+	gametick "projectt/game/tick"
+	"projectt/metrics"
 	"projectt/models"
+	"projectt/storage"
 	"projectt/types"
-	"strconv"
-	"strings"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var (
-	server *GameServer
-)
-
 type GameConnection struct {
 	// TCP client connection
 	conn net.Conn
 	// UDP client connection
 	udpAddr *net.UDPAddr
 	udpConn *net.UDPConn
+	// Datagrams are protected with udpKey, sent in the welcome message
+	udpKey     [b.DatagramKeySize]byte
+	udpCodec   b.DatagramCodec
+	udpReplay  replayWindow
+	udpSendSeq atomic.Uint64
 	// Unique connection ID
 	connID uint32
 
@@ -36,6 +51,26 @@ type GameConnection struct {
 	mu     sync.RWMutex // Mutex for thread-safe access
 
 	lastHeartbeat time.Time
+	disconnected  bool // set once handleDisconnect has run
+
+	// Session resume, see session.go
+	resumeToken [b.SessionTokenSize]byte
+	suspendedAt time.Time // when the transport dropped, zero while connected
+
+	// Chat moderation state, only used by the connection's read loop
+	chatLimiter *ratelimit.Bucket
+	chatRepeats *moderation.RepeatGuard
+
+	// Protocol violations the client may still commit, see violation
+	violations      *ratelimit.Bucket
+	violationLogged atomic.Bool
+	// Per message type rate limits, see messageLimits
+	limits map[types.MessageType]*ratelimit.Bucket
+
+	// Waypoints the player is moving along, see MoveTo
+	route []pathfinding.Point
+	// Entity of the player while logged in
+	entity entity.ID
 }
 
 type GameServer struct {
@@ -43,17 +78,146 @@ type GameServer struct {
 	countries     map[uint8]models.Country
 	tiles         map[string]models.MapTile
 	updatedTiles  map[string]models.MapTile // Track tiles that need saving
-	movingPlayers map[uint]*models.Player
+	movingPlayers map[uint]*GameConnection  // by player ID
+	world         *entity.World             // players, units and projectiles
 	mu            sync.RWMutex
+
+	cfg     *config.Config
+	store   storage.Store
+	loop    *gametick.Loop
+	metrics *metrics.Metrics
+
+	commands   *commandRegistry
+	chatFilter *moderation.Filter
+	bans       []models.Ban // loaded by Load, including expired ones
+	startedAt  time.Time
+
+	// Flood protection, see LimitsConfig
+	udpBudget   *ratelimit.Bucket
+	connLimiter *ratelimit.Keyed
+	// Movement validation, nil when disabled
+	anticheat *anticheat.Validator
+	// Routes across the map, see FindPath
+	pathfinder *pathfinding.Finder
+	// Commanders of the AI countries, nil when disabled
+	brain *ai.Brain
+	// Tiles occupied or freed by units
+	captures atomic.Uint64
+
+	// Listeners, set by Serve
+	listener net.Listener
+	udpConn  *net.UDPConn
+
+	// ctx is cancelled by Close to stop the background routines
+	ctx       context.Context
+	cancel    context.CancelFunc
+	routines  sync.WaitGroup // background routines started by Serve
+	clients   sync.WaitGroup // connection handlers
+	closeOnce sync.Once
 }
 
-func NewGameServer() *GameServer {
-	return &GameServer{
+func NewGameServer(cfg *config.Config, store storage.Store) *GameServer {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &GameServer{
 		connections:   make(map[uint32]*GameConnection),
 		countries:     make(map[uint8]models.Country),
 		tiles:         make(map[string]models.MapTile),
 		updatedTiles:  make(map[string]models.MapTile),
-		movingPlayers: make(map[uint]*models.Player),
+		movingPlayers: make(map[uint]*GameConnection),
+		world:         entity.NewWorld(cfg.Game.ChunkSize),
+		cfg:           cfg,
+		store:         store,
+		loop:          gametick.NewLoop(cfg.Game.TicksPerSecond),
+		metrics:       metrics.New(),
+		commands:      newCommandRegistry(),
+		chatFilter:    moderation.NewFilter(cfg.Chat.FilteredWords),
+		startedAt:     time.Now(),
+		udpBudget:     ratelimit.NewBucket(cfg.Limits.UDPPacketRate, cfg.Limits.UDPPacketBurst),
+		connLimiter:   ratelimit.NewKeyed(cfg.Limits.ConnectionRate, cfg.Limits.ConnectionBurst),
+		anticheat:     newValidator(cfg),
+		brain:         newBrain(cfg),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+	s.pathfinder = newPathfinder(s)
+	s.registerMetrics()
+	s.registerDefaultCommands()
+	return s
+}
+
+// Metrics returns the server's metric collectors
+func (s *GameServer) Metrics() *metrics.Metrics {
+	return s.metrics
+}
+
+func (s *GameServer) registerMetrics() {
+	m := s.metrics
+
+	s.loop.Observe = func(d time.Duration) {
+		m.TickDuration.Observe(d.Seconds())
+	}
+
+	m.Gauge("connected_clients", "Open client connections.", func() float64 {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return float64(len(s.connections))
+	})
+	m.Gauge("logged_in_players", "Connections with a logged in player.", func() float64 {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		count := 0
+		for _, gc := range s.connections {
+			gc.mu.RLock()
+			if gc.player != nil {
+				count++
+			}
+			gc.mu.RUnlock()
+		}
+		return float64(count)
+	})
+	m.Gauge("suspended_sessions", "Players whose connection dropped and who may still resume.", func() float64 {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		count := 0
+		for _, gc := range s.connections {
+			gc.mu.RLock()
+			if !gc.suspendedAt.IsZero() {
+				count++
+			}
+			gc.mu.RUnlock()
+		}
+		return float64(count)
+	})
+	m.Gauge("moving_players", "Players simulated by the tick loop.", func() float64 {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return float64(len(s.movingPlayers))
+	})
+	m.Gauge("entities", "Players, units and projectiles simulated by the tick loop.", func() float64 {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return float64(s.world.Len())
+	})
+	m.Counter("tiles_captured_total", "Tiles occupied or freed by units.", func() float64 {
+		return float64(s.captures.Load())
+	})
+	m.Gauge("dirty_tiles", "Updated tiles waiting for the next save.", func() float64 {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return float64(len(s.updatedTiles))
+	})
+	m.Gauge("tick", "Current simulation tick.", func() float64 {
+		return float64(s.loop.Tick())
+	})
+	m.Counter("tick_overruns_total", "Ticks that took longer than the tick duration.", func() float64 {
+		return float64(s.loop.Stats().Overruns)
+	})
+	m.Counter("ticks_skipped_total", "Ticks dropped because the loop fell behind.", func() float64 {
+		return float64(s.loop.Stats().Skipped)
+	})
+
+	if provider, ok := s.store.(storage.StatsProvider); ok {
+		m.DBStats(provider.DBStats)
 	}
 }
 
@@ -61,7 +225,7 @@ func NewGameConnection(conn net.Conn, server *GameServer) *GameConnection {
 	// Generate a unique connection ID
 	var connID uint32
 	for {
-		connID = rand.Uint32()
+		connID = mathrand.Uint32()
 		unique := true
 		server.mu.RLock()
 		_, exists := server.connections[connID]
@@ -74,12 +238,28 @@ func NewGameConnection(conn net.Conn, server *GameServer) *GameConnection {
 			break
 		}
 	}
+	var udpKey [b.DatagramKeySize]byte
+	rand.Read(udpKey[:])
+	// The key is only secret if it was sent over TLS
+	udpCodec := b.NewSignedCodec(udpKey)
+	if isTLS(conn) {
+		udpCodec = b.NewEncryptedCodec(udpKey)
+	}
+
+	chat := server.cfg.Chat
 	return &GameConnection{
-		conn:    conn,
-		udpAddr: nil,
-		udpConn: nil,
-		connID:  connID,
-		server:  server,
+		conn:          conn,
+		udpAddr:       nil,
+		udpConn:       nil,
+		udpKey:        udpKey,
+		udpCodec:      udpCodec,
+		connID:        connID,
+		server:        server,
+		lastHeartbeat: time.Now(),
+		chatLimiter:   ratelimit.NewBucket(chat.RateLimit, chat.RateBurst),
+		chatRepeats:   moderation.NewRepeatGuard(chat.MaxRepeats, chat.RepeatWindow),
+		violations:    ratelimit.NewBucket(violationRate, maxViolations),
+		limits:        newMessageLimiters(server.cfg),
 	}
 }
 
@@ -103,7 +283,7 @@ func (s *GameServer) broadcastInternal(msg b.Message, connections []*GameConnect
 			if c == nil {
 				return
 			}
-			if err := c.SendTCPMessage(msg); err != nil {
+			if err := c.SendTCPMessage(msg); err != nil && !errors.Is(err, errSessionSuspended) {
 				log.Printf("Error broadcasting to %s: %v\n",
 					c.conn.RemoteAddr().String(), err)
 			}
@@ -111,55 +291,43 @@ func (s *GameServer) broadcastInternal(msg b.Message, connections []*GameConnect
 	}
 }
 
-// BroadcastInRange sends a message to all clients within MaxViewDistance
+// BroadcastToCountry sends a message to all logged in players of a country
+func (s *GameServer) BroadcastToCountry(msg b.Message, countryID uint8) {
+	recipients := make([]*GameConnection, 0)
+	for _, conn := range s.connectionsSnapshot() {
+		conn.mu.RLock()
+		if conn.player != nil && conn.player.CountryID == countryID {
+			recipients = append(recipients, conn)
+		}
+		conn.mu.RUnlock()
+	}
+
+	s.broadcastInternal(msg, recipients)
+}
+
+// BroadcastInRange sends a message to all players within MaxViewDistance
 func (s *GameServer) BroadcastInRange(msg b.Message, centerX, centerY float32, useTCP bool) {
 	s.mu.RLock()
-	connectionsCopy := make([]*GameConnection, 0, len(s.connections))
-	for _, conn := range s.connections {
-		connectionsCopy = append(connectionsCopy, conn)
-	}
+	recipients := s.connectionsInRange(centerX, centerY)
 	s.mu.RUnlock()
 
-	s.broadcastInRangeInternal(msg, centerX, centerY, connectionsCopy, useTCP)
+	s.broadcastTo(msg, recipients, useTCP)
 }
 
-// broadcastInRangeInternal performs the actual broadcast without acquiring server locks
-// This is useful when the caller already holds the server lock
-func (s *GameServer) broadcastInRangeInternal(msg b.Message, centerX, centerY float32, connections []*GameConnection, useTCP bool) {
+// broadcastTo sends a message to the given connections without acquiring
+// server locks. This is useful when the caller already holds the server lock.
+func (s *GameServer) broadcastTo(msg b.Message, connections []*GameConnection, useTCP bool) {
 	for _, conn := range connections {
 		go func(c *GameConnection) {
-			if c == nil {
-				return
-			}
-
-			c.mu.RLock()
-			// Skip clients without players
-			if c.player == nil {
-				c.mu.RUnlock()
-				return
-			}
-
-			// Calculate distance between players
-			dx := c.player.CoordX - centerX
-			dy := c.player.CoordY - centerY
-			distance := math.Sqrt(float64(dx*dx) + float64(dy*dy))
-			playerWithinRange := distance <= float64(config.MaxViewDistance)
-			c.mu.RUnlock()
-
-			// Send only if within view distance
-			if playerWithinRange {
-				var err error
-				if useTCP {
-					err = c.SendTCPMessage(msg)
-				} else {
-					err = c.SendUDPMessage(msg)
-				}
-				if err != nil {
-					log.Printf("Error broadcasting to %s: %v\n",
-						c.conn.RemoteAddr().String(), err)
-				}
+			var err error
+			if useTCP {
+				err = c.SendTCPMessage(msg)
 			} else {
-				// log.Printf("Player %s (%f, %f) not in range (%f, %f) - distance: %f - max distance: %d", c.player.Nickname, c.player.CoordX, c.player.CoordY, centerX, centerY, distance, config.MaxViewDistance)
+				err = c.SendUDPMessage(msg)
+			}
+			if err != nil && !errors.Is(err, errSessionSuspended) {
+				log.Printf("Error broadcasting to %s: %v\n",
+					c.conn.RemoteAddr().String(), err)
 			}
 		}(conn)
 	}
@@ -168,6 +336,7 @@ func (s *GameServer) broadcastInRangeInternal(msg b.Message, centerX, centerY fl
 func (gc *GameConnection) handleLogin(data []byte) {
 	loginRequest, err := b.DecodeLoginMessage(data)
 	if err != nil {
+		gc.violation("malformed")
 		gc.SendTCPMessage(b.Message{
 			Type:  types.LoginMessage,
 			Error: "error.request.invalid",
@@ -184,19 +353,10 @@ func (gc *GameConnection) handleLogin(data []byte) {
 		return
 	}
 
-	// check if player with nickname already connected
-	gc.server.mu.RLock()
-	connectedNicknames := make(map[string]bool)
-	for _, conn := range gc.server.connections {
-		conn.mu.RLock()
-		if conn.player != nil {
-			connectedNicknames[strings.ToLower(conn.player.Nickname)] = true
-		}
-		conn.mu.RUnlock()
-	}
-	gc.server.mu.RUnlock()
-
-	if connectedNicknames[strings.ToLower(loginRequest.Nickname)] {
+	gc.mu.RLock()
+	loggedIn := gc.player != nil
+	gc.mu.RUnlock()
+	if loggedIn {
 		gc.SendTCPMessage(b.Message{
 			Type:  types.LoginMessage,
 			Error: "error.player.already_connected",
@@ -204,17 +364,55 @@ func (gc *GameConnection) handleLogin(data []byte) {
 		return
 	}
 
-	gc.mu.Lock()
-	if err := config.DB.Where("nickname ILIKE ?", loginRequest.Nickname).First(&gc.player).Error; err != nil {
-		gc.player = nil // reset player if not found
-		gc.mu.Unlock()
+	loggedInPlayer, err := gc.server.store.FindPlayerByNickname(loginRequest.Nickname)
+	if err != nil {
 		gc.SendTCPMessage(b.Message{
 			Type:  types.LoginMessage,
 			Error: "error.player.not_found",
 		})
 		return
 	}
+
+	if ban := gc.server.findBan(loggedInPlayer.ID, remoteIP(gc.conn)); ban != nil {
+		gc.SendTCPMessage(b.Message{
+			Type:  types.LoginMessage,
+			Error: "error.player.banned",
+		})
+		return
+	}
+
+	// A new login takes over from an existing connection of the player, once
+	// it is known to succeed. The old connection saves the player, which is
+	// then loaded again so the new connection continues from that state.
+	if existing := gc.server.findConnectionByNickname(loginRequest.Nickname); existing != nil {
+		existing.Disconnect(&b.DisconnectMessage{Reason: b.DisconnectReasonReplacedByLogin})
+		if loggedInPlayer, err = gc.server.store.FindPlayerByNickname(loginRequest.Nickname); err != nil {
+			gc.SendTCPMessage(b.Message{
+				Type:  types.LoginMessage,
+				Error: "error.login.unavailable",
+			})
+			return
+		}
+	}
+	gc.server.mu.Lock()
+	entityID := gc.server.addPlayerEntity(loggedInPlayer, gc.connID)
+	gc.server.mu.Unlock()
+	gc.mu.Lock()
+	disconnected := gc.disconnected
+	if !disconnected {
+		gc.player = loggedInPlayer
+		gc.entity = entityID
+	}
 	gc.mu.Unlock()
+	if disconnected {
+		// the connection closed while the player was loaded
+		gc.server.mu.Lock()
+		gc.server.world.Remove(entityID)
+		gc.server.mu.Unlock()
+		return
+	}
+	// the new client starts its clock over
+	gc.server.anticheat.Reset(loggedInPlayer.ID)
 
 	binaryPlayer := getBinaryPlayer(gc.player)
 	player, err := b.EncodePlayer(binaryPlayer)
@@ -239,200 +437,42 @@ func (gc *GameConnection) handleLogin(data []byte) {
 	}, gc.player.CoordX, gc.player.CoordY, true)
 
 	// send initial data
+	gc.issueSession()
 	gc.sendSyncState()
+	gc.sendCommandList()
+	gc.deliverMail(loggedInPlayer)
 }
 
-func (gc *GameConnection) handleChat(data []byte) {
-	gc.mu.RLock()
-	defer gc.mu.RUnlock()
-	if gc.player == nil {
-		gc.SendTCPMessage(b.Message{
-			Type:  types.ChatMessage,
-			Error: "error.login.required",
-		})
-		return
-	}
-
-	msg, err := b.DecodeChatMessage(data)
+func (gc *GameConnection) handleMovement(data []byte) {
+	// Convert data to PlayerMovementRequest
+	moveReq, err := b.DecodePlayerMovementRequest(data)
 	if err != nil {
-		gc.SendTCPMessage(b.Message{
-			Type:  types.ChatMessage,
-			Error: "error.chat.invalid",
-		})
+		gc.violation("malformed")
 		return
 	}
 
-	if len(msg.Message) == 0 {
+	gc.mu.RLock()
+	player := gc.player
+	gc.mu.RUnlock()
+	if player == nil {
 		gc.SendTCPMessage(b.Message{
-			Type:  types.ChatMessage,
-			Error: "error.chat.empty",
+			Type:  types.UnauthorizedMessage,
+			Error: "error.login.required",
 		})
 		return
 	}
 
-	if msg.Message[0] == '/' {
-		// Handle commands here (e.g., /help, /whisper, etc.)
-		parts := strings.Fields(msg.Message)
-		switch parts[0] {
-		case "/help":
-			gc.SendTCPMessage(b.Message{
-				Type:  types.ChatMessage,
-				Error: "Help is on the way!",
-			})
-
-		case "/w":
-			if len(parts) < 3 {
-				gc.SendTCPMessage(b.Message{
-					Type:  types.ChatMessage,
-					Error: "error.chat.usage_whisper",
-				})
-				return
-			}
-
-			targetPlayer := parts[1]
-			whisperMessage := strings.Join(parts[2:], " ")
-			gc.SendTCPMessage(b.Message{
-				Type:  types.ChatMessage,
-				Error: fmt.Sprintf("Whispering to %s: %s", targetPlayer, whisperMessage),
-			})
-
-		case "/notice":
-			if len(parts) == 1 {
-				gc.SendTCPMessage(b.Message{
-					Type:  types.ChatMessage,
-					Error: "error.chat.usage_notice",
-				})
-				return
-			}
-			message := strings.Join(parts[1:], " ")
-			chatMessage := b.ChatMessage{
-				Type:    b.ChatMessageTypeNotice,
-				From:    "Notice",
-				Message: message,
-			}
-			data, err := b.EncodeChatMessage(&chatMessage)
-			if err == nil {
-				// Broadcast chat message to all clients
-				gc.server.Broadcast(b.Message{
-					Type: types.ChatMessage,
-					Data: data,
-				})
-			}
-
-		case "/tp":
-			if len(parts) != 4 {
-				gc.SendTCPMessage(b.Message{
-					Type:  types.ChatMessage,
-					Error: "error.chat.usage_tp",
-				})
-				return
-			}
-			targetPlayer := strings.ToLower(parts[1])
-			x, y := parts[2], parts[3]
-
-			var p *models.Player
-			if targetPlayer == "@me" {
-				p = gc.player
-			} else {
-				gc.server.mu.RLock()
-				for _, conn := range gc.server.connections {
-					if conn == nil || conn.player == nil {
-						continue
-					}
-					if strings.ToLower(conn.player.Nickname) == targetPlayer {
-						p = conn.player
-						break
-					}
-				}
-				gc.server.mu.RUnlock()
-			}
-
-			if p == nil {
-				gc.SendTCPMessage(b.Message{
-					Type:  types.ChatMessage,
-					Error: "error.general.player_not_found",
-				})
-				return
-			}
-
-			var cx, cy int
-			if cx, err = strconv.Atoi(x); err != nil {
-				gc.SendTCPMessage(b.Message{
-					Type:  types.ChatMessage,
-					Error: "error.chat.usage_tp",
-				})
-				return
-			}
-			if cy, err = strconv.Atoi(y); err != nil {
-				gc.SendTCPMessage(b.Message{
-					Type:  types.ChatMessage,
-					Error: "error.chat.usage_tp",
-				})
-				return
-			}
-			p.CoordX, p.CoordY = float32(cx), float32(cy)
-			p.LastUpdated = time.Now()
-			// update moving players
-			gc.server.mu.Lock()
-			gc.server.movingPlayers[p.ID] = p
-			gc.server.mu.Unlock()
-			// send chat message to player
-			chatMessage := b.ChatMessage{
-				Type:    b.ChatMessageTypeSystem,
-				From:    "System",
-				Message: fmt.Sprintf("Player %s successfully teleported to %s,%s", p.Nickname, x, y),
-			}
-			data, err := b.EncodeChatMessage(&chatMessage)
-			if err == nil {
-				gc.SendTCPMessage(b.Message{
-					Type: types.ChatMessage,
-					Data: data,
-				})
-			}
-
-		default:
-			gc.SendTCPMessage(b.Message{
-				Type:  types.ChatMessage,
-				Error: "error.chat.unknown_command",
-			})
-		}
-	} else {
-		chatMessage := b.ChatMessage{
-			Type:    b.ChatMessageTypeGeneral,
-			From:    gc.player.Nickname,
-			Message: msg.Message,
-		}
-		data, err := b.EncodeChatMessage(&chatMessage)
-		if err != nil {
-			gc.SendTCPMessage(b.Message{
-				Type:  types.ChatMessage,
-				Error: "error.chat.encoding_failed",
-			})
-			return
-		}
-		// Broadcast chat message to all clients
-		gc.server.Broadcast(b.Message{
-			Type: types.ChatMessage,
-			Data: data,
-		})
+	// Inputs with forged timestamps are dropped before they can replace
+	// honest ones
+	report, ok := gc.server.anticheat.Input(player.ID, moveReq.Timestamp, gc.server.loop.Tick(), time.Now())
+	gc.server.reportCheat(player.ID, player.Nickname, report)
+	if !ok {
+		return
 	}
-}
 
-func (gc *GameConnection) handleMovement(data any) {
 	gc.mu.Lock()
 	defer gc.mu.Unlock()
 	if gc.player == nil {
-		gc.mu.Unlock()
-		gc.SendTCPMessage(b.Message{
-			Type:  types.UnauthorizedMessage,
-			Error: "error.login.required",
-		})
-		return
-	}
-
-	// Convert data to PlayerMovementRequest
-	moveReq, err := b.DecodePlayerMovementRequest(data.([]byte))
-	if err != nil {
 		return
 	}
 
@@ -441,7 +481,8 @@ func (gc *GameConnection) handleMovement(data any) {
 		return // return, already have more current input
 	}
 
-	// Update player
+	// Update player, input from the client ends any route
+	gc.route = nil
 	gc.player.DirX, gc.player.DirY = moveReq.DirX, moveReq.DirY
 	gc.player.LastUpdatedTicks = moveReq.Timestamp
 	gc.player.LastUpdated = time.Now()
@@ -449,12 +490,12 @@ func (gc *GameConnection) handleMovement(data any) {
 	// Update moving players
 	gc.server.mu.Lock()
 	if gc.player.IsMoving() {
-		gc.server.movingPlayers[gc.player.ID] = gc.player
+		gc.server.movingPlayers[gc.player.ID] = gc
 	}
 	gc.server.mu.Unlock()
 }
 
-func (gc *GameConnection) handlePlayerData(data any) {
+func (gc *GameConnection) handlePlayerData(data []byte) {
 	gc.mu.RLock()
 	if gc.player == nil {
 		gc.mu.RUnlock()
@@ -468,8 +509,9 @@ func (gc *GameConnection) handlePlayerData(data any) {
 	gc.mu.RUnlock()
 
 	// Convert data to PlayerDataRequest
-	req, err := b.DecodePlayerDataRequest(data.([]byte))
+	req, err := b.DecodePlayerDataRequest(data)
 	if err != nil {
+		gc.violation("malformed")
 		return
 	}
 
@@ -507,7 +549,7 @@ func (gc *GameConnection) handlePlayerData(data any) {
 	distance := math.Sqrt(float64(dx*dx + dy*dy))
 
 	// Check if player is within MaxViewDistance
-	if distance > float64(config.MaxViewDistance) {
+	if distance > float64(gc.server.cfg.Game.MaxViewDistance()) {
 		return
 	}
 
@@ -522,7 +564,7 @@ func (gc *GameConnection) handlePlayerData(data any) {
 	}
 }
 
-func (gc *GameConnection) handleChunkRequest(data any) {
+func (gc *GameConnection) handleChunkRequest(data []byte) {
 	gc.mu.RLock()
 	if gc.player == nil {
 		gc.mu.RUnlock()
@@ -534,12 +576,14 @@ func (gc *GameConnection) handleChunkRequest(data any) {
 	}
 
 	// Calculate player's current chunk
-	playerChunkX, playerChunkY := gc.player.GetChunkCoord(config.ChunkSize)
+	chunkSize := gc.server.cfg.Game.ChunkSize
+	playerChunkX, playerChunkY := gc.player.GetChunkCoord(chunkSize)
 	gc.mu.RUnlock()
 
 	// Convert data to ChunkRequest
-	chunk, err := b.DecodeChunkRequest(data.([]byte))
+	chunk, err := b.DecodeChunkRequest(data)
 	if err != nil {
+		gc.violation("malformed")
 		gc.SendTCPMessage(b.Message{
 			Type:  types.ChunkRequestMessage,
 			Error: "error.invalid.request",
@@ -552,63 +596,153 @@ func (gc *GameConnection) handleChunkRequest(data any) {
 	chunkDy := chunk.ChunkY - playerChunkY
 	chunkDistance := math.Sqrt(float64(chunkDx*chunkDx + chunkDy*chunkDy))
 
-	if chunkDistance > math.Hypot(float64(config.MaxChunkViewDistance), float64(config.MaxChunkViewDistance)) {
+	maxChunkViewDistance := float64(gc.server.cfg.Game.MaxChunkViewDistance)
+	if chunkDistance > math.Hypot(maxChunkViewDistance, maxChunkViewDistance) {
 		return
 	}
 
-	// Calculate chunk boundaries
-	startX := chunk.ChunkX * uint16(config.ChunkSize)
-	startY := chunk.ChunkY * uint16(config.ChunkSize)
-	endX := startX + uint16(config.ChunkSize)
-	endY := startY + uint16(config.ChunkSize)
+	chunkPacket, err := gc.server.chunkPacket(chunk.ChunkX, chunk.ChunkY)
+	if err != nil {
+		log.Printf("error sending chunks: %s\n", err)
+		return
+	}
 
-	chunkTiles := make([]b.ChunkTile, 0)
+	// Send chunk data
+	gc.SendTCPMessage(b.Message{
+		Type: types.ChunkDataMessage,
+		Data: chunkPacket,
+	})
+}
 
-	gc.server.mu.RLock()
-	// Collect tiles in this chunk
-	for x := startX; x < endX; x++ {
-		for y := startY; y < endY; y++ {
-			if tile, exists := gc.server.tiles[fmt.Sprintf("%d,%d", x, y)]; exists {
-				chunkTiles = append(chunkTiles, b.ChunkTile{
+// chunkPacket encodes the tiles of a chunk, tiles missing from the map as
+// water. The chunk size is checked to be 16 by the config.
+func (s *GameServer) chunkPacket(chunkX, chunkY uint16) ([]byte, error) {
+	chunkSize := uint16(s.cfg.Game.ChunkSize)
+	startX, startY := chunkX*chunkSize, chunkY*chunkSize
+
+	packet := b.ChunkPacket{ChunkX: chunkX, ChunkY: chunkY}
+	i := 0
+	s.mu.RLock()
+	for x := startX; x < startX+chunkSize; x++ {
+		for y := startY; y < startY+chunkSize; y++ {
+			if tile, exists := s.tiles[fmt.Sprintf("%d,%d", x, y)]; exists {
+				packet.Tiles[i] = b.ChunkTile{
 					CountryID:           uint8(tile.OwnerCountryID),
 					IsBorder:            tile.IsBorder,
 					Type:                uint8(tile.TileType),
 					PrefabID:            tile.PrefabID,
 					OccupiedByCountryID: tile.OccupiedByCountryID,
-				})
+				}
 			} else {
-				chunkTiles = append(chunkTiles, b.ChunkTile{
-					Type: uint8(types.TileTypeWater),
-				}) // empty tile
+				packet.Tiles[i] = b.ChunkTile{Type: uint8(types.TileTypeWater)} // empty tile
 			}
+			i++
 		}
 	}
-	gc.server.mu.RUnlock()
+	s.mu.RUnlock()
 
-	// check chunkTiles array length if is it equals to 256
-	if len(chunkTiles) != 256 {
-		return
+	return b.EncodeChunkPacket(packet)
+}
+
+// broadcastTileChanges sends the chunks of the changed tiles again to the
+// players who may request them. The caller must not hold the server mutex.
+func (s *GameServer) broadcastTileChanges(tiles []models.MapTile) {
+	chunkSize := uint16(s.cfg.Game.ChunkSize)
+	sent := make(map[[2]uint16]bool)
+	for _, tile := range tiles {
+		chunk := [2]uint16{tile.CoordX / chunkSize, tile.CoordY / chunkSize}
+		if sent[chunk] {
+			continue
+		}
+		sent[chunk] = true
+
+		data, err := s.chunkPacket(chunk[0], chunk[1])
+		if err != nil {
+			log.Printf("Error encoding chunk %d,%d: %v\n", chunk[0], chunk[1], err)
+			continue
+		}
+		s.mu.RLock()
+		recipients := s.connectionsViewingChunk(chunk[0], chunk[1])
+		s.mu.RUnlock()
+		s.broadcastTo(b.Message{Type: types.ChunkDataMessage, Data: data}, recipients, true)
 	}
+}
 
-	chunkPacket, err := b.EncodeChunkPacket(b.ChunkPacket{
-		ChunkX: chunk.ChunkX,
-		ChunkY: chunk.ChunkY,
-		Tiles:  [256]b.ChunkTile(chunkTiles),
-	})
-	if err != nil {
-		log.Printf("error sending chunks: %s\n", err)
-		return
+// connectionsViewingChunk returns the connections of the players close
+// enough to request the chunk, see handleChunkRequest. s.mu must be held.
+func (s *GameServer) connectionsViewingChunk(chunkX, chunkY uint16) []*GameConnection {
+	chunkSize := float64(s.cfg.Game.ChunkSize)
+	viewDistance := math.Hypot(float64(s.cfg.Game.MaxChunkViewDistance), float64(s.cfg.Game.MaxChunkViewDistance))
+	centreX := (float64(chunkX) + 0.5) * chunkSize
+	centreY := (float64(chunkY) + 0.5) * chunkSize
+
+	connections := make([]*GameConnection, 0)
+	for _, e := range s.world.Near(float32(centreX), float32(centreY), float32((viewDistance+1)*chunkSize)) {
+		if e.Player == nil {
+			continue
+		}
+		dx := float64(int(e.X)/s.cfg.Game.ChunkSize) - float64(chunkX)
+		dy := float64(int(e.Y)/s.cfg.Game.ChunkSize) - float64(chunkY)
+		if math.Hypot(dx, dy) > viewDistance {
+			continue
+		}
+		if gc, ok := s.connections[e.Player.ConnID]; ok {
+			connections = append(connections, gc)
+		}
 	}
+	return connections
+}
 
-	// Send chunk data
+// Disconnect sends the client a DisconnectMessage, removes the player and
+// closes the connection. The caller must not hold the server mutex.
+func (gc *GameConnection) Disconnect(msg *b.DisconnectMessage) {
+	gc.sendDisconnect(msg)
+
+	// Remove the player before the read loop sees the closed connection, so
+	// the session is not kept for resuming
+	gc.server.mu.Lock()
+	gc.handleDisconnect()
+	gc.server.mu.Unlock()
+
+	gc.conn.Close()
+}
+
+// disconnectWriteTimeout is how long a client gets to take the disconnect
+// message before its connection is closed anyway
+const disconnectWriteTimeout = time.Second
+
+// sendDisconnect tells the client why it is about to be disconnected. A
+// client that stopped reading cannot hold up the caller for longer than
+// disconnectWriteTimeout.
+func (gc *GameConnection) sendDisconnect(msg *b.DisconnectMessage) {
+	// the connection is closed right after, so the deadline is never lifted
+	gc.conn.SetWriteDeadline(time.Now().Add(disconnectWriteTimeout))
+
+	data, err := b.EncodeDisconnectMessage(msg)
+	if err != nil {
+		// reason text too long, the code alone still explains it
+		data, _ = b.EncodeDisconnectMessage(&b.DisconnectMessage{Reason: msg.Reason, Until: msg.Until})
+	}
 	gc.SendTCPMessage(b.Message{
-		Type: types.ChunkDataMessage,
-		Data: chunkPacket,
+		Type: types.DisconnectMessage,
+		Data: data,
 	})
 }
 
-// handleDisconnect must be called with server mutex locked
+// handleDisconnect removes the connection and saves its player. It runs
+// once; later calls do nothing. The caller must hold the server mutex.
 func (gc *GameConnection) handleDisconnect() {
+	gc.mu.Lock()
+	if gc.disconnected {
+		gc.mu.Unlock()
+		return
+	}
+	gc.disconnected = true
+	// Datagrams for this connection ID are ignored from now on
+	gc.udpAddr = nil
+	gc.udpConn = nil
+	gc.mu.Unlock()
+
 	gc.mu.RLock()
 	log.Printf("Disconnected: %s\n", gc.conn.RemoteAddr().String())
 
@@ -620,19 +754,16 @@ func (gc *GameConnection) handleDisconnect() {
 		playerCoords[0] = gc.player.CoordX
 		playerCoords[1] = gc.player.CoordY
 	}
+	entityID := gc.entity
 	gc.mu.RUnlock()
 
-	// Create connections copy before deleting (we already have server lock)
-	connectionsCopy := make([]*GameConnection, 0, len(gc.server.connections))
-	for _, conn := range gc.server.connections {
-		connectionsCopy = append(connectionsCopy, conn)
-	}
-
-	// Delete connection (caller must have server mutex locked)
+	// Delete connection and entity (caller must have server mutex locked)
 	delete(gc.server.connections, gc.connID)
+	gc.server.world.Remove(entityID)
 
 	if playerToSave != nil {
-		if err := config.DB.Save(playerToSave).Error; err != nil {
+		gc.server.anticheat.Forget(playerToSave.ID)
+		if err := gc.server.store.SavePlayer(playerToSave); err != nil {
 			log.Printf("Error saving player %s: %v\n", playerToSave.Nickname, err)
 		} else {
 			log.Printf("Player %s saved successfully\n", playerToSave.Nickname)
@@ -641,10 +772,10 @@ func (gc *GameConnection) handleDisconnect() {
 		// Send player left message to nearby players using internal broadcast
 		data := make([]byte, 4)
 		binary.LittleEndian.PutUint32(data, uint32(playerToSave.ID))
-		gc.server.broadcastInRangeInternal(b.Message{
+		gc.server.broadcastTo(b.Message{
 			Type: types.PlayerLeftMessage,
 			Data: data,
-		}, playerCoords[0], playerCoords[1], connectionsCopy, true)
+		}, gc.server.connectionsInRange(playerCoords[0], playerCoords[1]), true)
 	}
 }
 
@@ -659,7 +790,11 @@ func (gc *GameConnection) SendTCPMessage(msg b.Message) error {
 
 	gc.mu.RLock()
 	conn := gc.conn
+	suspended := !gc.suspendedAt.IsZero()
 	gc.mu.RUnlock()
+	if suspended {
+		return errSessionSuspended
+	}
 
 	rawData, err := b.EncodeRawMessage(msg)
 	if err != nil {
@@ -672,15 +807,18 @@ func (gc *GameConnection) SendTCPMessage(msg b.Message) error {
 	copy(messageBuffer[4:], rawData)
 
 	// Write entire message in a single call
-	if _, err := conn.Write(messageBuffer); err != nil {
+	n, err := conn.Write(messageBuffer)
+	gc.server.metrics.BytesSent.WithLabelValues("tcp").Add(float64(n))
+	if err != nil {
 		return fmt.Errorf("failed to write message: %v", err)
 	}
+	gc.server.metrics.MessagesOut.WithLabelValues(msg.Type.String()).Inc()
 
 	return nil
 }
 
 func (gc *GameConnection) SendUDPMessage(msg b.Message) error {
-	if gc == nil || gc.udpConn == nil {
+	if gc == nil {
 		return fmt.Errorf("invalid connection")
 	}
 
@@ -688,6 +826,9 @@ func (gc *GameConnection) SendUDPMessage(msg b.Message) error {
 	conn := gc.udpConn
 	addr := gc.udpAddr
 	gc.mu.RUnlock()
+	if conn == nil || addr == nil {
+		return fmt.Errorf("invalid connection")
+	}
 
 	rawData, err := b.EncodeRawMessage(msg)
 	if err != nil {
@@ -695,9 +836,12 @@ func (gc *GameConnection) SendUDPMessage(msg b.Message) error {
 	}
 
 	// Write entire message in a single call
-	if _, err := conn.WriteToUDP(rawData, addr); err != nil {
+	n, err := conn.WriteToUDP(gc.udpCodec.SealServer(gc.udpSendSeq.Add(1), rawData), addr)
+	gc.server.metrics.BytesSent.WithLabelValues("udp").Add(float64(n))
+	if err != nil {
 		return fmt.Errorf("failed to write message: %v", err)
 	}
+	gc.server.metrics.MessagesOut.WithLabelValues(msg.Type.String()).Inc()
 
 	return nil
 }
@@ -706,29 +850,41 @@ func (s *GameServer) autoSaveRoutine() {
 	ticker := time.NewTicker(60 * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		Save()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.Save()
+			s.pruneChatHistory()
+		}
 	}
 }
 
-func Save() {
-	server.mu.RLock()
-	connectionsCopy := make([]*GameConnection, 0, len(server.connections))
-	for _, conn := range server.connections {
+// Save persists all active players and updated tiles
+func (s *GameServer) Save() {
+	start := time.Now()
+	defer func() {
+		s.metrics.AutosaveDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	s.mu.RLock()
+	connectionsCopy := make([]*GameConnection, 0, len(s.connections))
+	for _, conn := range s.connections {
 		connectionsCopy = append(connectionsCopy, conn)
 	}
 
 	// Collect updated tiles
-	updatedTiles := make([]models.MapTile, 0, len(server.updatedTiles))
-	for _, tile := range server.updatedTiles {
+	updatedTiles := make([]models.MapTile, 0, len(s.updatedTiles))
+	for _, tile := range s.updatedTiles {
 		updatedTiles = append(updatedTiles, tile)
 	}
-	server.mu.RUnlock()
+	s.mu.RUnlock()
 
 	// Clear updated tiles map after collecting (need write lock for this)
-	server.mu.Lock()
-	server.updatedTiles = make(map[string]models.MapTile)
-	server.mu.Unlock()
+	s.mu.Lock()
+	s.updatedTiles = make(map[string]models.MapTile)
+	s.mu.Unlock()
 
 	// Collect all active players
 	activePlayers := make([]*models.Player, 0)
@@ -747,7 +903,7 @@ func Save() {
 
 	// Save players in batches
 	if len(activePlayers) > 0 {
-		if err := config.DB.Save(&activePlayers).Error; err != nil {
+		if err := s.store.SavePlayers(activePlayers); err != nil {
 			log.Printf("Error during player auto-save: %v\n", err)
 		} else {
 			log.Printf("Auto-saved %d players\n", len(activePlayers))
@@ -756,7 +912,7 @@ func Save() {
 
 	// Save updated tiles in batches
 	if len(updatedTiles) > 0 {
-		if err := config.DB.Save(&updatedTiles).Error; err != nil {
+		if err := s.store.SaveTiles(updatedTiles); err != nil {
 			log.Printf("Error during tile auto-save: %v\n", err)
 		} else {
 			log.Printf("Auto-saved %d tiles\n", len(updatedTiles))
@@ -766,11 +922,14 @@ func Save() {
 
 func (s *GameServer) UpdateTile(tile models.MapTile) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	key := fmt.Sprintf("%d,%d", tile.CoordX, tile.CoordY)
 	s.tiles[key] = tile
 	s.updatedTiles[key] = tile
+	s.pathfinder.Invalidate(int(tile.CoordX), int(tile.CoordY))
+	s.indexTile(tile)
+	s.mu.Unlock()
+
+	s.broadcastTileChanges([]models.MapTile{tile})
 }
 
 func (gc *GameConnection) sendSyncState() {
@@ -781,61 +940,49 @@ func (gc *GameConnection) sendSyncState() {
 		return
 	}
 	playerCoords := [2]float32{gc.player.CoordX, gc.player.CoordY}
-	playerID := gc.player.ID
+	entityID := gc.entity
+	countryID := gc.player.CountryID
 	gc.mu.RUnlock()
 
-	// Then get server data safely - separate the operations
-	gc.server.mu.RLock()
-	connectionsCopy := make([]*GameConnection, 0, len(gc.server.connections))
-	for _, conn := range gc.server.connections {
-		connectionsCopy = append(connectionsCopy, conn)
+	// Then get the entities in view and the countries from the server
+	s := gc.server
+	s.mu.RLock()
+	onlineCount := len(s.connections)
+	nearbyConnections := make([]*GameConnection, 0)
+	nearbyEntities := make([]b.Entity, 0)
+	for _, e := range s.world.Near(playerCoords[0], playerCoords[1], float32(s.cfg.Game.MaxViewDistance())) {
+		switch {
+		case e.ID == entityID:
+		case e.Player != nil:
+			if conn, ok := s.connections[e.Player.ConnID]; ok {
+				nearbyConnections = append(nearbyConnections, conn)
+			}
+		default:
+			nearbyEntities = append(nearbyEntities, getBinaryEntity(e))
+		}
 	}
-	gc.server.mu.RUnlock()
-
-	// Get countries separately to minimize lock time
-	gc.server.mu.RLock()
-	countriesCopy := make(map[uint8]models.Country)
-	for id, country := range gc.server.countries {
-		countriesCopy[id] = country
+	binaryCountries := make([]b.Country, 0, len(s.countries))
+	for _, country := range s.countries {
+		binaryCountries = append(binaryCountries, getBinaryCountry(country))
 	}
-	gc.server.mu.RUnlock()
-
-	// Collect nearby players without holding server lock
-	nearbyPlayers := make([]*b.Player, 0)
-	for _, conn := range connectionsCopy {
-		if conn == nil {
-			continue
-		}
+	s.mu.RUnlock()
 
+	// Copy the nearby players without holding the server lock
+	nearbyPlayers := make([]*b.Player, 0, len(nearbyConnections))
+	for _, conn := range nearbyConnections {
 		conn.mu.RLock()
-		if conn.player == nil || conn.player.ID == playerID {
-			conn.mu.RUnlock()
-			continue
+		if conn.player != nil {
+			nearbyPlayers = append(nearbyPlayers, getBinaryPlayer(conn.player))
 		}
-
-		// Copy player data to avoid holding lock
-		playerData := conn.player.Copy()
 		conn.mu.RUnlock()
-
-		dx := float64(playerData.CoordX - playerCoords[0])
-		dy := float64(playerData.CoordY - playerCoords[1])
-		distance := math.Sqrt(dx*dx + dy*dy)
-
-		if distance <= float64(config.MaxViewDistance) {
-			nearbyPlayers = append(nearbyPlayers, getBinaryPlayer(playerData))
-		}
-	}
-
-	// Binary countries
-	binaryCountries := make([]b.Country, 0)
-	for _, country := range countriesCopy {
-		binaryCountries = append(binaryCountries, getBinaryCountry(country))
 	}
 
 	data, err := b.EncodeSyncStateData(&b.SyncStateData{
 		Players:     nearbyPlayers,
 		Countries:   binaryCountries,
-		OnlineCount: len(connectionsCopy),
+		OnlineCount: onlineCount,
+		ChatHistory: s.chatHistory(countryID),
+		Entities:    nearbyEntities,
 	})
 	if err != nil {
 		return
@@ -848,232 +995,443 @@ func (gc *GameConnection) sendSyncState() {
 	})
 }
 
-func StartServer() {
-	port := os.Getenv("APP_PORT")
-	server = NewGameServer()
+// Load reads countries, map tiles and bans from the store into memory
+func (s *GameServer) Load() error {
+	countries, err := s.store.LoadCountries()
+	if err != nil {
+		return fmt.Errorf("failed to load countries: %v", err)
+	}
 
-	// Load countries from the database
-	var countries []models.Country
-	if err := config.DB.Find(&countries).Error; err != nil {
-		log.Fatalf("Failed to load countries: %v", err)
+	tiles, err := s.store.LoadTiles()
+	if err != nil {
+		return fmt.Errorf("failed to load map tiles: %v", err)
 	}
-	for _, country := range countries {
-		server.countries[country.ID] = country
+
+	bans, err := s.store.LoadBans()
+	if err != nil {
+		return fmt.Errorf("failed to load bans: %v", err)
 	}
 
-	// Load map tiles from the database
-	var tiles []models.MapTile
-	if err := config.DB.Find(&tiles).Error; err != nil {
-		log.Fatalf("Failed to load map tiles: %v", err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bans = bans
+	for _, country := range countries {
+		s.countries[country.ID] = country
 	}
 
 	// Store tiles in the server
 	for _, tile := range tiles {
 		key := fmt.Sprintf("%d,%d", tile.CoordX, tile.CoordY)
-		server.tiles[key] = tile
+		s.tiles[key] = tile
+		s.indexTile(tile)
 	}
 
+	return nil
+}
+
+// Serve starts the background routines and accepts clients on the given
+// listeners. It returns immediately; call Shutdown or Close to stop the server.
+func (s *GameServer) Serve(listener net.Listener, udpConn *net.UDPConn) {
+	s.listener = listener
+	s.udpConn = udpConn
+
+	s.routines.Add(4)
 	// Start auto-save routine
-	go server.autoSaveRoutine()
+	go func() {
+		defer s.routines.Done()
+		s.autoSaveRoutine()
+	}()
 	// Start cleanup routine
-	go server.cleanupInactiveConnections()
+	go func() {
+		defer s.routines.Done()
+		s.cleanupInactiveConnections()
+	}()
 	// Start tick loop
-	go server.tickLoop()
+	go func() {
+		defer s.routines.Done()
+		s.tickLoop()
+	}()
 
 	// TCP setup
 	go func() {
-		listener, err := net.Listen("tcp", ":"+port)
-		if err != nil {
-			log.Fatalf("Server could not be started: %v", err)
-		}
-		defer listener.Close()
-
-		fmt.Printf("TCP server is running on port %s...\n", port)
-
+		defer s.routines.Done()
 		for {
 			conn, err := listener.Accept()
 			if err != nil {
+				select {
+				case <-s.ctx.Done():
+					return
+				default:
+				}
 				log.Printf("Error accepting connection: %v", err)
 				continue
 			}
+			if ip := remoteIP(conn); ip != nil && !s.connLimiter.Allow(ip.String()) {
+				s.metrics.ConnRefused.WithLabelValues("rate_limited").Inc()
+				conn.Close()
+				continue
+			}
 
 			// Handle connection
-			go handleTCPConnection(server, conn)
+			s.clients.Add(1)
+			go func() {
+				defer s.clients.Done()
+				handleTCPConnection(s, conn)
+			}()
 		}
 	}()
 
-	// UDP setup
-	go func() {
-		addr, err := net.ResolveUDPAddr("udp", ":"+port)
-		if err != nil {
-			log.Fatalf("Failed to resolve UDP address: %v", err)
-		}
+	s.serveUDP(udpConn)
+}
 
-		udpConn, err := net.ListenUDP("udp", addr)
-		if err != nil {
-			log.Fatalf("Failed to start UDP server: %v", err)
-		}
-		defer udpConn.Close()
+// udpQueueSize is the number of datagrams waiting for a worker before
+// further ones are dropped
+const udpQueueSize = 1024
 
-		fmt.Printf("UDP server is running on port %s...\n", port)
+// maxDatagramSize bounds the datagrams read from clients
+const maxDatagramSize = 1500
 
+type udpPacket struct {
+	addr *net.UDPAddr
+	data []byte
+}
+
+// serveUDP reads datagrams within the global packet budget and hands them to
+// a fixed pool of workers
+func (s *GameServer) serveUDP(udpConn *net.UDPConn) {
+	packets := make(chan udpPacket, udpQueueSize)
+	workers := runtime.NumCPU()
+
+	s.routines.Add(1 + workers)
+	for range workers {
+		go func() {
+			defer s.routines.Done()
+			for packet := range packets {
+				handleUDPConnection(s, udpConn, packet.addr, packet.data)
+			}
+		}()
+	}
+
+	go func() {
+		defer s.routines.Done()
+		defer close(packets)
+
+		buffer := make([]byte, maxDatagramSize)
 		for {
-			buffer := make([]byte, 128)
 			n, remoteAddr, err := udpConn.ReadFromUDP(buffer)
 			if err != nil {
+				select {
+				case <-s.ctx.Done():
+					return
+				default:
+				}
 				log.Printf("Error reading from UDP connection: %v", err)
 				continue
 			}
 
-			go handleUDPConnection(server, udpConn, remoteAddr, buffer[:n])
+			if !s.udpBudget.Allow() {
+				s.metrics.UDPRejected.WithLabelValues("budget").Inc()
+				continue
+			}
+			select {
+			case packets <- udpPacket{addr: remoteAddr, data: bytes.Clone(buffer[:n])}:
+			default:
+				s.metrics.UDPRejected.WithLabelValues("queue_full").Inc()
+			}
 		}
 	}()
 }
 
-func (s *GameServer) cleanupInactiveConnections() {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+// ServeConn handles a single client connection until it is closed
+func (s *GameServer) ServeConn(conn net.Conn) {
+	s.clients.Add(1)
+	defer s.clients.Done()
+	handleTCPConnection(s, conn)
+}
 
-	for range ticker.C {
-		s.mu.RLock()
-		connectionsCopy := make([]*GameConnection, 0, len(s.connections))
-		for _, gc := range s.connections {
-			connectionsCopy = append(connectionsCopy, gc)
+// closeTimeout bounds how long Close waits for the clients to be
+// disconnected, each of which may take up to disconnectWriteTimeout
+const closeTimeout = 2 * disconnectWriteTimeout
+
+// Close stops the listeners and background routines and disconnects all
+// clients at once. Connections not disconnected within closeTimeout are
+// closed without further waiting.
+func (s *GameServer) Close() {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		if s.listener != nil {
+			s.listener.Close()
 		}
-		s.mu.RUnlock()
-
-		now := time.Now()
-		connectionsToRemove := make([]*GameConnection, 0)
 
-		for _, gc := range connectionsCopy {
-			gc.mu.RLock()
-			shouldRemove := now.Sub(gc.lastHeartbeat) > 30*time.Second
-			gc.mu.RUnlock()
-
-			if shouldRemove {
-				connectionsToRemove = append(connectionsToRemove, gc)
+		connections := s.connectionsSnapshot()
+		var disconnects sync.WaitGroup
+		for _, gc := range connections {
+			disconnects.Add(1)
+			go func() {
+				defer disconnects.Done()
+				gc.Disconnect(&b.DisconnectMessage{Reason: b.DisconnectReasonServerShutdown})
+			}()
+		}
+		done := make(chan struct{})
+		go func() {
+			disconnects.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(closeTimeout):
+			log.Printf("Clients not disconnected within %v, closing their connections\n", closeTimeout)
+			for _, gc := range connections {
+				gc.conn.Close()
 			}
 		}
 
-		if len(connectionsToRemove) > 0 {
-			s.mu.Lock()
-			for _, gc := range connectionsToRemove {
-				gc.handleDisconnect()
-			}
-			s.mu.Unlock()
+		if s.udpConn != nil {
+			s.udpConn.Close()
 		}
-	}
+	})
 }
 
-func (s *GameServer) tickLoop() {
-	duration := config.FixedDeltaTime
-	ticker := time.NewTicker(duration)
-	defer ticker.Stop()
+// Shutdown stops the server gracefully. Players are warned with a countdown
+// notice, then the server is closed, its routines and connection handlers are
+// awaited and all dirty state is saved. It gives up waiting when ctx is done.
+func (s *GameServer) Shutdown(ctx context.Context, countdown time.Duration) error {
+	s.announceShutdown(ctx, countdown)
 
-	// Get tiles safely
-	s.mu.RLock()
-	tiles := s.tiles
-	s.mu.RUnlock()
+	stopped := make(chan struct{})
+	go func() {
+		s.Close()
+		// the tick loop has stopped, so nothing is modified during the final save
+		s.routines.Wait()
+		s.clients.Wait()
+		s.Save()
+		close(stopped)
+	}()
 
-	for range ticker.C {
-		// Get moving players safely with server lock
-		s.mu.RLock()
-		playersCopy := make([]*models.Player, 0)
-		for _, player := range s.movingPlayers {
-			if player == nil {
-				continue
-			}
-			playersCopy = append(playersCopy, player)
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("shutdown did not finish: %w", ctx.Err())
+	}
+}
+
+// announceShutdown broadcasts the time left until shutdown at the start,
+// every ten seconds and during the last five seconds
+func (s *GameServer) announceShutdown(ctx context.Context, countdown time.Duration) {
+	deadline := time.Now().Add(countdown)
+	for first := true; ; first = false {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return
 		}
-		s.mu.RUnlock()
 
-		// Check player last updated time and delete if not moving more than a second
-		players := make([]*models.Player, 0)
-		for _, player := range playersCopy {
-			if player == nil {
-				continue
-			}
-			diff := time.Since(player.LastUpdated)
-			if diff.Seconds() >= 1 {
-				s.mu.Lock()
-				delete(s.movingPlayers, player.ID)
-				s.mu.Unlock()
-				continue
-			}
-			players = append(players, player)
+		seconds := int(math.Ceil(remaining.Seconds()))
+		if first || seconds%10 == 0 || seconds <= 5 {
+			s.BroadcastNotice(fmt.Sprintf("Server is shutting down in %v", time.Duration(seconds)*time.Second))
 		}
 
-		if len(players) == 0 {
-			continue
+		// wake up when the next whole second is left
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(remaining - time.Duration(seconds-1)*time.Second):
 		}
+	}
+}
 
-		// Calculate and send movement data
-		for _, player := range players {
-			if player == nil {
-				continue
-			}
+// StartServer creates a game server, binds the TCP and UDP listeners on the
+// configured port and starts serving
+func StartServer(cfg *config.Config, store storage.Store) (*GameServer, error) {
+	server := NewGameServer(cfg, store)
+	if err := server.Load(); err != nil {
+		return nil, err
+	}
 
-			// Calculate time delta in seconds since last update
-			func() {
-				cx, cy := player.CoordX, player.CoordY
+	var tlsConfig *tls.Config
+	if cfg.TLS.Enabled {
+		var err error
+		if tlsConfig, err = NewTLSConfig(cfg.TLS, cfg.App.Host); err != nil {
+			return nil, err
+		}
+	}
 
-				// Get movement speed
-				speed := player.GetCurrentSpeed()
+	addr := fmt.Sprintf(":%d", cfg.App.Port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("server could not be started: %v", err)
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+		fmt.Printf("TCP server is running on port %d with TLS...\n", cfg.App.Port)
+	} else {
+		fmt.Printf("TCP server is running on port %d...\n", cfg.App.Port)
+	}
 
-				// Normalize direction vector
-				magnitude := float32(math.Sqrt(float64(player.DirX*player.DirX + player.DirY*player.DirY)))
-				if magnitude > 0 {
-					normalizedDirX := player.DirX / magnitude
-					normalizedDirY := player.DirY / magnitude
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to resolve UDP address: %v", err)
+	}
 
-					// Calculate position based on normalized direction, speed and delta time
-					cx += normalizedDirX * speed * float32(config.FixedDeltaTime.Seconds())
-					cy += normalizedDirY * speed * float32(config.FixedDeltaTime.Seconds())
-				}
+	udpConn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to start UDP server: %v", err)
+	}
+	fmt.Printf("UDP server is running on port %d...\n", cfg.App.Port)
 
-				// Check if new position is walkable
-				tileKey := fmt.Sprintf("%d,%d", uint16(cx), uint16(cy))
+	server.Serve(listener, udpConn)
+	return server, nil
+}
 
-				tile, exists := tiles[tileKey]
-				if !exists {
-					// Not exists, skip
-					return
-				}
-				// Check if tile is suitable for the unit type
-				switch player.GetUnitType() {
-				case types.UnitTypeInfantry, types.UnitTypeTank:
-					if tile.TileType != types.TileTypeGround {
-						// Cannot walk on non-ground tiles
-						return
-					}
-				case types.UnitTypeShip, types.UnitTypeBattleShip:
-					if tile.TileType != types.TileTypeWater {
-						// Cannot sail on non-water tiles
-						return
-					}
+// heartbeatTimeout is how long a connection may stay silent before it is
+// disconnected
+const heartbeatTimeout = 30 * time.Second
 
-				}
+// cleanupInterval is how often timed out connections and expired sessions
+// are looked for
+const cleanupInterval = time.Second
 
-				// Update player
-				player.CoordX, player.CoordY = cx, cy
-			}()
+func (s *GameServer) cleanupInactiveConnections() {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.disconnectInactive(now)
+			s.expireSessions(now)
+			s.connLimiter.Prune(now)
+			s.anticheat.Prune(now)
+		}
+	}
+}
+
+// disconnectInactive disconnects clients that sent nothing within the
+// heartbeat timeout before now
+func (s *GameServer) disconnectInactive(now time.Time) {
+	for _, gc := range s.connectionsSnapshot() {
+		gc.mu.RLock()
+		// suspended sessions expire after the resume grace period instead
+		inactive := gc.suspendedAt.IsZero() && now.Sub(gc.lastHeartbeat) > heartbeatTimeout
+		gc.mu.RUnlock()
+
+		if inactive {
+			log.Printf("Connection %s timed out", gc.conn.RemoteAddr())
+			gc.Disconnect(&b.DisconnectMessage{Reason: b.DisconnectReasonTimeout})
+		}
+	}
+}
+
+func (s *GameServer) tickLoop() {
+	s.loop.Run(s.ctx.Done(), s.tick)
+}
+
+// tick advances the simulation by one fixed step
+func (s *GameServer) tick(tick uint64) {
+	duration := s.loop.DeltaTime()
+
+	// Tiles may be edited while players move, every lookup takes the lock
+	grid := func(x, y int) (types.TileType, bool) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return s.tileType(x, y)
+	}
+
+	// Get moving players safely with server lock
+	s.mu.RLock()
+	moving := make([]*GameConnection, 0, len(s.movingPlayers))
+	for _, gc := range s.movingPlayers {
+		moving = append(moving, gc)
+	}
+	s.mu.RUnlock()
+
+	// Calculate and send movement data
+	players := make([]playerState, 0, len(moving))
+	for _, gc := range moving {
+		gc.mu.Lock()
+		player := gc.player
+		if player == nil {
+			gc.mu.Unlock()
+			continue
+		}
 
-			playerMovementData := b.PlayerMovementData{
-				PlayerID:         uint32(player.ID),
-				PosX:             player.CoordX,
-				PosY:             player.CoordY,
-				DirX:             player.DirX,
-				DirY:             player.DirY,
-				Speed:            player.GetCurrentSpeed(),
-				LastUpdatedTicks: player.LastUpdatedTicks,
+		// Delete players that did not move for more than a second
+		if time.Since(player.LastUpdated).Seconds() >= 1 {
+			players = append(players, playerState{entity: gc.entity, x: player.CoordX, y: player.CoordY})
+			gc.mu.Unlock()
+			s.mu.Lock()
+			delete(s.movingPlayers, player.ID)
+			s.mu.Unlock()
+			continue
+		}
+
+		// Positions only change here or by sanctioned teleports, anything
+		// else moving a player faster than its speed is reported
+		report := s.anticheat.Position(player.ID, player.CoordX, player.CoordY, player.GetCurrentSpeed(), time.Now())
+
+		speed := player.GetCurrentSpeed()
+		onRoute := gc.route != nil && gc.followRoute(speed*float32(duration.Seconds()))
+
+		// Normalize direction vector
+		magnitude := float32(math.Sqrt(float64(player.DirX*player.DirX + player.DirY*player.DirY)))
+		if magnitude > 0 {
+			// Calculate the step based on normalized direction, speed and delta time
+			if player.Unit != nil {
+				// aircraft out of fuel slow down until they land
+				tile, _ := grid(int(player.CoordX), int(player.CoordY))
+				speed *= movement.Fuel(player.Unit, tile, duration, time.Now())
+			}
+			distance := speed * float32(duration.Seconds())
+			dx := player.DirX / magnitude * distance
+			dy := player.DirY / magnitude * distance
+
+			// Slide along tiles the unit may not enter
+			fromX, fromY := player.CoordX, player.CoordY
+			player.CoordX, player.CoordY = movement.Step(grid, player.GetUnitType(), player.CoordX, player.CoordY, dx, dy)
+
+			// a route blocked by a changed tile is given up
+			movedX, movedY := player.CoordX-fromX, player.CoordY-fromY
+			if onRoute && movedX*movedX+movedY*movedY < distance*distance/16 {
+				gc.stopRoute()
 			}
+		}
 
-			// Notify nearby clients about movement start
-			encodedData := b.EncodePlayerMovementData(&playerMovementData)
-			s.BroadcastInRange(b.Message{
-				Type: types.PlayerMovementMessage,
-				Data: encodedData,
-			}, player.CoordX, player.CoordY, false)
+		state := playerState{entity: gc.entity, x: player.CoordX, y: player.CoordY}
+		if magnitude := float32(math.Sqrt(float64(player.DirX*player.DirX + player.DirY*player.DirY))); magnitude > 0 {
+			state.velX, state.velY = player.DirX/magnitude*speed, player.DirY/magnitude*speed
 		}
+		players = append(players, state)
+
+		playerMovementData := b.PlayerMovementData{
+			PlayerID:         uint32(player.ID),
+			PosX:             player.CoordX,
+			PosY:             player.CoordY,
+			DirX:             player.DirX,
+			DirY:             player.DirY,
+			Speed:            player.GetCurrentSpeed(),
+			LastUpdatedTicks: player.LastUpdatedTicks,
+			Tick:             uint32(tick),
+		}
+		nickname := player.Nickname
+		gc.mu.Unlock()
+
+		s.reportCheat(player.ID, nickname, report)
+
+		// Notify nearby clients about movement start
+		encodedData := b.EncodePlayerMovementData(&playerMovementData)
+		s.BroadcastInRange(b.Message{
+			Type: types.PlayerMovementMessage,
+			Data: encodedData,
+		}, playerMovementData.PosX, playerMovementData.PosY, false)
+	}
+
+	s.tickEntities(tick, duration, players)
+	if s.brain != nil {
+		// the commanders stay within their budget, see AIConfig.TickBudget
+		s.brain.Think(&aiWorld{s: s}, time.Now())
 	}
 }
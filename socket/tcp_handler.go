@@ -1,28 +1,62 @@
 package socket
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	b "projectt/binary"
-	"projectt/config"
 	"projectt/types"
+	"time"
+)
+
+const (
+	// tlsHandshakeTimeout is how long a client may take to complete the TLS
+	// handshake
+	tlsHandshakeTimeout = 10 * time.Second
+	// idleReadTimeout closes connections that stopped sending altogether,
+	// should the heartbeat check not have disconnected them already
+	idleReadTimeout = 2 * heartbeatTimeout
+	// frameReadTimeout is how long the rest of a frame may take to arrive
+	// once its length was read
+	frameReadTimeout = 10 * time.Second
 )
 
 func handleTCPConnection(server *GameServer, conn net.Conn) {
 	defer conn.Close()
 
-	conn.(*net.TCPConn).SetNoDelay(true)
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetNoDelay(true)
+	}
+	// Bound the handshake instead of running it on the first read or write
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		ctx, cancel := context.WithTimeout(server.ctx, tlsHandshakeTimeout)
+		err := tlsConn.HandshakeContext(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("TLS handshake with %s failed: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
 
 	gc := NewGameConnection(conn, server)
 	fmt.Printf("New connection from %s\n", conn.RemoteAddr())
 
+	if ban := server.findBan(0, remoteIP(conn)); ban != nil {
+		log.Printf("Refusing banned address %s (ban %d)", conn.RemoteAddr(), ban.ID)
+		server.metrics.ConnRefused.WithLabelValues("banned").Inc()
+		gc.sendDisconnect(banDisconnectMessage(ban))
+		return
+	}
+
 	// Make sure we don't exceed max connections
 	server.mu.Lock()
-	if len(server.connections) >= config.MaxPlayers {
+	if len(server.connections) >= server.cfg.Game.MaxPlayers {
 		server.mu.Unlock()
+		server.metrics.ConnRefused.WithLabelValues("full").Inc()
 		gc.SendTCPMessage(b.Message{
 			Type:  types.LoginMessage,
 			Error: "error.server.full",
@@ -33,7 +67,13 @@ func handleTCPConnection(server *GameServer, conn net.Conn) {
 	server.mu.Unlock()
 
 	// send welcome message
-	data := b.EncodeWelcomeMessage(&b.WelcomeMessage{ConnectionID: gc.connID})
+	data := b.EncodeWelcomeMessage(&b.WelcomeMessage{
+		ConnectionID:   gc.connID,
+		TicksPerSecond: uint16(server.loop.TicksPerSecond()),
+		Tick:           uint32(server.loop.Tick()),
+		SessionKey:     gc.udpKey,
+		EncryptedUDP:   isTLS(conn),
+	})
 	gc.SendTCPMessage(b.Message{
 		Type: types.WelcomeMessage,
 		Data: data,
@@ -42,6 +82,7 @@ func handleTCPConnection(server *GameServer, conn net.Conn) {
 	// Read messages in a loop
 	for {
 		// Read message length (4 bytes)
+		conn.SetReadDeadline(time.Now().Add(idleReadTimeout))
 		lenBuf := make([]byte, 4)
 		_, err := io.ReadFull(conn, lenBuf)
 		if err != nil {
@@ -52,8 +93,16 @@ func handleTCPConnection(server *GameServer, conn net.Conn) {
 			break
 		}
 		messageLen := binary.LittleEndian.Uint32(lenBuf)
+		// The stream cannot be resynchronized after an oversized frame
+		if messageLen > b.MaxFrameSize {
+			log.Printf("Frame of %d bytes from %s exceeds the limit", messageLen, conn.RemoteAddr())
+			server.metrics.Violations.WithLabelValues("oversized").Inc()
+			gc.Disconnect(&b.DisconnectMessage{Reason: b.DisconnectReasonProtocolViolation})
+			break
+		}
 
-		// Read message data
+		// Read message data, which must follow its length without delay
+		conn.SetReadDeadline(time.Now().Add(frameReadTimeout))
 		data := make([]byte, messageLen)
 		_, err = io.ReadFull(conn, data)
 		if err != nil {
@@ -64,8 +113,10 @@ func handleTCPConnection(server *GameServer, conn net.Conn) {
 		handleMessage(server, gc, data)
 	}
 
-	// Handle disconnection
+	// Handle disconnection, the player may resume within the grace period
 	server.mu.Lock()
-	gc.handleDisconnect()
+	if !gc.suspend(time.Now()) {
+		gc.handleDisconnect()
+	}
 	server.mu.Unlock()
 }
@@ -1,49 +1,121 @@
 package socket
 
 import (
-	"encoding/binary"
 	"fmt"
 	"log"
 	"net"
+	b "projectt/binary"
 )
 
 func handleUDPConnection(server *GameServer, conn *net.UDPConn, addr *net.UDPAddr, data []byte) {
-	if len(data) < 4 {
+	datagram, err := b.ParseDatagram(data)
+	if err != nil {
+		server.metrics.UDPRejected.WithLabelValues("malformed").Inc()
 		return
 	}
 
-	connID := binary.LittleEndian.Uint32(data[0:])
-
 	// Find game connection for this address
-	gc, err := findConnection(server, conn, addr, connID)
+	gc, payload, err := findConnection(server, conn, addr, datagram)
 	if err != nil {
 		return
 	}
 
-	handleMessage(server, gc, data[4:])
+	handleMessage(server, gc, payload)
 }
 
-func findConnection(server *GameServer, conn *net.UDPConn, addr *net.UDPAddr, connID uint32) (*GameConnection, error) {
-	// Look for existing connection with this address
+// findConnection returns the connection a datagram belongs to and its
+// payload. Only datagrams protected with the connection's key and not seen
+// before are accepted. The first one binds the sender's address; a newer datagram from
+// another address moves the binding there, e.g. after NAT rebinding.
+func findConnection(server *GameServer, conn *net.UDPConn, addr *net.UDPAddr, datagram *b.Datagram) (*GameConnection, []byte, error) {
+	connID := datagram.ConnectionID
 	server.mu.RLock()
 	gc, exists := server.connections[connID]
 	server.mu.RUnlock()
 
-	if exists {
-		gc.mu.Lock()
-		defer gc.mu.Unlock()
-		if gc.udpAddr == nil {
-			// First time seeing this address, bind it
-			gc.udpAddr = addr
-			gc.udpConn = conn
-			log.Printf("Bound UDP for %s to connID %d", addr.String(), connID)
-			return gc, nil
-		}
-		if gc.udpAddr.String() != addr.String() {
-			return nil, fmt.Errorf("connection ID mismatch")
+	if !exists {
+		server.metrics.UDPRejected.WithLabelValues("unknown").Inc()
+		return nil, nil, fmt.Errorf("connection not found")
+	}
+
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if gc.disconnected {
+		return nil, nil, fmt.Errorf("connection closed")
+	}
+	// Input waits until the session is resumed over TCP
+	if !gc.suspendedAt.IsZero() {
+		return nil, nil, fmt.Errorf("session suspended")
+	}
+	payload, err := gc.udpCodec.OpenClient(datagram)
+	if err != nil {
+		server.metrics.UDPRejected.WithLabelValues("bad_mac").Inc()
+		return nil, nil, err
+	}
+	newest, ok := gc.udpReplay.check(datagram.Sequence)
+	if !ok {
+		server.metrics.UDPRejected.WithLabelValues("replay").Inc()
+		return nil, nil, fmt.Errorf("replayed datagram")
+	}
+
+	switch {
+	case gc.udpAddr == nil:
+		// First time seeing this address, bind it
+		log.Printf("Bound UDP for %s to connID %d", addr.String(), connID)
+	case gc.udpAddr.String() != addr.String():
+		// A delayed datagram from the old address must not move it back
+		if !newest {
+			server.metrics.UDPRejected.WithLabelValues("stale_address").Inc()
+			return nil, nil, fmt.Errorf("datagram from previous address")
 		}
-		return gc, nil
+		log.Printf("Rebound UDP for connID %d from %s to %s", connID, gc.udpAddr.String(), addr.String())
+		server.metrics.UDPRebinds.Inc()
 	}
+	gc.udpReplay.mark(datagram.Sequence)
+	gc.udpAddr = addr
+	gc.udpConn = conn
+	return gc, payload, nil
+}
 
-	return nil, fmt.Errorf("connection not found")
+// replayWindowSize is how far behind the newest datagram a reordered one
+// may arrive and still be accepted
+const replayWindowSize = 64
+
+// replayWindow tracks the sequence numbers seen on a connection. It accepts
+// each number once, as long as it is within replayWindowSize of the newest.
+type replayWindow struct {
+	newest uint64
+	seen   uint64 // bit i is set if newest-i was seen
+}
+
+// check reports whether sequence may be accepted and whether it is newer
+// than every sequence seen so far
+func (w *replayWindow) check(sequence uint64) (newest bool, ok bool) {
+	if sequence == 0 {
+		return false, false
+	}
+	if sequence > w.newest {
+		return true, true
+	}
+	age := w.newest - sequence
+	if age >= replayWindowSize {
+		return false, false
+	}
+	return false, w.seen&(1<<age) == 0
+}
+
+// mark records sequence as seen. It must have passed check.
+func (w *replayWindow) mark(sequence uint64) {
+	if sequence > w.newest {
+		shift := sequence - w.newest
+		if shift >= replayWindowSize {
+			w.seen = 0
+		} else {
+			w.seen <<= shift
+		}
+		w.newest = sequence
+		w.seen |= 1
+		return
+	}
+	w.seen |= 1 << (w.newest - sequence)
 }
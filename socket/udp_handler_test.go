@@ -0,0 +1,160 @@
+package socket
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	b "projectt/binary"
+	"projectt/config"
+	"projectt/types"
+	"testing"
+	"time"
+)
+
+func TestReplayWindow(t *testing.T) {
+	var w replayWindow
+	accept := func(sequence uint64) bool {
+		_, ok := w.check(sequence)
+		if ok {
+			w.mark(sequence)
+		}
+		return ok
+	}
+
+	for _, sequence := range []uint64{1, 3, 2, 10} {
+		if !accept(sequence) {
+			t.Fatalf("sequence %d was refused", sequence)
+		}
+	}
+	for _, sequence := range []uint64{0, 1, 3, 10} {
+		if accept(sequence) {
+			t.Fatalf("sequence %d was accepted twice", sequence)
+		}
+	}
+	if !accept(5) {
+		t.Fatal("reordered sequence within the window was refused")
+	}
+
+	if !accept(10 + replayWindowSize) {
+		t.Fatal("sequence far ahead was refused")
+	}
+	if accept(9) || accept(10) {
+		t.Fatal("sequence outside the window was accepted")
+	}
+	if newest, ok := w.check(10 + replayWindowSize - 1); newest || !ok {
+		t.Fatalf("expected an older unseen sequence, got newest %v ok %v", newest, ok)
+	}
+}
+
+// expectNoUDP fails if a datagram arrives on conn within a short time
+func expectNoUDP(t *testing.T, conn *net.UDPConn) {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	buffer := make([]byte, 1500)
+	if n, err := conn.Read(buffer); err == nil {
+		t.Fatalf("unexpected datagram %v", buffer[:n])
+	}
+}
+
+func TestUDPAuthentication(t *testing.T) {
+	server, _ := newTestServer(t)
+	alice := dialTestClient(t, server)
+	alice.login("Alice")
+	alice.dialUDP(server)
+
+	ping, err := b.EncodeRawMessage(b.Message{Type: types.PingPongMessage, Data: []byte("ping")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Knowing the connection ID is not enough to take over the binding
+	attacker, err := net.DialUDP("udp", nil, server.udpConn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer attacker.Close()
+	forged := b.NewSignedCodec([b.DatagramKeySize]byte{}).SealClient(alice.connID, alice.udpSeq+1, ping)
+	if _, err := attacker.Write(forged); err != nil {
+		t.Fatal(err)
+	}
+	expectNoUDP(t, attacker)
+	alice.sendUDP(b.Message{Type: types.PingPongMessage, Data: []byte("still bound")})
+	alice.expectUDP(types.PingPongMessage)
+
+	// A captured datagram is only handled once
+	alice.udpSeq++
+	packet := alice.codec.SealClient(alice.connID, alice.udpSeq, ping)
+	for range 2 {
+		if _, err := alice.udp.Write(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alice.expectUDP(types.PingPongMessage)
+	expectNoUDP(t, alice.udp)
+
+	// A signed datagram from a new address moves the binding there. One
+	// sequence number is skipped to have an unseen older one below.
+	old := alice.udp
+	alice.udpSeq++
+	alice.dialUDP(server)
+	expectNoUDP(t, old)
+
+	// Older datagrams from the previous address no longer move it back
+	stale := alice.codec.SealClient(alice.connID, alice.udpSeq-1, ping)
+	if _, err := old.Write(stale); err != nil {
+		t.Fatal(err)
+	}
+	expectNoUDP(t, old)
+	alice.sendUDP(b.Message{Type: types.PingPongMessage, Data: []byte("moved")})
+	alice.expectUDP(types.PingPongMessage)
+}
+
+func TestEncryptedUDP(t *testing.T) {
+	server, _ := newTestServer(t, func(cfg *config.Config) {
+		cfg.TLS.Enabled = true
+		cfg.TLS.SelfSigned = true
+	})
+	alice := dialTestClient(t, server)
+	if _, ok := alice.conn.(*tls.Conn); !ok {
+		t.Fatal("expected a TLS connection")
+	}
+	alice.login("Alice")
+	alice.dialUDP(server)
+
+	// Payloads are unreadable in both directions
+	secret := []byte("secret movement")
+	raw, err := b.EncodeRawMessage(b.Message{Type: types.PingPongMessage, Data: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice.udpSeq++
+	packet := alice.codec.SealClient(alice.connID, alice.udpSeq, raw)
+	if bytes.Contains(packet, secret) {
+		t.Fatal("client datagram is not encrypted")
+	}
+	if _, err := alice.udp.Write(packet); err != nil {
+		t.Fatal(err)
+	}
+	alice.udp.SetReadDeadline(time.Now().Add(testTimeout))
+	buffer := make([]byte, 1500)
+	n, err := alice.udp.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buffer[:n], secret) {
+		t.Fatal("server datagram is not encrypted")
+	}
+	if payload, err := alice.codec.OpenServer(buffer[:n]); err != nil || !bytes.Contains(payload, secret) {
+		t.Fatalf("failed to decrypt the reply: %v", err)
+	}
+
+	// Tampered datagrams are dropped
+	alice.udpSeq++
+	packet = alice.codec.SealClient(alice.connID, alice.udpSeq, raw)
+	packet[len(packet)-1] ^= 1
+	if _, err := alice.udp.Write(packet); err != nil {
+		t.Fatal(err)
+	}
+	expectNoUDP(t, alice.udp)
+}
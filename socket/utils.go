@@ -2,6 +2,7 @@ package socket
 
 import (
 	"projectt/binary"
+	"projectt/game/entity"
 	"projectt/models"
 )
 
@@ -29,3 +30,39 @@ func getBinaryCountry(m models.Country) binary.Country {
 		IsAIControlled: m.IsAIControlled,
 	}
 }
+
+func getEntityMovementData(e *entity.Entity, tick uint64) binary.EntityMovementData {
+	data := binary.EntityMovementData{
+		EntityID:   uint32(e.ID),
+		EntityType: uint8(e.Type),
+		CountryID:  e.Country,
+		PosX:       e.X,
+		PosY:       e.Y,
+		VelX:       e.VelX,
+		VelY:       e.VelY,
+		Tick:       uint32(tick),
+	}
+	if e.Body != nil {
+		data.UnitType = uint8(e.Body.UnitType)
+	}
+	return data
+}
+
+func getBinaryEntity(e *entity.Entity) binary.Entity {
+	be := binary.Entity{
+		ID:        uint32(e.ID),
+		Type:      uint8(e.Type),
+		CountryID: e.Country,
+		PosX:      e.X,
+		PosY:      e.Y,
+		VelX:      e.VelX,
+		VelY:      e.VelY,
+	}
+	if e.Body != nil {
+		be.UnitType = uint8(e.Body.UnitType)
+	}
+	if e.Health != nil {
+		be.Health, be.MaxHealth = uint32(max(0, e.Health.Current)), uint32(e.Health.Max)
+	}
+	return be
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"errors"
+	"projectt/models"
+	"testing"
+)
+
+func testBans(t *testing.T, store Store) {
+	t.Helper()
+
+	playerID := uint(7)
+	account := &models.Ban{PlayerID: &playerID, Nickname: "Ryuzaki", Reason: "griefing"}
+	network := &models.Ban{CIDR: "203.0.113.0/24", Reason: "proxy"}
+	for _, ban := range []*models.Ban{account, network} {
+		if err := store.SaveBan(ban); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if account.ID == 0 || network.ID == account.ID {
+		t.Fatalf("expected distinct IDs, got %d and %d", account.ID, network.ID)
+	}
+
+	if err := store.DeleteBan(account.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteBan(account.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	bans, err := store.LoadBans()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bans) != 1 || bans[0].CIDR != "203.0.113.0/24" || bans[0].PlayerID != nil {
+		t.Fatalf("unexpected bans %+v", bans)
+	}
+}
+
+func TestMemoryBans(t *testing.T) {
+	testBans(t, NewMemory())
+}
+
+func TestSQLiteBans(t *testing.T) {
+	testBans(t, newSQLiteStore(t))
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"projectt/models"
+	"testing"
+	"time"
+)
+
+func testChatHistory(t *testing.T, store Store) {
+	t.Helper()
+
+	old := time.Now().Add(-48 * time.Hour)
+	messages := []*models.ChatMessage{
+		{Channel: 0, CountryID: 1, From: "a", Message: "old", CreatedAt: old},
+		{Channel: 0, CountryID: 2, From: "b", Message: "one"},
+		{Channel: 2, CountryID: 1, From: "a", Message: "country"},
+		{Channel: 0, CountryID: 1, From: "a", Message: "two"},
+		{Channel: 2, CountryID: 2, From: "b", Message: "other country"},
+		{Channel: 0, CountryID: 1, From: "a", Message: "three"},
+	}
+	for _, msg := range messages {
+		if err := store.SaveChatMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	texts := func(messages []models.ChatMessage) []string {
+		result := make([]string, len(messages))
+		for i, msg := range messages {
+			result[i] = msg.Message
+		}
+		return result
+	}
+
+	general, err := store.RecentChatMessages(0, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := texts(general); len(got) != 3 || got[0] != "one" || got[2] != "three" {
+		t.Fatalf("unexpected general history %q", got)
+	}
+	country, _ := store.RecentChatMessages(2, 1, 10)
+	if got := texts(country); len(got) != 1 || got[0] != "country" {
+		t.Fatalf("unexpected country history %q", got)
+	}
+
+	deleted, err := store.DeleteChatMessagesBefore(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected 1 expired message, got %d", deleted)
+	}
+	if all, _ := store.RecentChatMessages(0, 0, 10); len(all) != 3 {
+		t.Fatalf("expected 3 general messages after pruning, got %d", len(all))
+	}
+}
+
+func testMail(t *testing.T, store Store) {
+	t.Helper()
+
+	for _, mail := range []*models.Mail{
+		{RecipientID: 1, From: "a", Message: "first"},
+		{RecipientID: 2, From: "a", Message: "someone else"},
+		{RecipientID: 1, From: "b", Message: "second"},
+	} {
+		if err := store.SaveMail(mail); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if count, _ := store.CountMail(1); count != 2 {
+		t.Fatalf("expected 2 letters, got %d", count)
+	}
+	mail, err := store.LoadMail(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mail) != 2 || mail[0].Message != "first" || mail[1].Message != "second" {
+		t.Fatalf("unexpected mail %+v", mail)
+	}
+	if err := store.DeleteMail([]uint{mail[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := store.LoadMail(1); len(left) != 1 || left[0].Message != "second" {
+		t.Fatalf("unexpected mail after deleting the first %+v", left)
+	}
+	if count, _ := store.CountMail(2); count != 1 {
+		t.Fatalf("other mailbox changed, %d letters", count)
+	}
+}
+
+func TestMemoryChat(t *testing.T) {
+	testChatHistory(t, NewMemory())
+	testMail(t, NewMemory())
+}
+
+func TestSQLiteChat(t *testing.T) {
+	testChatHistory(t, newSQLiteStore(t))
+	testMail(t, newSQLiteStore(t))
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"projectt/config"
+	"projectt/models"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewGorm returns a Store backed by a GORM database (Postgres or SQLite)
+func NewGorm(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+func (s *gormStore) FindPlayerByNickname(nickname string) (*models.Player, error) {
+	var player models.Player
+	err := s.db.Preload("Unit").Where("LOWER(nickname) = LOWER(?)", nickname).First(&player).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &player, nil
+}
+
+func (s *gormStore) SavePlayer(player *models.Player) error {
+	return s.db.Save(player).Error
+}
+
+func (s *gormStore) SavePlayers(players []*models.Player) error {
+	if len(players) == 0 {
+		return nil
+	}
+	return s.db.Save(&players).Error
+}
+
+func (s *gormStore) LoadCountries() ([]models.Country, error) {
+	var countries []models.Country
+	if err := s.db.Find(&countries).Error; err != nil {
+		return nil, err
+	}
+	return countries, nil
+}
+
+func (s *gormStore) SaveCountry(country *models.Country) error {
+	return s.db.Save(country).Error
+}
+
+func (s *gormStore) LoadTiles() ([]models.MapTile, error) {
+	var tiles []models.MapTile
+	if err := s.db.Find(&tiles).Error; err != nil {
+		return nil, err
+	}
+	return tiles, nil
+}
+
+func (s *gormStore) SaveTiles(tiles []models.MapTile) error {
+	if len(tiles) == 0 {
+		return nil
+	}
+	return s.db.Save(&tiles).Error
+}
+
+func (s *gormStore) LoadUnits() ([]models.Unit, error) {
+	var units []models.Unit
+	if err := s.db.Find(&units).Error; err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+func (s *gormStore) SaveUnit(unit *models.Unit) error {
+	return s.db.Save(unit).Error
+}
+
+func (s *gormStore) DBStats() sql.DBStats {
+	return config.GetDBStats(s.db)
+}
+
+func (s *gormStore) SaveChatMessage(msg *models.ChatMessage) error {
+	return s.db.Create(msg).Error
+}
+
+func (s *gormStore) RecentChatMessages(channel, countryID uint8, limit int) ([]models.ChatMessage, error) {
+	query := s.db.Where("channel = ?", channel)
+	if countryID != 0 {
+		query = query.Where("country_id = ?", countryID)
+	}
+
+	var messages []models.ChatMessage
+	if err := query.Order("id DESC").Limit(limit).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+func (s *gormStore) DeleteChatMessagesBefore(t time.Time) (int64, error) {
+	result := s.db.Where("created_at < ?", t).Delete(&models.ChatMessage{})
+	return result.RowsAffected, result.Error
+}
+
+func (s *gormStore) SaveMail(mail *models.Mail) error {
+	return s.db.Create(mail).Error
+}
+
+func (s *gormStore) CountMail(recipientID uint) (int64, error) {
+	var count int64
+	err := s.db.Model(&models.Mail{}).Where("recipient_id = ?", recipientID).Count(&count).Error
+	return count, err
+}
+
+func (s *gormStore) LoadMail(recipientID uint) ([]models.Mail, error) {
+	var mail []models.Mail
+	if err := s.db.Where("recipient_id = ?", recipientID).Order("id").Find(&mail).Error; err != nil {
+		return nil, err
+	}
+	return mail, nil
+}
+
+func (s *gormStore) DeleteMail(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.Delete(&models.Mail{}, ids).Error
+}
+
+func (s *gormStore) LoadBans() ([]models.Ban, error) {
+	var bans []models.Ban
+	if err := s.db.Order("id").Find(&bans).Error; err != nil {
+		return nil, err
+	}
+	return bans, nil
+}
+
+func (s *gormStore) SaveBan(ban *models.Ban) error {
+	return s.db.Save(ban).Error
+}
+
+func (s *gormStore) DeleteBan(id uint) error {
+	result := s.db.Delete(&models.Ban{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"errors"
+	"projectt/models"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newSQLiteStore(t *testing.T) Store {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Country{}, &models.MapTile{}, &models.Player{}, &models.Unit{}, &models.ChatMessage{}, &models.Mail{}, &models.Ban{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewGorm(db)
+}
+
+func TestSQLitePlayers(t *testing.T) {
+	store := newSQLiteStore(t)
+
+	if err := store.SaveCountry(&models.Country{Code: "TR"}); err != nil {
+		t.Fatal(err)
+	}
+	player := &models.Player{Nickname: "Ryuzaki", CountryID: 1}
+	if err := store.SavePlayer(player); err != nil {
+		t.Fatal(err)
+	}
+
+	player.CoordX = 12
+	if err := store.SavePlayers([]*models.Player{player}); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := store.FindPlayerByNickname("RYUZAKI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != player.ID || found.CoordX != 12 {
+		t.Fatalf("unexpected player %+v", found)
+	}
+
+	if _, err := store.FindPlayerByNickname("nobody"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
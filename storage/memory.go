@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"fmt"
+	"projectt/models"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryStore struct {
+	players   map[uint]*models.Player
+	countries map[uint8]models.Country
+	tiles     map[string]models.MapTile
+	units     map[uint]models.Unit
+	chat      []models.ChatMessage // ordered by ID
+	mail      []models.Mail        // ordered by ID
+	bans      []models.Ban         // ordered by ID
+	nextID    uint                 // for chat messages, mail and bans
+	mu        sync.RWMutex
+}
+
+// NewMemory returns a Store that keeps everything in process memory.
+// Data is lost when the server stops; it is intended for tests and local experiments.
+func NewMemory() Store {
+	return &memoryStore{
+		players:   make(map[uint]*models.Player),
+		countries: make(map[uint8]models.Country),
+		tiles:     make(map[string]models.MapTile),
+		units:     make(map[uint]models.Unit),
+	}
+}
+
+func (s *memoryStore) FindPlayerByNickname(nickname string) (*models.Player, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, player := range s.players {
+		if strings.EqualFold(player.Nickname, nickname) {
+			found := player.Copy()
+			if found.UnitID != nil {
+				if unit, exists := s.units[*found.UnitID]; exists {
+					found.Unit = &unit
+				}
+			}
+			return found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) SavePlayer(player *models.Player) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.savePlayer(player)
+	return nil
+}
+
+func (s *memoryStore) SavePlayers(players []*models.Player) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, player := range players {
+		s.savePlayer(player)
+	}
+	return nil
+}
+
+// savePlayer must be called with the store mutex locked
+func (s *memoryStore) savePlayer(player *models.Player) {
+	now := time.Now()
+	if player.ID == 0 {
+		player.ID = uint(len(s.players) + 1)
+		for s.players[player.ID] != nil {
+			player.ID++
+		}
+		player.CreatedAt = now
+	}
+	player.UpdatedAt = now
+	s.players[player.ID] = player.Copy()
+}
+
+func (s *memoryStore) LoadCountries() ([]models.Country, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	countries := make([]models.Country, 0, len(s.countries))
+	for _, country := range s.countries {
+		countries = append(countries, country)
+	}
+	return countries, nil
+}
+
+func (s *memoryStore) SaveCountry(country *models.Country) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if country.ID == 0 {
+		country.ID = uint8(len(s.countries) + 1)
+	}
+	s.countries[country.ID] = *country
+	return nil
+}
+
+func (s *memoryStore) LoadTiles() ([]models.MapTile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tiles := make([]models.MapTile, 0, len(s.tiles))
+	for _, tile := range s.tiles {
+		tiles = append(tiles, tile)
+	}
+	return tiles, nil
+}
+
+func (s *memoryStore) SaveTiles(tiles []models.MapTile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tile := range tiles {
+		s.tiles[fmt.Sprintf("%d,%d", tile.CoordX, tile.CoordY)] = tile
+	}
+	return nil
+}
+
+func (s *memoryStore) LoadUnits() ([]models.Unit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	units := make([]models.Unit, 0, len(s.units))
+	for _, unit := range s.units {
+		units = append(units, unit)
+	}
+	return units, nil
+}
+
+func (s *memoryStore) SaveUnit(unit *models.Unit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if unit.ID == 0 {
+		unit.ID = uint(len(s.units) + 1)
+		for {
+			if _, exists := s.units[unit.ID]; !exists {
+				break
+			}
+			unit.ID++
+		}
+	}
+	s.units[unit.ID] = *unit
+	return nil
+}
+
+func (s *memoryStore) SaveChatMessage(msg *models.ChatMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	msg.ID = s.nextID
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	s.chat = append(s.chat, *msg)
+	return nil
+}
+
+func (s *memoryStore) RecentChatMessages(channel, countryID uint8, limit int) ([]models.ChatMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	messages := make([]models.ChatMessage, 0, limit)
+	for i := len(s.chat) - 1; i >= 0 && len(messages) < limit; i-- {
+		msg := s.chat[i]
+		if msg.Channel == channel && (countryID == 0 || msg.CountryID == countryID) {
+			messages = append(messages, msg)
+		}
+	}
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+func (s *memoryStore) DeleteChatMessagesBefore(t time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := len(s.chat)
+	s.chat = slices.DeleteFunc(s.chat, func(msg models.ChatMessage) bool {
+		return msg.CreatedAt.Before(t)
+	})
+	return int64(before - len(s.chat)), nil
+}
+
+func (s *memoryStore) SaveMail(mail *models.Mail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	mail.ID = s.nextID
+	if mail.CreatedAt.IsZero() {
+		mail.CreatedAt = time.Now()
+	}
+	s.mail = append(s.mail, *mail)
+	return nil
+}
+
+func (s *memoryStore) CountMail(recipientID uint) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int64
+	for _, mail := range s.mail {
+		if mail.RecipientID == recipientID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memoryStore) LoadMail(recipientID uint) ([]models.Mail, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var mail []models.Mail
+	for _, m := range s.mail {
+		if m.RecipientID == recipientID {
+			mail = append(mail, m)
+		}
+	}
+	return mail, nil
+}
+
+func (s *memoryStore) DeleteMail(ids []uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mail = slices.DeleteFunc(s.mail, func(mail models.Mail) bool {
+		return slices.Contains(ids, mail.ID)
+	})
+	return nil
+}
+
+func (s *memoryStore) LoadBans() ([]models.Ban, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.bans), nil
+}
+
+func (s *memoryStore) SaveBan(ban *models.Ban) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ban.ID != 0 {
+		for i := range s.bans {
+			if s.bans[i].ID == ban.ID {
+				s.bans[i] = *ban
+				return nil
+			}
+		}
+	}
+
+	s.nextID++
+	ban.ID = s.nextID
+	if ban.CreatedAt.IsZero() {
+		ban.CreatedAt = time.Now()
+	}
+	s.bans = append(s.bans, *ban)
+	return nil
+}
+
+func (s *memoryStore) DeleteBan(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, ban := range s.bans {
+		if ban.ID == id {
+			s.bans = slices.Delete(s.bans, i, i+1)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"errors"
+	"projectt/models"
+	"testing"
+)
+
+func TestMemoryPlayers(t *testing.T) {
+	store := NewMemory()
+
+	player := &models.Player{Nickname: "Ryuzaki", CountryID: 1}
+	if err := store.SavePlayer(player); err != nil {
+		t.Fatal(err)
+	}
+	if player.ID == 0 {
+		t.Fatal("expected an ID to be assigned")
+	}
+
+	found, err := store.FindPlayerByNickname("ryuzaki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != player.ID {
+		t.Fatalf("expected player %d, got %d", player.ID, found.ID)
+	}
+
+	// returned players must not alias stored state
+	found.CoordX = 42
+	again, _ := store.FindPlayerByNickname("Ryuzaki")
+	if again.CoordX != 0 {
+		t.Fatal("store was modified through a returned player")
+	}
+
+	if _, err := store.FindPlayerByNickname("nobody"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemoryTiles(t *testing.T) {
+	store := NewMemory()
+
+	store.SaveTiles([]models.MapTile{{CoordX: 1, CoordY: 2}, {CoordX: 3, CoordY: 4}})
+	store.SaveTiles([]models.MapTile{{CoordX: 1, CoordY: 2, OwnerCountryID: 7}})
+
+	tiles, _ := store.LoadTiles()
+	if len(tiles) != 2 {
+		t.Fatalf("expected 2 tiles, got %d", len(tiles))
+	}
+	for _, tile := range tiles {
+		if tile.CoordX == 1 && tile.OwnerCountryID != 7 {
+			t.Fatal("tile was not updated")
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"projectt/models"
+	"time"
+)
+
+// ErrNotFound is returned when a requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// Store is the persistence layer used by the game server
+type Store interface {
+	// Players
+	FindPlayerByNickname(nickname string) (*models.Player, error)
+	SavePlayer(player *models.Player) error
+	SavePlayers(players []*models.Player) error
+
+	// Countries
+	LoadCountries() ([]models.Country, error)
+	SaveCountry(country *models.Country) error
+
+	// Map tiles
+	LoadTiles() ([]models.MapTile, error)
+	SaveTiles(tiles []models.MapTile) error
+
+	// Units
+	LoadUnits() ([]models.Unit, error)
+	SaveUnit(unit *models.Unit) error
+
+	// Chat history
+	SaveChatMessage(msg *models.ChatMessage) error
+	// RecentChatMessages returns up to limit messages of a channel, oldest
+	// first. A countryID of 0 matches every country.
+	RecentChatMessages(channel, countryID uint8, limit int) ([]models.ChatMessage, error)
+	// DeleteChatMessagesBefore removes messages older than t and returns how
+	// many were deleted
+	DeleteChatMessagesBefore(t time.Time) (int64, error)
+
+	// Offline messages
+	SaveMail(mail *models.Mail) error
+	CountMail(recipientID uint) (int64, error)
+	// LoadMail returns a player's mail, oldest first
+	LoadMail(recipientID uint) ([]models.Mail, error)
+	// DeleteMail removes delivered mail
+	DeleteMail(ids []uint) error
+
+	// Bans
+	LoadBans() ([]models.Ban, error)
+	SaveBan(ban *models.Ban) error
+	DeleteBan(id uint) error
+}
+
+// StatsProvider is implemented by stores backed by a database/sql connection pool
+type StatsProvider interface {
+	DBStats() sql.DBStats
+}
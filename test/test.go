@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
 	"os/signal"
+	b "projectt/binary"
 	"projectt/test/message"
 	"strconv"
 	"syscall"
@@ -45,6 +47,13 @@ func main() {
 	}
 	fmt.Printf("Received connection ID: %d\n", connID)
 
+	// Datagrams are signed with the session key after the tick fields
+	var sessionKey [b.DatagramKeySize]byte
+	buf.Seek(6, io.SeekCurrent)
+	if _, err := io.ReadFull(buf, sessionKey[:]); err != nil {
+		log.Fatalf("Can't read session key from data")
+	}
+
 	// UDP bağlantısı oluştur
 	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{
 		IP:   net.ParseIP("127.0.0.1"),
@@ -98,10 +107,8 @@ func main() {
 		panic(err)
 	}
 
-	// Combine connID and data into a single buffer
-	messageBuffer := make([]byte, 4+len(rawData))
-	binary.LittleEndian.PutUint32(messageBuffer[:4], uint32(connID))
-	copy(messageBuffer[4:], rawData)
+	// Sign connID, sequence and data with the session key
+	messageBuffer := b.NewSignedCodec(sessionKey).SealClient(connID, 1, rawData)
 
 	// Send message
 	_, err = conn.Write(messageBuffer)
@@ -0,0 +1,11 @@
+package types
+
+// EntityType is the kind of thing an entity in the world is
+type EntityType uint8
+
+const (
+	EntityTypePlayer     EntityType = iota + 1
+	EntityTypeUnit                  // vehicle of an AI country
+	EntityTypeSoldier               // infantry of an AI country
+	EntityTypeProjectile            // shot fired by an armed entity
+)
@@ -19,4 +19,38 @@ const (
 	ChunkRequestMessage
 	ChunkDataMessage
 	DisconnectMessage
+	CommandListMessage
+	SessionMessage
+	EntityMovementMessage
+	EntityRemovedMessage
 )
+
+var messageTypeNames = [...]string{
+	WelcomeMessage:        "welcome",
+	LoginMessage:          "login",
+	ChatMessage:           "chat",
+	SystemMessage:         "system",
+	UnauthorizedMessage:   "unauthorized",
+	UnknownMessage:        "unknown",
+	PlayerMovementMessage: "player_movement",
+	PlayerJoinedMessage:   "player_joined",
+	PlayerLeftMessage:     "player_left",
+	PlayerDataMessage:     "player_data",
+	PingPongMessage:       "ping_pong",
+	SyncStateMessage:      "sync_state",
+	UnitActionMessage:     "unit_action",
+	ChunkRequestMessage:   "chunk_request",
+	ChunkDataMessage:      "chunk_data",
+	DisconnectMessage:     "disconnect",
+	CommandListMessage:    "command_list",
+	SessionMessage:        "session",
+	EntityMovementMessage: "entity_movement",
+	EntityRemovedMessage:  "entity_removed",
+}
+
+func (t MessageType) String() string {
+	if int(t) < len(messageTypeNames) && messageTypeNames[t] != "" {
+		return messageTypeNames[t]
+	}
+	return "invalid"
+}
@@ -0,0 +1,34 @@
+package types
+
+import "fmt"
+
+type StaffRole int
+
+const (
+	StaffRoleNone StaffRole = iota
+	StaffRoleModerator
+	StaffRoleAdmin
+)
+
+var staffRoleNames = map[StaffRole]string{
+	StaffRoleNone:      "none",
+	StaffRoleModerator: "moderator",
+	StaffRoleAdmin:     "admin",
+}
+
+func (r StaffRole) String() string {
+	if name, ok := staffRoleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("StaffRole(%d)", int(r))
+}
+
+// ParseStaffRole parses a role name as returned by String
+func ParseStaffRole(name string) (StaffRole, error) {
+	for role, roleName := range staffRoleNames {
+		if roleName == name {
+			return role, nil
+		}
+	}
+	return StaffRoleNone, fmt.Errorf("unknown staff role %q", name)
+}
@@ -7,3 +7,8 @@ const (
 	TileTypeWater
 	TileTypeBuilding
 )
+
+// Valid reports whether t is one of the known tile types
+func (t TileType) Valid() bool {
+	return t >= TileTypeGround && t <= TileTypeBuilding
+}